@@ -3,7 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/brofu/simple_ethereum_parser/packages/abi"
 	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
 	"github.com/brofu/simple_ethereum_parser/packages/logging"
 	"github.com/brofu/simple_ethereum_parser/packages/parser"
@@ -19,15 +22,50 @@ func main() {
 
 	logger := logging.NewDefaultLogger(logging.LevelDebug)
 	chainAccesser := ethereum.NewEthJsonRpcClient(entryPoint, logger)
-	parser := parser.NewToolParser(logger, chainAccesser)
 
-	var rootCmd = &cobra.Command{Use: "cmd-tool"}
+	var strategy string
+	var fromBlock int
+	var abiFiles []string
+	var signatureFile string
+	signatures := abi.NewSignatureRegistry()
+	var toolParser parser.Parser
+
+	var rootCmd = &cobra.Command{
+		Use: "cmd-tool",
+		// the parser is constructed after the flags are parsed
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			config := parser.ToolParserConfiguration{
+				Strategy:  parser.IndexingStrategy(strategy),
+				FromBlock: fromBlock,
+			}
+			if err := config.Strategy.Validate(); err != nil {
+				return err
+			}
+			toolParser = parser.NewToolParser(logger, chainAccesser, config)
+			if signatureFile != "" {
+				if err := signatures.LoadFile(signatureFile); err != nil {
+					return err
+				}
+			}
+			for _, abiFile := range abiFiles {
+				if err := registerABI(toolParser, abiFile); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	rootCmd.PersistentFlags().StringVar(&strategy, "strategy", string(parser.StrategyTraceFilter),
+		fmt.Sprintf("the way to find transactions, %q or %q", parser.StrategyTraceFilter, parser.StrategyBlockScan))
+	rootCmd.PersistentFlags().IntVar(&fromBlock, "from-block", 0, "the first block to query transactions. It should be set for the block scan strategy")
+	rootCmd.PersistentFlags().StringVar(&signatureFile, "signatures", "", "the file of custom signatures added to the built-in ones, one per line as `function <signature>` or `event <signature>`")
+	rootCmd.PersistentFlags().StringArrayVar(&abiFiles, "abi", nil, "the ABI JSON file of a contract to decode the calls to it, in the form of `address=file`. It can be repeated")
 
 	var blockNumCmd = &cobra.Command{
 		Use:   "get-block-number",
 		Short: "Get current block number",
 		Run: func(cmd *cobra.Command, args []string) {
-			bn := parser.GetCurrentBlock()
+			bn := toolParser.GetCurrentBlock()
 			fmt.Printf("%d\n", bn)
 		},
 	}
@@ -37,14 +75,56 @@ func main() {
 		Short: "Get transactions of an address",
 		Args:  cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			address := args[0]
-			trx := parser.GetTransactions(address)
-			fmt.Printf("%+v\n", trx)
+			address, err := ethereum.ParseAddress(args[0])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			for _, trx := range toolParser.GetTransactions(address) {
+				fmt.Println(formatTransaction(trx, signatures))
+			}
+		},
+	}
+
+	var tokenCmd = &cobra.Command{
+		Use:   "get-token-transfers [address]",
+		Short: "Get ERC-20 and ERC-721 transfers of an address",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			address, err := ethereum.ParseAddress(args[0])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			for _, transfer := range toolParser.GetTokenTransfers(address) {
+				fmt.Println(formatTokenTransfer(transfer))
+			}
+		},
+	}
+
+	var balanceCmd = &cobra.Command{
+		Use:   "get-balance [address]",
+		Short: "Get balance and nonce of an address",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			address, err := ethereum.ParseAddress(args[0])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			state, ok := toolParser.GetAccountState(address)
+			if !ok {
+				fmt.Println("get balance fail")
+				os.Exit(1)
+			}
+			fmt.Printf("address: %s, balance: %s ETH, nonce: %s, block: %d\n", state.Address, state.Balance.Ether(), state.Nonce, state.BlockNumber)
 		},
 	}
 
 	rootCmd.AddCommand(blockNumCmd)
 	rootCmd.AddCommand(trxCmd)
+	rootCmd.AddCommand(tokenCmd)
+	rootCmd.AddCommand(balanceCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -52,3 +132,78 @@ func main() {
 	}
 
 }
+
+// registerABI register the ABI of a contract, in the form of `address=file`
+func registerABI(toolParser parser.Parser, abiFile string) error {
+	parts := strings.SplitN(abiFile, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid abi flag %q, it should be address=file", abiFile)
+	}
+	address, err := ethereum.ParseAddress(parts[0])
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(parts[1])
+	if err != nil {
+		return err
+	}
+	contractABI, err := abi.Parse(data)
+	if err != nil {
+		return err
+	}
+	toolParser.RegisterABI(address, contractABI)
+	return nil
+}
+
+// formatTransaction format a transaction in one line, with the amounts in human-readable form.
+// The method name is looked up in `signatures` if the call is not decoded by ABI.
+func formatTransaction(trx ethereum.Transaction, signatures *abi.SignatureRegistry) string {
+	line := fmt.Sprintf("block: %d, hash: %s, from: %s, to: %s, value: %s ETH, gas: %s, gas used: %s",
+		trx.BlockNumber, trx.TransactionHash, trx.Action.From, trx.Action.To, trx.Action.Value.Ether(), trx.Action.Gas, trx.Result.GasUsed)
+	if trx.Timestamp != 0 {
+		line += ", time: " + time.Unix(int64(trx.Timestamp), 0).UTC().Format(time.RFC3339)
+	}
+	if trx.Receipt != nil {
+		line += fmt.Sprintf(", status: %s, fee: %s ETH, logs: %d", trx.Receipt.Status, trx.Receipt.Fee.Ether(), trx.Receipt.LogsCount)
+	}
+	if trx.Decoded != nil {
+		line += ", call: " + formatCall(trx.Decoded)
+	} else if signature, ok := signatures.Function(trx.Action.Input); ok {
+		line += ", method: " + signature
+	}
+	if trx.Error != "" {
+		line += ", error: " + trx.Error
+	}
+	return line
+}
+
+// formatTokenTransfer format a token transfer in one line, with the amount in decimal.
+// The decimals of the token are not known, so the value is in the smallest unit of the token.
+func formatTokenTransfer(transfer ethereum.TokenTransfer) string {
+	line := fmt.Sprintf("block: %d, hash: %s, token: %s (%s), from: %s, to: %s",
+		transfer.BlockNumber, transfer.TransactionHash, transfer.Token, transfer.Standard, transfer.From, transfer.To)
+	if transfer.Standard == ethereum.TokenStandardERC721 {
+		return line + ", token id: " + transfer.TokenId.String()
+	}
+	return line + ", value: " + transfer.Value.String()
+}
+
+// formatCall format a decoded call, e.g. `transfer(to=0x..., amount=1000) -> (true)`
+func formatCall(call *abi.DecodedCall) string {
+	formatParams := func(params []abi.Param) string {
+		items := make([]string, 0, len(params))
+		for _, param := range params {
+			if param.Name == "" {
+				items = append(items, fmt.Sprintf("%v", param.Value))
+				continue
+			}
+			items = append(items, fmt.Sprintf("%s=%v", param.Name, param.Value))
+		}
+		return strings.Join(items, ", ")
+	}
+	line := fmt.Sprintf("%s(%s)", call.Method, formatParams(call.Inputs))
+	if len(call.Outputs) > 0 {
+		line += fmt.Sprintf(" -> (%s)", formatParams(call.Outputs))
+	}
+	return line
+}
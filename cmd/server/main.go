@@ -3,10 +3,15 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"time"
 
+	"github.com/brofu/simple_ethereum_parser/packages/abi"
 	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
 	"github.com/brofu/simple_ethereum_parser/packages/logging"
 	"github.com/brofu/simple_ethereum_parser/packages/parser"
@@ -16,12 +21,61 @@ import (
 var (
 	entryPoint     = "https://cloudflare-eth.com/"
 	testEntryPoint = "http://localhost:8080/rpc"
+	// the entry point to subscribe new heads
+	testWsEntryPoint = "ws://localhost:8080/ws"
+	storeFile        = "parser_store.log"
+
+	snapshotFile     = "parser_snapshot.json"
+	snapshotInterval = time.Minute * 10
+
+	// the custom signatures added to the built-in ones, to show the method names without ABIs
+	signatureFile = "parser_signatures.txt"
 )
 
 func main() {
-	context := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	logger := logging.NewDefaultLogger(logging.LevelDebug)
-	chainAccesser := ethereum.NewEthJsonRpcClient(testEntryPoint, logger)
+
+	// more endpoints can be added, the traffic fails over to the others if one is down or rate limited.
+	// The calls to each endpoint are throttled to stay within its rate limit.
+	throttleConfig := ethereum.ThrottleConfiguration{
+		RequestsPerSecond: 20,
+		Burst:             40,
+		MaxInFlight:       10,
+	}
+	// the idle connections are kept for all the calls in flight.
+	// For the authenticated providers, add `ethereum.WithBearerToken` or `ethereum.WithHeader` with the API key.
+	client := ethereum.NewEthJsonRpcClient(testEntryPoint, logger,
+		ethereum.WithConnectionPool(0, throttleConfig.MaxInFlight, 0),
+		ethereum.WithGzip(),
+		ethereum.WithThrottle(throttleConfig),
+	)
+	endpoints := []ethereum.Endpoint{
+		{
+			Name:     testEntryPoint,
+			Accesser: client,
+			Weight:   1,
+		},
+	}
+	multiConfig := ethereum.MultiAccesserConfiguration{
+		HealthCheckInterval: time.Second * 10,
+		MaxHeadLag:          5,
+	}
+	chainAccesser, err := ethereum.NewMultiAccesser(ctx, endpoints, multiConfig, logger)
+	if err != nil {
+		logger.Errorf("construct chain accesser fail | err: %s", err.Error())
+		panic(err)
+	}
+
+	// the data is kept in file, so the server can restart without re-syncing
+	store, err := parser.NewFileTransactionStore(storeFile, 100, 100)
+	if err != nil {
+		logger.Errorf("open transaction store fail | file: %s, err: %s", storeFile, err.Error())
+		panic(err)
+	}
+	defer store.Close()
+
 	config := parser.ServiceParserConfiguration{
 		MaxAddressNumber:            100,
 		MaxTransactionNumber:        100,
@@ -29,25 +83,144 @@ func main() {
 		Interval:                    time.Millisecond * 5000,
 		GetBlockNumberQueryTimeout:  time.Millisecond * 1000,
 		GetTransactionsQueryTimeout: time.Millisecond * 3000,
+		ReorgWindow:                 64,
+		Confirmations:               12,
+		Store:                       store,
+		AddressChunkSize:            20,
+		TokenTransfers:              true,
+		AccountStates:               true,
+		ReceiptEnrichment:           true,
+		BlockTimestamps:             true,
+		HeadSubscriber:              ethereum.NewEthWebSocketClient(testWsEntryPoint, logger),
+		// the filter is installed on one endpoint, the polls fail over to the others until they reach it
+		PendingTransactionWatcher: ethereum.NewPendingTransactionPoller(chainAccesser, time.Second, logger),
+		PendingDropTimeout:        time.Minute * 5,
 	}
-	parser := parser.NewServiceParser(context, logger, chainAccesser, config)
+	// the data in store is newer than the snapshot, so only load the snapshot if the store is empty.
+	// It's restored before the parser starts, so the ongoing rounds are not disturbed.
+	if store.Size() == 0 {
+		if f := openSnapshot(logger); f != nil {
+			defer f.Close()
+			config.Snapshot = f
+		}
+	}
+	serviceParser := parser.NewServiceParser(ctx, logger, chainAccesser, config)
+
+	snapshotter, _ := serviceParser.(parser.Snapshotter)
 
+	stats, _ := chainAccesser.(ethereum.StatsReporter)
 	handler := &Handler{
-		parser: parser,
-		logger: logger,
+		parser:     serviceParser,
+		stats:      stats,
+		signatures: loadSignatures(logger),
+		logger:     logger,
 	}
 
 	http.HandleFunc("/get-block-number", handler.GetBlockNumber)
 	http.HandleFunc("/get-transactions", handler.GetTransactions)
 	http.HandleFunc("/subscribe", handler.Subscribe)
+	http.HandleFunc("/unsubscribe", handler.Unsubscribe)
+	http.HandleFunc("/get-backfill-progress", handler.GetBackfillProgress)
+	http.HandleFunc("/get-sync-status", handler.GetSyncStatus)
+	http.HandleFunc("/get-token-transfers", handler.GetTokenTransfers)
+	http.HandleFunc("/get-endpoint-stats", handler.GetEndpointStats)
+	http.HandleFunc("/get-account", handler.GetAccount)
+	http.HandleFunc("/get-pending-transactions", handler.GetPendingTransactions)
+	http.HandleFunc("/register-abi", handler.RegisterABI)
 
-	logger.Infof("Starting server on :8081...")
-	http.ListenAndServe(":8081", nil)
+	server := &http.Server{Addr: ":8081"}
+	go func() {
+		logger.Infof("Starting server on :8081...")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("server exits | err: %s", err.Error())
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	ticker := time.NewTicker(snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if snapshotter != nil {
+				saveSnapshot(snapshotter, logger)
+			}
+		case sig := <-signals:
+			logger.Infof("shutting down | signal: %s", sig)
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second*5)
+			server.Shutdown(shutdownCtx)
+			shutdownCancel()
+			if snapshotter != nil {
+				saveSnapshot(snapshotter, logger)
+			}
+			return
+		}
+	}
+}
+
+// openSnapshot open the snapshot file to restore the parser state from. nil is returned if it doesn't exist.
+func openSnapshot(logger logging.Logger) *os.File {
+	f, err := os.Open(snapshotFile)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Infof("no snapshot to load | file: %s", snapshotFile)
+		return nil
+	}
+	if err != nil {
+		logger.Errorf("open snapshot fail | file: %s, err: %s", snapshotFile, err.Error())
+		return nil
+	}
+	return f
+}
+
+// saveSnapshot write the parser state into the snapshot file.
+// It's written into a temp file first, so the old snapshot is kept if anything goes wrong.
+func saveSnapshot(snapshotter parser.Snapshotter, logger logging.Logger) {
+	tmpFile := snapshotFile + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		logger.Errorf("create snapshot fail | file: %s, err: %s", tmpFile, err.Error())
+		return
+	}
+
+	if err := snapshotter.Snapshot(f); err != nil {
+		f.Close()
+		logger.Errorf("save snapshot fail | file: %s, err: %s", tmpFile, err.Error())
+		return
+	}
+	if err := f.Close(); err != nil {
+		logger.Errorf("save snapshot fail | file: %s, err: %s", tmpFile, err.Error())
+		return
+	}
+	if err := os.Rename(tmpFile, snapshotFile); err != nil {
+		logger.Errorf("save snapshot fail | file: %s, err: %s", snapshotFile, err.Error())
+	}
+}
+
+// loadSignatures construct the signature registry, with the custom signatures in the file if it exists
+func loadSignatures(logger logging.Logger) *abi.SignatureRegistry {
+	signatures := abi.NewSignatureRegistry()
+	err := signatures.LoadFile(signatureFile)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Infof("no custom signatures to load | file: %s", signatureFile)
+		return signatures
+	}
+	if err != nil { // not fatal, the built-in ones are still used
+		logger.Errorf("load custom signatures fail | file: %s, err: %s", signatureFile, err.Error())
+		return signatures
+	}
+	logger.Infof("custom signatures loaded | file: %s, signatures: %d", signatureFile, signatures.Size())
+	return signatures
 }
 
 type Handler struct {
 	parser parser.Parser
-	logger logging.Logger
+	// the stats of chain endpoints, nil if not supported by the chain accesser
+	stats ethereum.StatsReporter
+	// the fallback of method names, for the contracts without registered ABIs
+	signatures *abi.SignatureRegistry
+	logger     logging.Logger
 }
 
 func (this *Handler) GetBlockNumber(w http.ResponseWriter, r *http.Request) {
@@ -81,15 +254,24 @@ func (this *Handler) GetTransactions(w http.ResponseWriter, r *http.Request) {
 	var params protocol.GetTransactionsParams
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		this.logger.Errorf("unmarl params fail | err: %s", err.Error())
-		respondWithError(w, protocol.ErrCodeUnmarl, protocol.ErrMsgUnmarl, "")
+		respondWithParamsError(w, err, req.RequestId)
+		return
+	}
+	if params.Address == nil { // missing
+		respondWithError(w, protocol.ErrCodeInvalidAddress, protocol.ErrMsgInvalidAddress, req.RequestId)
 		return
 	}
 
-	transactions := this.parser.GetTransactions(params.Address)
+	var transactions []ethereum.Transaction
+	if params.Finalized {
+		transactions = this.parser.GetFinalizedTransactions(*params.Address)
+	} else {
+		transactions = this.parser.GetTransactions(*params.Address)
+	}
 
 	resp := protocol.JsonResponse{
 		RequestId: req.RequestId,
-		Result:    transactions,
+		Result:    protocol.NewTransactions(transactions, this.signatures),
 	}
 
 	json.NewEncoder(w).Encode(resp)
@@ -107,11 +289,50 @@ func (this *Handler) Subscribe(w http.ResponseWriter, r *http.Request) {
 	var params protocol.SubscribeParams
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		this.logger.Errorf("unmarl params fail | err: %s", err.Error())
+		respondWithParamsError(w, err, req.RequestId)
+		return
+	}
+	if params.Address == nil { // missing
+		respondWithError(w, protocol.ErrCodeInvalidAddress, protocol.ErrMsgInvalidAddress, req.RequestId)
+		return
+	}
+
+	var success bool
+	if params.FromBlock != nil {
+		success = this.parser.SubscribeFrom(*params.Address, *params.FromBlock)
+	} else {
+		success = this.parser.Subscribe(*params.Address)
+	}
+
+	resp := protocol.JsonResponse{
+		RequestId: req.RequestId,
+		Result:    success,
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (this *Handler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+
+	var req protocol.JsonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		this.logger.Errorf("decode request fail | err: %s", err.Error())
 		respondWithError(w, protocol.ErrCodeUnmarl, protocol.ErrMsgUnmarl, "")
 		return
 	}
 
-	success := this.parser.Subscribe(params.Address)
+	var params protocol.UnsubscribeParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		this.logger.Errorf("unmarl params fail | err: %s", err.Error())
+		respondWithParamsError(w, err, req.RequestId)
+		return
+	}
+	if params.Address == nil { // missing
+		respondWithError(w, protocol.ErrCodeInvalidAddress, protocol.ErrMsgInvalidAddress, req.RequestId)
+		return
+	}
+
+	success := this.parser.Unsubscribe(*params.Address)
 
 	resp := protocol.JsonResponse{
 		RequestId: req.RequestId,
@@ -121,6 +342,236 @@ func (this *Handler) Subscribe(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+func (this *Handler) GetBackfillProgress(w http.ResponseWriter, r *http.Request) {
+
+	var req protocol.JsonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		this.logger.Errorf("decode request fail | err: %s", err.Error())
+		respondWithError(w, protocol.ErrCodeUnmarl, protocol.ErrMsgUnmarl, "")
+		return
+	}
+
+	var params protocol.GetBackfillProgressParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		this.logger.Errorf("unmarl params fail | err: %s", err.Error())
+		respondWithParamsError(w, err, req.RequestId)
+		return
+	}
+	if params.Address == nil { // missing
+		respondWithError(w, protocol.ErrCodeInvalidAddress, protocol.ErrMsgInvalidAddress, req.RequestId)
+		return
+	}
+
+	progress, ok := this.parser.GetBackfillProgress(*params.Address)
+	if !ok {
+		respondWithError(w, protocol.ErrCodeBackfillNotFound, protocol.ErrMsgBackfillNotFound, req.RequestId)
+		return
+	}
+
+	resp := protocol.JsonResponse{
+		RequestId: req.RequestId,
+		Result:    progress,
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (this *Handler) GetSyncStatus(w http.ResponseWriter, r *http.Request) {
+
+	var req protocol.JsonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		this.logger.Errorf("decode request fail | err: %s", err.Error())
+		respondWithError(w, protocol.ErrCodeUnmarl, protocol.ErrMsgUnmarl, "")
+		return
+	}
+
+	var params protocol.GetSyncStatusParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		this.logger.Errorf("unmarl params fail | err: %s", err.Error())
+		respondWithParamsError(w, err, req.RequestId)
+		return
+	}
+	if params.Address == nil { // missing
+		respondWithError(w, protocol.ErrCodeInvalidAddress, protocol.ErrMsgInvalidAddress, req.RequestId)
+		return
+	}
+
+	status, ok := this.parser.GetSyncStatus(*params.Address)
+	if !ok {
+		respondWithError(w, protocol.ErrCodeSyncStatusNotFound, protocol.ErrMsgSyncStatusNotFound, req.RequestId)
+		return
+	}
+
+	resp := protocol.JsonResponse{
+		RequestId: req.RequestId,
+		Result:    status,
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (this *Handler) GetTokenTransfers(w http.ResponseWriter, r *http.Request) {
+
+	var req protocol.JsonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		this.logger.Errorf("decode request fail | err: %s", err.Error())
+		respondWithError(w, protocol.ErrCodeUnmarl, protocol.ErrMsgUnmarl, "")
+		return
+	}
+
+	var params protocol.GetTokenTransfersParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		this.logger.Errorf("unmarl params fail | err: %s", err.Error())
+		respondWithParamsError(w, err, req.RequestId)
+		return
+	}
+	if params.Address == nil { // missing
+		respondWithError(w, protocol.ErrCodeInvalidAddress, protocol.ErrMsgInvalidAddress, req.RequestId)
+		return
+	}
+
+	transfers := this.parser.GetTokenTransfers(*params.Address)
+
+	resp := protocol.JsonResponse{
+		RequestId: req.RequestId,
+		Result:    protocol.NewTokenTransfers(transfers),
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (this *Handler) GetAccount(w http.ResponseWriter, r *http.Request) {
+
+	var req protocol.JsonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		this.logger.Errorf("decode request fail | err: %s", err.Error())
+		respondWithError(w, protocol.ErrCodeUnmarl, protocol.ErrMsgUnmarl, "")
+		return
+	}
+
+	var params protocol.GetAccountParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		this.logger.Errorf("unmarl params fail | err: %s", err.Error())
+		respondWithParamsError(w, err, req.RequestId)
+		return
+	}
+	if params.Address == nil { // missing
+		respondWithError(w, protocol.ErrCodeInvalidAddress, protocol.ErrMsgInvalidAddress, req.RequestId)
+		return
+	}
+
+	state, ok := this.parser.GetAccountState(*params.Address)
+	if !ok {
+		respondWithError(w, protocol.ErrCodeAccountNotFound, protocol.ErrMsgAccountNotFound, req.RequestId)
+		return
+	}
+
+	resp := protocol.JsonResponse{
+		RequestId: req.RequestId,
+		Result:    protocol.NewAccount(state),
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (this *Handler) GetPendingTransactions(w http.ResponseWriter, r *http.Request) {
+
+	var req protocol.JsonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		this.logger.Errorf("decode request fail | err: %s", err.Error())
+		respondWithError(w, protocol.ErrCodeUnmarl, protocol.ErrMsgUnmarl, "")
+		return
+	}
+
+	var params protocol.GetPendingTransactionsParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		this.logger.Errorf("unmarl params fail | err: %s", err.Error())
+		respondWithParamsError(w, err, req.RequestId)
+		return
+	}
+	if params.Address == nil { // missing
+		respondWithError(w, protocol.ErrCodeInvalidAddress, protocol.ErrMsgInvalidAddress, req.RequestId)
+		return
+	}
+
+	resp := protocol.JsonResponse{
+		RequestId: req.RequestId,
+		Result:    this.parser.GetPendingTransactions(*params.Address),
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (this *Handler) RegisterABI(w http.ResponseWriter, r *http.Request) {
+
+	var req protocol.JsonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		this.logger.Errorf("decode request fail | err: %s", err.Error())
+		respondWithError(w, protocol.ErrCodeUnmarl, protocol.ErrMsgUnmarl, "")
+		return
+	}
+
+	var params protocol.RegisterABIParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		this.logger.Errorf("unmarl params fail | err: %s", err.Error())
+		respondWithParamsError(w, err, req.RequestId)
+		return
+	}
+	if params.Address == nil { // missing
+		respondWithError(w, protocol.ErrCodeInvalidAddress, protocol.ErrMsgInvalidAddress, req.RequestId)
+		return
+	}
+
+	var contractABI *abi.ABI
+	if len(params.ABI) != 0 && string(params.ABI) != "null" {
+		var err error
+		if contractABI, err = abi.Parse(params.ABI); err != nil {
+			this.logger.Errorf("parse abi fail | address: %s, err: %s", params.Address.Hex(), err.Error())
+			respondWithError(w, protocol.ErrCodeInvalidABI, protocol.ErrMsgInvalidABI, req.RequestId)
+			return
+		}
+	}
+	this.parser.RegisterABI(*params.Address, contractABI)
+
+	resp := protocol.JsonResponse{
+		RequestId: req.RequestId,
+		Result:    true,
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (this *Handler) GetEndpointStats(w http.ResponseWriter, r *http.Request) {
+
+	var req protocol.JsonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		this.logger.Errorf("decode request fail | err: %s", err.Error())
+		respondWithError(w, protocol.ErrCodeUnmarl, protocol.ErrMsgUnmarl, "")
+		return
+	}
+
+	stats := []ethereum.EndpointStats{}
+	if this.stats != nil {
+		stats = this.stats.Stats()
+	}
+
+	resp := protocol.JsonResponse{
+		RequestId: req.RequestId,
+		Result:    stats,
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// respondWithParamsError respond the specific error for invalid address, and the general one for others
+func respondWithParamsError(w http.ResponseWriter, err error, id string) {
+	if errors.Is(err, ethereum.ErrInvalidAddress) {
+		respondWithError(w, protocol.ErrCodeInvalidAddress, protocol.ErrMsgInvalidAddress, id)
+		return
+	}
+	respondWithError(w, protocol.ErrCodeUnmarl, protocol.ErrMsgUnmarl, "")
+}
+
 func respondWithError(w http.ResponseWriter, code int, message string, id string) {
 	response := protocol.JsonResponse{
 		Error:     protocol.Error{Code: code, Message: message},
@@ -1,29 +1,167 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/big"
 	"net/http"
 	"strconv"
+	"sync"
 
 	"time"
 
 	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/websocket"
+)
+
+// the interval to push new heads to the WebSocket subscribers
+var headInterval = time.Second
+
+const (
+	// the seconds a pending transaction stays in the mempool before it's mined
+	pendingDelay = 3
+	// the max number of pending transactions returned by one poll
+	maxFilterChanges = 10
+)
+
+// the pending transaction filters, from the filter id to the last polled time in unix seconds
+var (
+	filters      = map[string]int64{}
+	lastFilterId = 0
+	filterLock   sync.Mutex
 )
 
 func main() {
 	http.HandleFunc("/rpc", rpcHandler)
+	http.HandleFunc("/ws", wsHandler)
 	fmt.Println("Starting server on :8080...")
 	http.ListenAndServe(":8080", nil)
 }
 
 func rpcHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, -32700, "Parse error", nil)
+		return
+	}
+
+	// batch call
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var reqs []ethereum.RPCRequest
+		if err := json.Unmarshal(trimmed, &reqs); err != nil {
+			respondWithError(w, -32700, "Parse error", nil)
+			return
+		}
+		if len(reqs) == 0 {
+			respondWithError(w, -32600, "Invalid Request", nil)
+			return
+		}
+		responses := make([]ethereum.RPCResponse, 0, len(reqs))
+		for _, req := range reqs {
+			responses = append(responses, handleRequest(req))
+		}
+		json.NewEncoder(w).Encode(responses)
+		return
+	}
+
 	var req ethereum.RPCRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		respondWithError(w, -32700, "Parse error", nil)
 		return
 	}
+	json.NewEncoder(w).Encode(handleRequest(req))
+}
+
+// wsHandler serve JSON RPC over WebSocket. The socket is closed after `drop_after` heads are pushed, if it's set.
+func wsHandler(w http.ResponseWriter, r *http.Request) {
+	dropAfter, _ := strconv.Atoi(r.URL.Query().Get("drop_after"))
+
+	conn, err := websocket.Accept(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	writeLock := sync.Mutex{}
+	write := func(v interface{}) error {
+		writeLock.Lock()
+		defer writeLock.Unlock()
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(raw)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	subscriptionId := 0
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var req ethereum.RPCRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			write(ethereum.RPCResponse{Jsonrpc: "2.0", Error: &ethereum.RPCError{Code: -32700, Message: "Parse error"}})
+			continue
+		}
+		if req.Method != "eth_subscribe" {
+			write(handleRequest(req))
+			continue
+		}
+
+		var params []string
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params) == 0 || params[0] != "newHeads" {
+			write(ethereum.RPCResponse{Jsonrpc: "2.0", ID: req.ID, Error: &ethereum.RPCError{Code: -32602, Message: "Invalid params"}})
+			continue
+		}
+		subscriptionId++
+		id := fmt.Sprintf("0x%x", subscriptionId)
+		write(ethereum.RPCResponse{Jsonrpc: "2.0", ID: req.ID, Result: json.RawMessage(strconv.Quote(id))})
+		go pushHeads(conn, write, id, dropAfter, done)
+	}
+}
+
+// pushHeads push a new head in every `headInterval`. The block number is the unix time, the same as `eth_blockNumber`.
+func pushHeads(conn *websocket.Conn, write func(interface{}) error, subscriptionId string, dropAfter int, done <-chan struct{}) {
+	ticker := time.NewTicker(headInterval)
+	defer ticker.Stop()
+
+	for pushed := 0; ; pushed++ {
+		if dropAfter > 0 && pushed >= dropAfter {
+			conn.Close()
+			return
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		bn := time.Now().Unix()
+		notification := map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  "eth_subscription",
+			"params": map[string]interface{}{
+				"subscription": subscriptionId,
+				"result": ethereum.Block{
+					Number:     fmt.Sprintf("0x%x", bn),
+					Hash:       fmt.Sprintf("0x%064x", bn),
+					ParentHash: fmt.Sprintf("0x%064x", bn-1),
+					Timestamp:  fmt.Sprintf("0x%x", bn),
+				},
+			},
+		}
+		if err := write(notification); err != nil {
+			return
+		}
+	}
+}
 
+func handleRequest(req ethereum.RPCRequest) ethereum.RPCResponse {
 	var result interface{}
 	var err *ethereum.RPCError
 
@@ -32,6 +170,24 @@ func rpcHandler(w http.ResponseWriter, r *http.Request) {
 		result, err = getBlockNumber(req.Params)
 	case "trace_filter":
 		result, err = traceFilter(req.Params)
+	case "eth_getBlockByNumber":
+		result, err = getBlockByNumber(req.Params)
+	case "eth_getTransactionReceipt":
+		result, err = getTransactionReceipt(req.Params)
+	case "eth_getLogs":
+		result, err = getLogs(req.Params)
+	case "eth_getBalance":
+		result, err = getBalance(req.Params)
+	case "eth_getTransactionCount":
+		result, err = getTransactionCount(req.Params)
+	case "eth_getTransactionByHash":
+		result, err = getTransactionByHash(req.Params)
+	case "eth_newPendingTransactionFilter":
+		result, err = newPendingTransactionFilter(req.Params)
+	case "eth_getFilterChanges":
+		result, err = getFilterChanges(req.Params)
+	case "eth_uninstallFilter":
+		result, err = uninstallFilter(req.Params)
 	default:
 		err = &ethereum.RPCError{Code: -32601, Message: "Method not found"}
 	}
@@ -43,12 +199,17 @@ func rpcHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		response.Error = err
-	} else {
-		response.Result = result
+		return response
 	}
-
-	json.NewEncoder(w).Encode(response)
+	raw, marshalErr := json.Marshal(result)
+	if marshalErr != nil {
+		response.Error = &ethereum.RPCError{Code: -32603, Message: "Internal error"}
+		return response
+	}
+	response.Result = raw
+	return response
 }
+
 func getBlockNumber(params json.RawMessage) (interface{}, *ethereum.RPCError) {
 	result := fmt.Sprintf("%d", time.Now().Unix())
 	return result, nil
@@ -70,22 +231,291 @@ func traceFilter(params json.RawMessage) (interface{}, *ethereum.RPCError) {
 		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
 	}
 
+	if len(req) == 0 {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+
 	bh := fmt.Sprintf("%d", time.Now().UnixNano())
 
-	result := []ethereum.Transaction{
-		{
-			BlockNumber:     convertBN(req[0].FromBlock),
-			TransactionHash: bh,
-		},
-		{
-			BlockNumber:     convertBN(req[0].ToBlock),
-			TransactionHash: bh,
-		},
+	// 2 transactions sent from each of the addresses
+	addresses := req[0].FromAddress
+	if len(addresses) == 0 {
+		addresses = []string{""}
+	}
+	result := make([]ethereum.Transaction, 0, len(addresses)*2)
+	for _, addr := range addresses {
+		result = append(result,
+			ethereum.Transaction{
+				Action:          ethereum.Action{From: addr},
+				BlockNumber:     convertBN(req[0].FromBlock),
+				TransactionHash: bh,
+			},
+			ethereum.Transaction{
+				Action:          ethereum.Action{From: addr},
+				BlockNumber:     convertBN(req[0].ToBlock),
+				TransactionHash: bh,
+			},
+		)
+	}
+
+	return result, nil
+}
+
+// getBlockByNumber returns a block header whose hashes are derived from the block number,
+// so the mock chain is stable (without reorganization)
+func getBlockByNumber(params json.RawMessage) (interface{}, *ethereum.RPCError) {
+	req := []interface{}{}
+
+	if err := json.Unmarshal(params, &req); err != nil || len(req) == 0 {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	bnString, ok := req[0].(string)
+	if !ok {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	bn, err := strconv.ParseInt(bnString, 0, 64)
+	if err != nil {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+
+	result := ethereum.Block{
+		Number:     fmt.Sprintf("0x%x", bn),
+		Hash:       fmt.Sprintf("0x%064x", bn),
+		ParentHash: fmt.Sprintf("0x%064x", bn-1),
+		Timestamp:  fmt.Sprintf("0x%x", bn),
 	}
 
+	// the 2nd param `true` means the full transactions are returned
+	if len(req) > 1 {
+		if full, _ := req[1].(bool); full {
+			return ethereum.BlockWithTransactions{
+				Block:        result,
+				Transactions: blockTransactions(bn),
+			}, nil
+		}
+	}
 	return result, nil
 }
 
+// blockTransactions returns 2 transactions of each block: 0x..01 -> 0x..02, and 0x..02 -> 0x..03.
+// The hash is derived from the block number and the index, so the receipt can be built from it.
+func blockTransactions(bn int64) []ethereum.BlockTransaction {
+	transactions := make([]ethereum.BlockTransaction, 0, 2)
+	for i := int64(0); i < 2; i++ {
+		transactions = append(transactions, ethereum.BlockTransaction{
+			Hash:             fmt.Sprintf("0x%064x", bn*10+i),
+			BlockHash:        fmt.Sprintf("0x%064x", bn),
+			BlockNumber:      fmt.Sprintf("0x%x", bn),
+			TransactionIndex: fmt.Sprintf("0x%x", i),
+			From:             fmt.Sprintf("0x%040x", i+1),
+			To:               fmt.Sprintf("0x%040x", i+2),
+			Gas:              21000,
+			GasPrice:         ethereum.NewBigInt(big.NewInt(1e9)),
+			Value:            ethereum.NewBigInt(big.NewInt(1)),
+			Input:            "0x",
+		})
+	}
+	return transactions
+}
+
+// getTransactionReceipt returns the receipt of the transactions built by `blockTransactions`.
+// The 2nd transaction of each block fails.
+func getTransactionReceipt(params json.RawMessage) (interface{}, *ethereum.RPCError) {
+	req := []string{}
+
+	if err := json.Unmarshal(params, &req); err != nil || len(req) == 0 {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	n, err := strconv.ParseInt(req[0], 0, 64)
+	if err != nil {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+
+	bn, i := n/10, n%10
+	status := "0x1"
+	if i == 1 {
+		status = "0x0"
+	}
+	result := ethereum.Receipt{
+		TransactionHash:   req[0],
+		BlockHash:         fmt.Sprintf("0x%064x", bn),
+		BlockNumber:       fmt.Sprintf("0x%x", bn),
+		From:              fmt.Sprintf("0x%040x", i+1),
+		To:                fmt.Sprintf("0x%040x", i+2),
+		GasUsed:           21000,
+		EffectiveGasPrice: ethereum.NewBigInt(big.NewInt(1e9)),
+		Status:            status,
+		Logs:              []ethereum.Log{},
+	}
+	return result, nil
+}
+
+// getLogs returns one ERC-20 transfer log in `toBlock` for each address in the topics filter.
+// The address is the sender if it's in the 2nd topic, or the receiver if it's in the 3rd one.
+func getLogs(params json.RawMessage) (interface{}, *ethereum.RPCError) {
+	req := []ethereum.JsonRpcLogFilterParams{}
+
+	if err := json.Unmarshal(params, &req); err != nil || len(req) == 0 {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	bn, err := strconv.ParseInt(req[0].ToBlock, 0, 64)
+	if err != nil {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+
+	result := []ethereum.Log{}
+	for position := 1; position <= 2 && position < len(req[0].Topics); position++ {
+		for _, topic := range req[0].Topics[position] {
+			topics := []string{ethereum.TransferTopic, ethereum.AddressTopic("0xbb"), topic}
+			if position == 1 {
+				topics = []string{ethereum.TransferTopic, topic, ethereum.AddressTopic("0xaa")}
+			}
+			// unique in the block, for both of the positions
+			index := position*10 + len(result)
+			result = append(result, ethereum.Log{
+				Address:          fmt.Sprintf("0x%040x", 0xc0ffee),
+				Topics:           topics,
+				Data:             fmt.Sprintf("0x%064x", 1000),
+				BlockNumber:      fmt.Sprintf("0x%x", bn),
+				BlockHash:        fmt.Sprintf("0x%064x", bn),
+				TransactionHash:  fmt.Sprintf("0x%064x", bn*100+int64(index)),
+				TransactionIndex: fmt.Sprintf("0x%x", index),
+				LogIndex:         fmt.Sprintf("0x%x", index),
+			})
+		}
+	}
+	return result, nil
+}
+
+// getBalance returns the balance derived from the address: the last byte of it in ether, plus 0.5 ether
+func getBalance(params json.RawMessage) (interface{}, *ethereum.RPCError) {
+	addr, err := parseAccountParams(params)
+	if err != nil {
+		return nil, err
+	}
+	balance := new(big.Int).Mul(big.NewInt(int64(addr[ethereum.AddressLength-1])*2+1), big.NewInt(5e17))
+	return ethereum.NewBigInt(balance), nil
+}
+
+// getTransactionCount returns the nonce derived from the address: the last byte of it
+func getTransactionCount(params json.RawMessage) (interface{}, *ethereum.RPCError) {
+	addr, err := parseAccountParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return ethereum.Quantity(addr[ethereum.AddressLength-1]), nil
+}
+
+// parseAccountParams parse the params `[address, block]` of the account queries
+func parseAccountParams(params json.RawMessage) (ethereum.Address, *ethereum.RPCError) {
+	req := []string{}
+	if err := json.Unmarshal(params, &req); err != nil || len(req) != 2 {
+		return ethereum.Address{}, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	addr, err := ethereum.ParseAddress(req[0])
+	if err != nil {
+		return ethereum.Address{}, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	return addr, nil
+}
+
+// getTransactionByHash returns the transactions built by `blockTransactions`, and the pending ones built by `getFilterChanges`.
+// A pending transaction is mined in the block of its hash, except the ones of every 5th block, which are dropped.
+func getTransactionByHash(params json.RawMessage) (interface{}, *ethereum.RPCError) {
+	req := []string{}
+
+	if err := json.Unmarshal(params, &req); err != nil || len(req) == 0 {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	n, err := strconv.ParseInt(req[0], 0, 64)
+	if err != nil {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+
+	bn, i := n/10, n%10
+	if i < 2 {
+		return blockTransactions(bn)[i], nil
+	}
+	if i != 9 {
+		return nil, nil
+	}
+
+	mined := time.Now().Unix() >= bn
+	if mined && bn%5 == 0 {
+		return nil, nil
+	}
+	result := ethereum.BlockTransaction{
+		Hash:     req[0],
+		From:     fmt.Sprintf("0x%040x", i+1),
+		To:       fmt.Sprintf("0x%040x", i+2),
+		Gas:      21000,
+		GasPrice: ethereum.NewBigInt(big.NewInt(1e9)),
+		Value:    ethereum.NewBigInt(big.NewInt(1e18)),
+		Input:    "0x",
+	}
+	if mined {
+		result.BlockHash = fmt.Sprintf("0x%064x", bn)
+		result.BlockNumber = fmt.Sprintf("0x%x", bn)
+		result.TransactionIndex = fmt.Sprintf("0x%x", i)
+	}
+	return result, nil
+}
+
+// newPendingTransactionFilter install a filter returning the hashes only, as the old nodes
+func newPendingTransactionFilter(params json.RawMessage) (interface{}, *ethereum.RPCError) {
+	req := []interface{}{}
+	if err := json.Unmarshal(params, &req); err != nil || len(req) > 0 {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "too many arguments, want at most 0"}
+	}
+
+	filterLock.Lock()
+	defer filterLock.Unlock()
+	lastFilterId++
+	filterId := fmt.Sprintf("0x%x", lastFilterId)
+	filters[filterId] = time.Now().Unix()
+	return filterId, nil
+}
+
+// getFilterChanges returns one pending transaction entering the mempool in each second since last poll.
+// The hash is derived from the block it's mined in, so it can be got by `getTransactionByHash`.
+func getFilterChanges(params json.RawMessage) (interface{}, *ethereum.RPCError) {
+	req := []string{}
+	if err := json.Unmarshal(params, &req); err != nil || len(req) == 0 {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+
+	filterLock.Lock()
+	defer filterLock.Unlock()
+	last, ok := filters[req[0]]
+	if !ok {
+		return nil, &ethereum.RPCError{Code: -32000, Message: "filter not found"}
+	}
+	now := time.Now().Unix()
+	if now-last > maxFilterChanges {
+		last = now - maxFilterChanges
+	}
+	filters[req[0]] = now
+
+	result := []string{}
+	for t := last + 1; t <= now; t++ {
+		result = append(result, fmt.Sprintf("0x%064x", (t+pendingDelay)*10+9))
+	}
+	return result, nil
+}
+
+func uninstallFilter(params json.RawMessage) (interface{}, *ethereum.RPCError) {
+	req := []string{}
+	if err := json.Unmarshal(params, &req); err != nil || len(req) == 0 {
+		return nil, &ethereum.RPCError{Code: -32602, Message: "Invalid params"}
+	}
+
+	filterLock.Lock()
+	defer filterLock.Unlock()
+	_, ok := filters[req[0]]
+	delete(filters, req[0])
+	return ok, nil
+}
+
 func respondWithError(w http.ResponseWriter, code int, message string, id interface{}) {
 	response := ethereum.RPCResponse{
 		Jsonrpc: "2.0",
@@ -0,0 +1,240 @@
+// Package abi decodes the calldata and return values of contract calls, by the contract ABI in JSON.
+// Only the functions are decoded. The events, errors, constructor and the others in the ABI are ignored.
+package abi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// the bytes of a function selector
+const selectorSize = 4
+
+var (
+	ErrInvalidABI  = errors.New("invalid abi")
+	ErrInvalidType = errors.New("invalid abi type")
+	// the calldata or return value can't be decoded by the method
+	ErrInvalidData    = errors.New("invalid abi data")
+	ErrMethodNotFound = errors.New("method not found")
+)
+
+// Argument is an input or output of a function in the ABI JSON
+type Argument struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// the fields of the `tuple` types
+	Components []Argument `json:"components,omitempty"`
+}
+
+// entry is an item of the ABI JSON
+type entry struct {
+	// `function`, `event`, `error`, `constructor`, `fallback` or `receive`. It's `function` if it's empty.
+	Type    string     `json:"type"`
+	Name    string     `json:"name"`
+	Inputs  []Argument `json:"inputs"`
+	Outputs []Argument `json:"outputs"`
+}
+
+// Method is a function of a contract
+type Method struct {
+	Name string
+	// the canonical signature, e.g. `transfer(address,uint256)`
+	Signature string
+	// the first 4 bytes of the keccak256 hash of the signature, in hex with 0x prefix
+	Selector    string
+	Inputs      []Argument
+	Outputs     []Argument
+	inputTypes  []*Type
+	outputTypes []*Type
+}
+
+// NewMethod parse the types of a function
+func NewMethod(name string, inputs, outputs []Argument) (*Method, error) {
+
+	inputTypes, err := newTypes(inputs)
+	if err != nil {
+		return nil, err
+	}
+	outputTypes, err := newTypes(outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(inputTypes))
+	for _, t := range inputTypes {
+		names = append(names, t.String())
+	}
+	signature := fmt.Sprintf("%s(%s)", name, strings.Join(names, ","))
+	return &Method{
+		Name:        name,
+		Signature:   signature,
+		Selector:    Selector(signature),
+		Inputs:      inputs,
+		Outputs:     outputs,
+		inputTypes:  inputTypes,
+		outputTypes: outputTypes,
+	}, nil
+}
+
+func newTypes(arguments []Argument) ([]*Type, error) {
+	types := make([]*Type, 0, len(arguments))
+	for _, argument := range arguments {
+		t, err := NewType(argument.Type, argument.Components)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// DecodeInput decode the calldata of a call to the method, including the selector
+func (this *Method) DecodeInput(input string) ([]Param, error) {
+
+	data, err := decodeHex(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < selectorSize || "0x"+hex.EncodeToString(data[:selectorSize]) != this.Selector {
+		return nil, fmt.Errorf("%w: selector of %s mismatch", ErrInvalidData, this.Signature)
+	}
+	return decodeArguments(this.inputTypes, argumentNames(this.Inputs), data[selectorSize:])
+}
+
+// DecodeOutput decode the return value of a call to the method
+func (this *Method) DecodeOutput(output string) ([]Param, error) {
+
+	data, err := decodeHex(output)
+	if err != nil {
+		return nil, err
+	}
+	return decodeArguments(this.outputTypes, argumentNames(this.Outputs), data)
+}
+
+func argumentNames(arguments []Argument) []string {
+	names := make([]string, 0, len(arguments))
+	for _, argument := range arguments {
+		names = append(names, argument.Name)
+	}
+	return names
+}
+
+// ABI is the functions of a contract, keyed by selector
+type ABI struct {
+	methods map[string]*Method
+}
+
+// Parse parse the ABI JSON of a contract, e.g. the output of `solc --abi`
+func Parse(data []byte) (*ABI, error) {
+
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidABI, err.Error())
+	}
+
+	abi := &ABI{methods: make(map[string]*Method)}
+	for _, e := range entries {
+		if e.Type != "" && e.Type != "function" {
+			continue
+		}
+		if e.Name == "" {
+			return nil, fmt.Errorf("%w: function without name", ErrInvalidABI)
+		}
+		method, err := NewMethod(e.Name, e.Inputs, e.Outputs)
+		if err != nil {
+			return nil, fmt.Errorf("%w: function %s: %s", ErrInvalidABI, e.Name, err.Error())
+		}
+		if old, ok := abi.methods[method.Selector]; ok {
+			return nil, fmt.Errorf("%w: selector %s of %s is taken by %s", ErrInvalidABI, method.Selector, method.Signature, old.Signature)
+		}
+		abi.methods[method.Selector] = method
+	}
+	return abi, nil
+}
+
+// Methods return the functions, sorted by signature
+func (this *ABI) Methods() []*Method {
+	methods := make([]*Method, 0, len(this.methods))
+	for _, method := range this.methods {
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].Signature < methods[j].Signature
+	})
+	return methods
+}
+
+// MethodBySelector find the function by the selector (in hex with 0x prefix), or the calldata starting with it
+func (this *ABI) MethodBySelector(selector string) (*Method, bool) {
+	if len(selector) < 2+selectorSize*2 {
+		return nil, false
+	}
+	method, ok := this.methods[strings.ToLower(selector[:2+selectorSize*2])]
+	return method, ok
+}
+
+// DecodedCall is a decoded contract call
+type DecodedCall struct {
+	Method    string  `json:"method"`
+	Signature string  `json:"signature"`
+	Inputs    []Param `json:"inputs"`
+	// the return values, empty if the call fails or returns nothing
+	Outputs []Param `json:"outputs,omitempty"`
+}
+
+// DecodeCall decode the calldata of a call, and its return value if it's not empty.
+// `ErrMethodNotFound` is returned if the selector is not in the ABI.
+func (this *ABI) DecodeCall(input, output string) (*DecodedCall, error) {
+
+	method, ok := this.MethodBySelector(input)
+	if !ok {
+		return nil, ErrMethodNotFound
+	}
+	inputs, err := method.DecodeInput(input)
+	if err != nil {
+		return nil, err
+	}
+	call := &DecodedCall{
+		Method:    method.Name,
+		Signature: method.Signature,
+		Inputs:    inputs,
+	}
+	if output == "" || output == "0x" {
+		return call, nil
+	}
+	if call.Outputs, err = method.DecodeOutput(output); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// Selector return the function selector of a canonical signature, in hex with 0x prefix
+func Selector(signature string) string {
+	return "0x" + hex.EncodeToString(keccak256([]byte(signature))[:selectorSize])
+}
+
+// Topic return the event topic of a canonical signature, in hex with 0x prefix
+func Topic(signature string) string {
+	return "0x" + hex.EncodeToString(keccak256([]byte(signature)))
+}
+
+func keccak256(data []byte) []byte {
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write(data)
+	return hasher.Sum(nil)
+}
+
+func decodeHex(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidData, err.Error())
+	}
+	return data, nil
+}
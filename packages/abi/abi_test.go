@@ -0,0 +1,243 @@
+package abi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testABI = `[
+	{"type": "function", "name": "transfer", "inputs": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}], "outputs": [{"name": "", "type": "bool"}]},
+	{"type": "function", "name": "f", "inputs": [{"name": "a", "type": "uint"}, {"name": "b", "type": "uint32[]"}, {"name": "c", "type": "bytes10"}, {"name": "d", "type": "bytes"}], "outputs": []},
+	{"name": "g", "inputs": [{"name": "a", "type": "uint256[][]"}, {"name": "b", "type": "string[]"}], "outputs": []},
+	{"type": "function", "name": "h", "inputs": [
+		{"name": "order", "type": "tuple", "components": [{"name": "maker", "type": "address"}, {"name": "amounts", "type": "uint256[]"}, {"name": "memo", "type": "string"}]},
+		{"name": "delta", "type": "int8"},
+		{"name": "flag", "type": "bool"},
+		{"name": "keys", "type": "bytes32[2]"}
+	], "outputs": []},
+	{"type": "event", "name": "Transfer", "inputs": [{"name": "from", "type": "address", "indexed": true}], "anonymous": false},
+	{"type": "constructor", "inputs": []}
+]`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		// the signatures of the methods
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "normal case 1 - functions only",
+			data: testABI,
+			want: []string{"f(uint256,uint32[],bytes10,bytes)", "g(uint256[][],string[])", "h((address,uint256[],string),int8,bool,bytes32[2])", "transfer(address,uint256)"},
+		},
+		{
+			name: "normal case 2 - empty",
+			data: `[]`,
+			want: []string{},
+		},
+		{
+			name:    "failure case 1 - invalid json",
+			data:    `{"type": "function"}`,
+			wantErr: true,
+		},
+		{
+			name:    "failure case 2 - invalid type",
+			data:    `[{"type": "function", "name": "f", "inputs": [{"name": "a", "type": "uint7"}]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "failure case 3 - duplicate function",
+			data:    `[{"type": "function", "name": "f", "inputs": [{"type": "uint"}]}, {"type": "function", "name": "f", "inputs": [{"type": "uint256"}]}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidABI), err)
+				return
+			}
+			assert.NoError(t, err)
+			signatures := []string{}
+			for _, method := range got.Methods() {
+				signatures = append(signatures, method.Signature)
+			}
+			assert.Equal(t, tt.want, signatures)
+		})
+	}
+}
+
+func TestSelector(t *testing.T) {
+	assert.Equal(t, "0xa9059cbb", Selector("transfer(address,uint256)"))
+	assert.Equal(t, "0x8be65246", Selector("f(uint256,uint32[],bytes10,bytes)"))
+	assert.Equal(t, "0x2289b18c", Selector("g(uint256[][],string[])"))
+}
+
+func TestABI_DecodeCall(t *testing.T) {
+	contract, err := Parse([]byte(testABI))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name      string
+		input     string
+		output    string
+		want      *DecodedCall
+		wantErrIs error
+	}{
+		{
+			name: "normal case 1 - static with output",
+			input: "0xA9059CBB" +
+				"000000000000000000000000b0b0000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000de0b6b3a7640000",
+			output: "0x0000000000000000000000000000000000000000000000000000000000000001",
+			want: &DecodedCall{
+				Method:    "transfer",
+				Signature: "transfer(address,uint256)",
+				Inputs: []Param{
+					{Name: "to", Type: "address", Value: "0xb0b0000000000000000000000000000000000001"},
+					{Name: "amount", Type: "uint256", Value: "1000000000000000000"},
+				},
+				Outputs: []Param{{Name: "", Type: "bool", Value: true}},
+			},
+		},
+		{
+			name: "normal case 2 - dynamic",
+			input: "0x8be65246" +
+				"0000000000000000000000000000000000000000000000000000000000000123" +
+				"0000000000000000000000000000000000000000000000000000000000000080" +
+				"3132333435363738393000000000000000000000000000000000000000000000" +
+				"00000000000000000000000000000000000000000000000000000000000000e0" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000456" +
+				"0000000000000000000000000000000000000000000000000000000000000789" +
+				"000000000000000000000000000000000000000000000000000000000000000d" +
+				"48656c6c6f2c20776f726c642100000000000000000000000000000000000000",
+			output: "0x",
+			want: &DecodedCall{
+				Method:    "f",
+				Signature: "f(uint256,uint32[],bytes10,bytes)",
+				Inputs: []Param{
+					{Name: "a", Type: "uint256", Value: "291"},
+					{Name: "b", Type: "uint32[]", Value: []interface{}{"1110", "1929"}},
+					{Name: "c", Type: "bytes10", Value: "0x31323334353637383930"},
+					{Name: "d", Type: "bytes", Value: "0x48656c6c6f2c20776f726c6421"},
+				},
+			},
+		},
+		{
+			name: "normal case 3 - nested arrays",
+			input: "0x2289b18c" +
+				"0000000000000000000000000000000000000000000000000000000000000040" +
+				"0000000000000000000000000000000000000000000000000000000000000140" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000040" +
+				"00000000000000000000000000000000000000000000000000000000000000a0" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"0000000000000000000000000000000000000000000000000000000000000060" +
+				"00000000000000000000000000000000000000000000000000000000000000a0" +
+				"00000000000000000000000000000000000000000000000000000000000000e0" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"6f6e650000000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"74776f0000000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000005" +
+				"7468726565000000000000000000000000000000000000000000000000000000",
+			want: &DecodedCall{
+				Method:    "g",
+				Signature: "g(uint256[][],string[])",
+				Inputs: []Param{
+					{Name: "a", Type: "uint256[][]", Value: []interface{}{[]interface{}{"1", "2"}, []interface{}{"3"}}},
+					{Name: "b", Type: "string[]", Value: []interface{}{"one", "two", "three"}},
+				},
+			},
+		},
+		{
+			name:  "normal case 4 - tuple, negative int and fixed array",
+			input: Selector("h((address,uint256[],string),int8,bool,bytes32[2])") + testTupleData,
+			want: &DecodedCall{
+				Method:    "h",
+				Signature: "h((address,uint256[],string),int8,bool,bytes32[2])",
+				Inputs: []Param{
+					{Name: "order", Type: "(address,uint256[],string)", Value: []Param{
+						{Name: "maker", Type: "address", Value: "0x00000000000000000000000000000000000000aa"},
+						{Name: "amounts", Type: "uint256[]", Value: []interface{}{"7", "8"}},
+						{Name: "memo", Type: "string", Value: "hi"},
+					}},
+					{Name: "delta", Type: "int8", Value: "-5"},
+					{Name: "flag", Type: "bool", Value: true},
+					{Name: "keys", Type: "bytes32[2]", Value: []interface{}{
+						"0x0100000000000000000000000000000000000000000000000000000000000000",
+						"0x0200000000000000000000000000000000000000000000000000000000000000",
+					}},
+				},
+			},
+		},
+		{
+			name:      "failure case 1 - unknown selector",
+			input:     "0x12345678",
+			wantErrIs: ErrMethodNotFound,
+		},
+		{
+			name:      "failure case 2 - too short for selector",
+			input:     "0xa905",
+			wantErrIs: ErrMethodNotFound,
+		},
+		{
+			name:      "failure case 3 - truncated",
+			input:     "0xa9059cbb000000000000000000000000b0b0000000000000000000000000000000000001",
+			wantErrIs: ErrInvalidData,
+		},
+		{
+			name:      "failure case 4 - invalid hex",
+			input:     "0xa9059cbbzz",
+			wantErrIs: ErrInvalidData,
+		},
+		{
+			name: "failure case 5 - invalid output",
+			input: "0xa9059cbb" +
+				"000000000000000000000000b0b0000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000001",
+			output:    "0x0000000000000000000000000000000000000000000000000000000000000002",
+			wantErrIs: ErrInvalidData,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := contract.DecodeCall(tt.input, tt.output)
+			if tt.wantErrIs != nil {
+				assert.True(t, errors.Is(err, tt.wantErrIs), err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// the arguments of `h`: ((0xaa, [7, 8], "hi"), -5, true, [0x01.., 0x02..])
+const testTupleData = "" +
+	"00000000000000000000000000000000000000000000000000000000000000a0" +
+	"fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffb" +
+	"0000000000000000000000000000000000000000000000000000000000000001" +
+	"0100000000000000000000000000000000000000000000000000000000000000" +
+	"0200000000000000000000000000000000000000000000000000000000000000" +
+	"00000000000000000000000000000000000000000000000000000000000000aa" +
+	"0000000000000000000000000000000000000000000000000000000000000060" +
+	"00000000000000000000000000000000000000000000000000000000000000c0" +
+	"0000000000000000000000000000000000000000000000000000000000000002" +
+	"0000000000000000000000000000000000000000000000000000000000000007" +
+	"0000000000000000000000000000000000000000000000000000000000000008" +
+	"0000000000000000000000000000000000000000000000000000000000000002" +
+	"6869000000000000000000000000000000000000000000000000000000000000"
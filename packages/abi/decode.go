@@ -0,0 +1,180 @@
+package abi
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// the size of a slot in the encoding
+const wordSize = 32
+
+// 2^256, to convert the negative `intN` from two's complement
+var twoTo256 = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// Param is a decoded argument. The values are in JSON friendly forms:
+//   - `uintN` and `intN` are decimal strings, since they overflow `int64`
+//   - `address`, `bytesN` and `bytes` are hex strings with 0x prefix
+//   - `bool` and `string` are as is
+//   - arrays are `[]interface{}`, and tuples are `[]Param`
+type Param struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// decodeArguments decode the arguments encoded as a tuple, and attach the names
+func decodeArguments(types []*Type, names []string, data []byte) ([]Param, error) {
+	values, err := decodeSequence(types, data)
+	if err != nil {
+		return nil, err
+	}
+	return newParams(types, names, values), nil
+}
+
+func newParams(types []*Type, names []string, values []interface{}) []Param {
+	params := make([]Param, 0, len(values))
+	for i, value := range values {
+		params = append(params, Param{Name: names[i], Type: types[i].String(), Value: value})
+	}
+	return params
+}
+
+// decodeSequence decode the values encoded one by one from the start of `data`, e.g. the fields of a tuple or the elements of an array.
+// The dynamic ones are encoded in the tail, with their offsets (from the start of `data`) in the head.
+func decodeSequence(types []*Type, data []byte) ([]interface{}, error) {
+
+	values := make([]interface{}, 0, len(types))
+	head := 0
+	for _, t := range types {
+		start := head
+		if t.dynamic() {
+			offset, err := readLength(data, head)
+			if err != nil {
+				return nil, err
+			}
+			start = offset
+		}
+		if start > len(data) {
+			return nil, fmt.Errorf("%w: offset %d out of %d bytes", ErrInvalidData, start, len(data))
+		}
+		value, err := decodeValue(t, data[start:])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		head += t.headSize()
+	}
+	return values, nil
+}
+
+// decodeValue decode a value encoded from the start of `data`
+func decodeValue(t *Type, data []byte) (interface{}, error) {
+
+	switch t.kind {
+	case kindBytes, kindString:
+		length, err := readLength(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		if length > len(data)-wordSize {
+			return nil, fmt.Errorf("%w: %s of %d bytes out of %d bytes", ErrInvalidData, t, length, len(data)-wordSize)
+		}
+		content := data[wordSize : wordSize+length]
+		if t.kind == kindString {
+			return string(content), nil
+		}
+		return "0x" + hex.EncodeToString(content), nil
+	case kindSlice:
+		length, err := readLength(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		return decodeArray(t.elem, length, data[wordSize:])
+	case kindArray:
+		return decodeArray(t.elem, t.size, data)
+	case kindTuple:
+		values, err := decodeSequence(t.fields, data)
+		if err != nil {
+			return nil, err
+		}
+		return newParams(t.fields, t.fieldNames, values), nil
+	}
+
+	word, err := readWord(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch t.kind {
+	case kindUint:
+		return new(big.Int).SetBytes(word).String(), nil
+	case kindInt:
+		value := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			value.Sub(value, twoTo256)
+		}
+		return value.String(), nil
+	case kindAddress:
+		return "0x" + hex.EncodeToString(word[wordSize-20:]), nil
+	case kindBool:
+		for _, b := range word[:wordSize-1] {
+			if b != 0 {
+				return nil, fmt.Errorf("%w: invalid bool", ErrInvalidData)
+			}
+		}
+		switch word[wordSize-1] {
+		case 0:
+			return false, nil
+		case 1:
+			return true, nil
+		}
+		return nil, fmt.Errorf("%w: invalid bool", ErrInvalidData)
+	case kindFixedBytes:
+		return "0x" + hex.EncodeToString(word[:t.size]), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrInvalidType, t)
+}
+
+// decodeArray decode `length` elements from the start of `data`
+func decodeArray(elem *Type, length int, data []byte) ([]interface{}, error) {
+
+	// each element takes at least its head, check it before allocation, since the length is from the data
+	size := elem.headSize()
+	if size == 0 { // empty tuple
+		size = 1
+	}
+	if length > len(data)/size {
+		return nil, fmt.Errorf("%w: %d elements of %s out of %d bytes", ErrInvalidData, length, elem, len(data))
+	}
+	types := make([]*Type, length)
+	for i := range types {
+		types[i] = elem
+	}
+	return decodeSequence(types, data)
+}
+
+func readWord(data []byte, offset int) ([]byte, error) {
+	if offset < 0 || offset+wordSize > len(data) {
+		return nil, fmt.Errorf("%w: word at %d out of %d bytes", ErrInvalidData, offset, len(data))
+	}
+	return data[offset : offset+wordSize], nil
+}
+
+// readLength read a word as a length or offset. It should fit in the data, so the large ones are rejected.
+func readLength(data []byte, offset int) (int, error) {
+	word, err := readWord(data, offset)
+	if err != nil {
+		return 0, err
+	}
+	for _, b := range word[:wordSize-8] {
+		if b != 0 {
+			return 0, fmt.Errorf("%w: length too large", ErrInvalidData)
+		}
+	}
+	length := binary.BigEndian.Uint64(word[wordSize-8:])
+	if length > uint64(len(data)) {
+		return 0, fmt.Errorf("%w: length %d out of %d bytes", ErrInvalidData, length, len(data))
+	}
+	return int(length), nil
+}
@@ -0,0 +1,59 @@
+package abi
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_decodeValue(t *testing.T) {
+	word := func(s string) string {
+		return strings.Repeat("0", 64-len(s)) + s
+	}
+
+	tests := []struct {
+		name string
+		typ  string
+		data string
+		want interface{}
+		// ErrInvalidData is expected if it's set
+		wantErr bool
+	}{
+		{name: "normal case 1 - uint256 max", typ: "uint256", data: strings.Repeat("f", 64), want: "115792089237316195423570985008687907853269984665640564039457584007913129639935"},
+		{name: "normal case 2 - int256 min", typ: "int256", data: "8" + strings.Repeat("0", 63), want: "-57896044618658097711785492504343953926634992332820282019728792003956564819968"},
+		{name: "normal case 3 - int16 positive", typ: "int16", data: word("7fff"), want: "32767"},
+		{name: "normal case 4 - bool false", typ: "bool", data: word("0"), want: false},
+		{name: "normal case 5 - empty string", typ: "string", data: word("0"), want: ""},
+		{name: "normal case 6 - empty slice", typ: "address[]", data: word("0"), want: []interface{}{}},
+		{name: "normal case 7 - utf-8 string", typ: "string", data: word("6") + "e4bda0e5a5bd" + strings.Repeat("0", 52), want: "你好"},
+		{name: "failure case 1 - empty", typ: "uint256", data: "", wantErr: true},
+		{name: "failure case 2 - invalid bool", typ: "bool", data: word("2"), wantErr: true},
+		{name: "failure case 3 - bytes longer than data", typ: "bytes", data: word("21") + strings.Repeat("0", 64), wantErr: true},
+		{name: "failure case 4 - huge slice length", typ: "uint256[]", data: word("ffffffffffff"), wantErr: true},
+		{name: "failure case 5 - length overflow", typ: "string", data: "1" + strings.Repeat("0", 63), wantErr: true},
+		{name: "failure case 6 - elements out of data", typ: "uint256[]", data: word("2") + word("1"), wantErr: true},
+		{name: "failure case 7 - offset out of data", typ: "string[]", data: word("1") + word("ff"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, err := NewType(tt.typ, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			data, err := hex.DecodeString(tt.data)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := decodeValue(typ, data)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidData), err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package abi
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// the signatures of the common functions and events, e.g. of ERC-20, Uniswap and Multicall
+//
+//go:embed signatures.txt
+var builtinSignatures []byte
+
+// ParseMethod parse a function signature, e.g. `transfer(address,uint256)` or `transfer(address to, uint256 amount)`.
+// The tuples are in the same form as the canonical signature, e.g. `(address,uint256)[]`.
+func ParseMethod(signature string) (*Method, error) {
+
+	signature = strings.TrimSpace(signature)
+	start := strings.Index(signature, "(")
+	if start <= 0 || !strings.HasSuffix(signature, ")") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSignature, signature)
+	}
+	name := strings.TrimSpace(signature[:start])
+	if strings.ContainsAny(name, " \t,()[]") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSignature, signature)
+	}
+	inputs, err := parseArguments(signature[start+1 : len(signature)-1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %s", ErrInvalidSignature, signature, err.Error())
+	}
+	return NewMethod(name, inputs, nil)
+}
+
+// parseArguments parse the comma separated arguments. Each one is the type, followed by the optional name.
+func parseArguments(s string) ([]Argument, error) {
+
+	arguments := []Argument{}
+	if strings.TrimSpace(s) == "" {
+		return arguments, nil
+	}
+
+	depth, start := 0, 0
+	var parts []string
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, errors.New("unbalanced parentheses")
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced parentheses")
+	}
+	parts = append(parts, s[start:])
+
+	for _, part := range parts {
+		argument, err := parseArgument(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, argument)
+	}
+	return arguments, nil
+}
+
+func parseArgument(s string) (Argument, error) {
+
+	var (
+		argument Argument
+		rest     []string
+	)
+	if strings.HasPrefix(s, "(") { // tuple, with the optional array suffix
+		end := strings.LastIndex(s, ")")
+		components, err := parseArguments(s[1:end])
+		if err != nil {
+			return Argument{}, err
+		}
+		fields := strings.Fields(s[end+1:])
+		suffix := ""
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "[") {
+			suffix, fields = fields[0], fields[1:]
+		}
+		argument = Argument{Type: "tuple" + suffix, Components: components}
+		rest = fields
+	} else {
+		fields := strings.Fields(s)
+		if len(fields) == 0 {
+			return Argument{}, errors.New("empty argument")
+		}
+		argument = Argument{Type: fields[0]}
+		rest = fields[1:]
+	}
+
+	// the modifiers in the Solidity declarations are skipped
+	var names []string
+	for _, field := range rest {
+		switch field {
+		case "indexed", "memory", "calldata", "storage", "payable":
+			continue
+		}
+		names = append(names, field)
+	}
+	if len(names) > 1 {
+		return Argument{}, fmt.Errorf("invalid argument %q", s)
+	}
+	if len(names) == 1 {
+		argument.Name = names[0]
+	}
+	return argument, nil
+}
+
+// SignatureRegistry maps the selectors of functions and the topics of events to their signatures.
+// It's to show the method names of the calls to the contracts without ABIs. It's safe for concurrent use.
+type SignatureRegistry struct {
+	lock sync.RWMutex
+	// the signatures of functions, keyed by selector
+	functions map[string]string
+	// the signatures of events, keyed by topic
+	events map[string]string
+}
+
+// NewSignatureRegistry construct a registry with the built-in signatures
+func NewSignatureRegistry() *SignatureRegistry {
+	registry := &SignatureRegistry{
+		functions: make(map[string]string),
+		events:    make(map[string]string),
+	}
+	if err := registry.Load(bytes.NewReader(builtinSignatures)); err != nil {
+		panic(err) // the built-in ones are verified by tests
+	}
+	return registry
+}
+
+// Load add the signatures, in the same form as the built-in `signatures.txt`:
+// one per line, `function <signature>` or `event <signature>`. The empty lines and `#` comments are skipped.
+// The existing ones with the same selector or topic are replaced. Nothing is added if any line is invalid.
+func (this *SignatureRegistry) Load(r io.Reader) error {
+
+	functions := make(map[string]string)
+	events := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for num := 1; scanner.Scan(); num++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 || (fields[0] != "function" && fields[0] != "event") {
+			return fmt.Errorf("%w: line %d: %q", ErrInvalidSignature, num, line)
+		}
+		// the canonical signature is used, e.g. `uint` is converted to `uint256`
+		method, err := ParseMethod(fields[1])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", num, err)
+		}
+		if fields[0] == "function" {
+			functions[method.Selector] = method.Signature
+		} else {
+			events[Topic(method.Signature)] = method.Signature
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for selector, signature := range functions {
+		this.functions[selector] = signature
+	}
+	for topic, signature := range events {
+		this.events[topic] = signature
+	}
+	return nil
+}
+
+// LoadFile add the signatures in a file, see `Load` for the form
+func (this *SignatureRegistry) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return this.Load(f)
+}
+
+// Function find the signature of a function by the selector (in hex with 0x prefix), or the calldata starting with it
+func (this *SignatureRegistry) Function(selector string) (string, bool) {
+	if len(selector) < 2+selectorSize*2 {
+		return "", false
+	}
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	signature, ok := this.functions[strings.ToLower(selector[:2+selectorSize*2])]
+	return signature, ok
+}
+
+// Event find the signature of an event by the topic (in hex with 0x prefix)
+func (this *SignatureRegistry) Event(topic string) (string, bool) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	signature, ok := this.events[strings.ToLower(topic)]
+	return signature, ok
+}
+
+// Size return the number of function and event signatures
+func (this *SignatureRegistry) Size() int {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return len(this.functions) + len(this.events)
+}
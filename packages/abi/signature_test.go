@@ -0,0 +1,151 @@
+package abi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMethod(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature string
+		want      string
+		// the names of inputs
+		wantNames []string
+		wantErr   bool
+	}{
+		{name: "normal case 1 - canonical", signature: "transfer(address,uint256)", want: "transfer(address,uint256)", wantNames: []string{"", ""}},
+		{name: "normal case 2 - with names and aliases", signature: " transfer(address to, uint amount) ", want: "transfer(address,uint256)", wantNames: []string{"to", "amount"}},
+		{name: "normal case 3 - no argument", signature: "deposit()", want: "deposit()", wantNames: []string{}},
+		{name: "normal case 4 - tuple array", signature: "aggregate((address target, bytes callData)[] calls)", want: "aggregate((address,bytes)[])", wantNames: []string{"calls"}},
+		{name: "normal case 5 - nested tuple", signature: "f((uint256,(address,bool)[2]),bytes memory data)", want: "f((uint256,(address,bool)[2]),bytes)", wantNames: []string{"", "data"}},
+		{name: "normal case 6 - event", signature: "Transfer(address indexed from, address indexed to, uint256 value)", want: "Transfer(address,address,uint256)", wantNames: []string{"from", "to", "value"}},
+		{name: "failure case 1 - no parentheses", signature: "transfer", wantErr: true},
+		{name: "failure case 2 - unbalanced", signature: "f((address,bool)", wantErr: true},
+		{name: "failure case 3 - empty argument", signature: "f(address,)", wantErr: true},
+		{name: "failure case 4 - invalid type", signature: "f(uint7)", wantErr: true},
+		{name: "failure case 5 - invalid name", signature: "a b(uint256)", wantErr: true},
+		{name: "failure case 6 - 2 names", signature: "f(uint256 a b)", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMethod(tt.signature)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.Signature)
+			assert.Equal(t, tt.wantNames, argumentNames(got.Inputs))
+		})
+	}
+}
+
+func TestNewSignatureRegistry(t *testing.T) {
+	registry := NewSignatureRegistry()
+
+	functions := map[string]string{
+		"0xa9059cbb": "transfer(address,uint256)",
+		"0x095ea7b3": "approve(address,uint256)",
+		"0x23b872dd": "transferFrom(address,address,uint256)",
+		"0x38ed1739": "swapExactTokensForTokens(uint256,uint256,address[],address,uint256)",
+		"0x7ff36ab5": "swapExactETHForTokens(uint256,address[],address,uint256)",
+		"0x414bf389": "exactInputSingle((address,address,uint24,address,uint256,uint256,uint256,uint160))",
+		"0xac9650d8": "multicall(bytes[])",
+		"0x5ae401dc": "multicall(uint256,bytes[])",
+		"0x252dba42": "aggregate((address,bytes)[])",
+	}
+	for selector, want := range functions {
+		got, ok := registry.Function(selector)
+		assert.True(t, ok, selector)
+		assert.Equal(t, want, got)
+	}
+	// the calldata works too
+	got, ok := registry.Function("0xA9059CBB000000000000000000000000b0b0000000000000000000000000000000000001")
+	assert.True(t, ok)
+	assert.Equal(t, "transfer(address,uint256)", got)
+	_, ok = registry.Function("0x")
+	assert.False(t, ok)
+	_, ok = registry.Function("0x12345678")
+	assert.False(t, ok)
+
+	events := map[string]string{
+		"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef": "Transfer(address,address,uint256)",
+		"0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925": "Approval(address,address,uint256)",
+		"0xd78ad95fa46c994b6551d0da85fc275fe613ce37657fb8d5e3d130840159d822": "Swap(address,uint256,uint256,uint256,uint256,address)",
+		"0xc42079f94a6350d7e6235f29174924f928cc2ac818eb64fed8004e115fbcca67": "Swap(address,address,int256,int256,uint160,uint128,int24)",
+	}
+	for topic, want := range events {
+		got, ok := registry.Event(topic)
+		assert.True(t, ok, topic)
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestSignatureRegistry_Load(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		// the signatures of the selectors after loading
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "normal case 1 - added and replaced",
+			data: "# custom\n\nfunction claim(uint256 id)\n  function transfer(address to, uint amount)  \nevent Claimed(address indexed user, uint256 id)\n",
+			want: map[string]string{
+				Selector("claim(uint256)"):            "claim(uint256)",
+				Selector("transfer(address,uint256)"): "transfer(address,uint256)",
+			},
+		},
+		{
+			name:    "failure case 1 - unknown kind, nothing added",
+			data:    "function claim(uint256)\nerror Unauthorized()\n",
+			want:    map[string]string{Selector("claim(uint256)"): ""},
+			wantErr: true,
+		},
+		{
+			name:    "failure case 2 - invalid signature, nothing added",
+			data:    "function claim(uint256)\nfunction broken(uint7)\n",
+			want:    map[string]string{Selector("claim(uint256)"): ""},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewSignatureRegistry()
+			size := registry.Size()
+
+			err := registry.Load(strings.NewReader(tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, size, registry.Size())
+			} else {
+				assert.NoError(t, err)
+			}
+			for selector, want := range tt.want {
+				got, _ := registry.Function(selector)
+				assert.Equal(t, want, got)
+			}
+		})
+	}
+}
+
+func TestSignatureRegistry_LoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signatures.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("event Claimed(address,uint256)\n"), 0644))
+
+	registry := NewSignatureRegistry()
+	assert.NoError(t, registry.LoadFile(path))
+	got, ok := registry.Event(Topic("Claimed(address,uint256)"))
+	assert.True(t, ok)
+	assert.Equal(t, "Claimed(address,uint256)", got)
+
+	err := registry.LoadFile(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.True(t, errors.Is(err, os.ErrNotExist), err)
+}
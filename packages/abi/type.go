@@ -0,0 +1,161 @@
+package abi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type kind int
+
+const (
+	kindUint kind = iota
+	kindInt
+	kindAddress
+	kindBool
+	kindFixedBytes
+	kindBytes
+	kindString
+	// `T[]`
+	kindSlice
+	// `T[k]`
+	kindArray
+	kindTuple
+)
+
+// Type is a parsed ABI type, e.g. `uint256`, `bytes32[]` or `(address,uint256)`
+type Type struct {
+	kind kind
+	// the bits of `uintN` / `intN`, the bytes of `bytesN`, or the length of `T[k]`
+	size int
+	// the element type of arrays
+	elem *Type
+	// the fields of tuple
+	fields     []*Type
+	fieldNames []string
+	// the canonical form used in signatures
+	name string
+}
+
+// NewType parse an ABI type. The components are the fields of `tuple` types, including the arrays of tuples.
+// The aliases are converted to the canonical form, e.g. `uint` to `uint256`.
+func NewType(typ string, components []Argument) (*Type, error) {
+
+	typ = strings.TrimSpace(typ)
+	if strings.HasSuffix(typ, "]") {
+		return newArrayType(typ, components)
+	}
+
+	if typ == "tuple" {
+		t := &Type{kind: kindTuple}
+		names := make([]string, 0, len(components))
+		for _, component := range components {
+			field, err := NewType(component.Type, component.Components)
+			if err != nil {
+				return nil, err
+			}
+			t.fields = append(t.fields, field)
+			t.fieldNames = append(t.fieldNames, component.Name)
+			names = append(names, field.name)
+		}
+		t.name = "(" + strings.Join(names, ",") + ")"
+		return t, nil
+	}
+
+	switch typ {
+	case "address":
+		return &Type{kind: kindAddress, name: typ}, nil
+	case "bool":
+		return &Type{kind: kindBool, name: typ}, nil
+	case "string":
+		return &Type{kind: kindString, name: typ}, nil
+	case "bytes":
+		return &Type{kind: kindBytes, name: typ}, nil
+	case "uint", "int":
+		typ += "256"
+	case "function": // the address and selector of a function
+		return &Type{kind: kindFixedBytes, size: 24, name: typ}, nil
+	}
+
+	switch {
+	case strings.HasPrefix(typ, "uint"):
+		return newSizedType(kindUint, typ, typ[len("uint"):], 8, 256, 8)
+	case strings.HasPrefix(typ, "int"):
+		return newSizedType(kindInt, typ, typ[len("int"):], 8, 256, 8)
+	case strings.HasPrefix(typ, "bytes"):
+		return newSizedType(kindFixedBytes, typ, typ[len("bytes"):], 1, 32, 1)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidType, typ)
+}
+
+// newSizedType parse the types with the size suffix, e.g. `uint8` and `bytes32`
+func newSizedType(k kind, typ, suffix string, min, max, step int) (*Type, error) {
+	size, err := strconv.Atoi(suffix)
+	if err != nil || size < min || size > max || size%step != 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidType, typ)
+	}
+	return &Type{kind: k, size: size, name: typ}, nil
+}
+
+// newArrayType parse `T[]` and `T[k]`. The last dimension is the outermost one, e.g. `uint256[2][]` is a slice of `uint256[2]`.
+func newArrayType(typ string, components []Argument) (*Type, error) {
+
+	start := strings.LastIndex(typ, "[")
+	if start <= 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidType, typ)
+	}
+	elem, err := NewType(typ[:start], components)
+	if err != nil {
+		return nil, err
+	}
+
+	length := typ[start+1 : len(typ)-1]
+	if length == "" {
+		return &Type{kind: kindSlice, elem: elem, name: elem.name + "[]"}, nil
+	}
+	size, err := strconv.Atoi(length)
+	if err != nil || size < 1 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidType, typ)
+	}
+	return &Type{kind: kindArray, size: size, elem: elem, name: fmt.Sprintf("%s[%d]", elem.name, size)}, nil
+}
+
+// String return the canonical form used in signatures
+func (this *Type) String() string {
+	return this.name
+}
+
+// dynamic report if the type is encoded in the tail, with its offset in the head
+func (this *Type) dynamic() bool {
+	switch this.kind {
+	case kindBytes, kindString, kindSlice:
+		return true
+	case kindArray:
+		return this.elem.dynamic()
+	case kindTuple:
+		for _, field := range this.fields {
+			if field.dynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headSize return the bytes of the type in the head. It's one word for the dynamic types, which is the offset.
+func (this *Type) headSize() int {
+	if this.dynamic() {
+		return wordSize
+	}
+	switch this.kind {
+	case kindArray:
+		return this.size * this.elem.headSize()
+	case kindTuple:
+		size := 0
+		for _, field := range this.fields {
+			size += field.headSize()
+		}
+		return size
+	}
+	return wordSize
+}
@@ -0,0 +1,54 @@
+package abi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewType(t *testing.T) {
+	pair := []Argument{{Name: "token", Type: "address"}, {Name: "amount", Type: "uint"}}
+	dynamicPair := []Argument{{Name: "token", Type: "address"}, {Name: "memo", Type: "string"}}
+
+	tests := []struct {
+		name         string
+		typ          string
+		components   []Argument
+		want         string
+		wantDynamic  bool
+		wantHeadSize int
+		wantErr      bool
+	}{
+		{name: "normal case 1 - alias", typ: "uint", want: "uint256", wantHeadSize: 32},
+		{name: "normal case 2 - int8", typ: "int8", want: "int8", wantHeadSize: 32},
+		{name: "normal case 3 - bytes32", typ: "bytes32", want: "bytes32", wantHeadSize: 32},
+		{name: "normal case 4 - bytes", typ: "bytes", want: "bytes", wantDynamic: true, wantHeadSize: 32},
+		{name: "normal case 5 - fixed array", typ: "address[3]", want: "address[3]", wantHeadSize: 96},
+		{name: "normal case 6 - array of fixed array", typ: "uint[2][]", want: "uint256[2][]", wantDynamic: true, wantHeadSize: 32},
+		{name: "normal case 7 - fixed array of dynamic", typ: "string[2]", want: "string[2]", wantDynamic: true, wantHeadSize: 32},
+		{name: "normal case 8 - static tuple", typ: "tuple", components: pair, want: "(address,uint256)", wantHeadSize: 64},
+		{name: "normal case 9 - array of static tuple", typ: "tuple[2]", components: pair, want: "(address,uint256)[2]", wantHeadSize: 128},
+		{name: "normal case 10 - dynamic tuple", typ: "tuple", components: dynamicPair, want: "(address,string)", wantDynamic: true, wantHeadSize: 32},
+		{name: "normal case 11 - function", typ: "function", want: "function", wantHeadSize: 32},
+		{name: "failure case 1 - unknown", typ: "uint256x", wantErr: true},
+		{name: "failure case 2 - invalid bits", typ: "int264", wantErr: true},
+		{name: "failure case 3 - invalid bytes", typ: "bytes33", wantErr: true},
+		{name: "failure case 4 - invalid length", typ: "uint256[0]", wantErr: true},
+		{name: "failure case 5 - missing element", typ: "[2]", wantErr: true},
+		{name: "failure case 6 - invalid component", typ: "tuple[]", components: []Argument{{Type: "foo"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewType(tt.typ, tt.components)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidType), err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+			assert.Equal(t, tt.wantDynamic, got.dynamic())
+			assert.Equal(t, tt.wantHeadSize, got.headSize())
+		})
+	}
+}
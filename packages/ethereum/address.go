@@ -0,0 +1,99 @@
+package ethereum
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// AddressLength is the length of an address in bytes
+const AddressLength = 20
+
+var ErrInvalidAddress = errors.New("invalid address")
+
+// Address is a 20 bytes ethereum address.
+// It's rendered in the EIP-55 checksum form, and the lower case hex form is used as the key of storage.
+type Address [AddressLength]byte
+
+// ParseAddress parse and validate an address in hex, with or without the `0x` prefix.
+// If the address is in mixed case, it's validated with the EIP-55 checksum.
+func ParseAddress(s string) (Address, error) {
+	var addr Address
+
+	raw := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(raw) != AddressLength*2 {
+		return addr, fmt.Errorf("%w: %q, length should be %d", ErrInvalidAddress, s, AddressLength*2)
+	}
+	if _, err := hex.Decode(addr[:], []byte(raw)); err != nil {
+		return addr, fmt.Errorf("%w: %q, %s", ErrInvalidAddress, s, err.Error())
+	}
+
+	// all lower or all upper case means there is no checksum
+	if raw != strings.ToLower(raw) && raw != strings.ToUpper(raw) && "0x"+raw != addr.Checksum() {
+		return addr, fmt.Errorf("%w: %q, checksum mismatch", ErrInvalidAddress, s)
+	}
+	return addr, nil
+}
+
+// MustParseAddress is like `ParseAddress`, but panics if the address is invalid.
+func MustParseAddress(s string) Address {
+	addr, err := ParseAddress(s)
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+// Hex return the lower case hex form, with the `0x` prefix
+func (this Address) Hex() string {
+	return "0x" + hex.EncodeToString(this[:])
+}
+
+// Checksum return the EIP-55 checksum form.
+// A letter is upper case if the corresponding nibble of the keccak256 hash of the lower case hex is >= 8.
+func (this Address) Checksum() string {
+	lower := hex.EncodeToString(this[:])
+
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write([]byte(lower))
+	hash := hasher.Sum(nil)
+
+	result := []byte(lower)
+	for i, c := range result {
+		if c < 'a' { // digit
+			continue
+		}
+		nibble := hash[i/2]
+		if i%2 == 0 {
+			nibble = nibble >> 4
+		}
+		if nibble&0xf >= 8 {
+			result[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(result)
+}
+
+func (this Address) String() string {
+	return this.Checksum()
+}
+
+func (this Address) IsZero() bool {
+	return this == Address{}
+}
+
+func (this Address) MarshalText() ([]byte, error) {
+	return []byte(this.Checksum()), nil
+}
+
+func (this *Address) UnmarshalText(text []byte) error {
+	addr, err := ParseAddress(string(text))
+	if err != nil {
+		return err
+	}
+	*this = addr
+	return nil
+}
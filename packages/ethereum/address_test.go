@@ -0,0 +1,79 @@
+package ethereum
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "normal case 1 - lower case",
+			input: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+			want:  "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		},
+		{
+			name:  "normal case 2 - upper case without prefix",
+			input: "FB6916095CA1DF60BB79CE92CE3EA74C37C5D359",
+			want:  "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		},
+		{
+			name:  "normal case 3 - checksum",
+			input: "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+			want:  "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		},
+		{
+			name:    "error case 1 - checksum mismatch",
+			input:   "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6Fb",
+			wantErr: true,
+		},
+		{
+			name:    "error case 2 - too short",
+			input:   "0xffff",
+			wantErr: true,
+		},
+		{
+			name:    "error case 3 - not hex",
+			input:   "0xzzF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAddress(tt.input)
+			if tt.wantErr {
+				assert.Equal(t, true, errors.Is(err, ErrInvalidAddress))
+				return
+			}
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tt.want, got.Checksum())
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestAddress_JSON(t *testing.T) {
+	type params struct {
+		Address Address `json:"address"`
+	}
+
+	var p params
+	err := json.Unmarshal([]byte(`{"address": "0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED"}`), &p)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", p.Address.Hex())
+
+	raw, err := json.Marshal(p)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"address":"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}`, string(raw))
+
+	err = json.Unmarshal([]byte(`{"address": "0xabc"}`), &p)
+	assert.Equal(t, true, errors.Is(err, ErrInvalidAddress))
+}
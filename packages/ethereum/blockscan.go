@@ -0,0 +1,186 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+)
+
+const (
+	receiptStatusFailure = "0x0"
+	// the error of failed transactions, the same as `trace_filter`
+	errorReverted = "Reverted"
+)
+
+// blockScanAccesser implements interface `EthereumChainAccesser` without the `trace_*` namespace, which is not enabled by most nodes.
+// It finds the transactions of addresses by scanning the blocks with full transactions, and gets the status and gas used from receipts.
+// Only the top level transactions are found, the internal calls found by `trace_filter` are not.
+// The other methods are served by the wrapped accesser.
+type blockScanAccesser struct {
+	EthereumChainAccesser
+	logger logging.Logger
+}
+
+// NewBlockScanAccesser wrap `chainAccesser`, to get the transactions of addresses by scanning blocks
+func NewBlockScanAccesser(chainAccesser EthereumChainAccesser, logger logging.Logger) EthereumChainAccesser {
+	return &blockScanAccesser{
+		EthereumChainAccesser: chainAccesser,
+		logger:                logger,
+	}
+}
+
+func (this *blockScanAccesser) EthGetCurrentTransactionsByAddress(ctx context.Context, req *EthGetCurrentTransactionsByAddressRequest) ([]Transaction, error) {
+	return this.scan(ctx, req.FromBlock, req.ToBlock, []string{req.FromAddress, req.ToAddress}, req.RequestId)
+}
+
+func (this *blockScanAccesser) EthGetCurrentTransactionsByAddresses(ctx context.Context, req *EthGetCurrentTransactionsByAddressesRequest) ([]Transaction, error) {
+	return this.scan(ctx, req.FromBlock, req.ToBlock, req.Addresses, req.RequestId)
+}
+
+// EthGetCurrentTransactionsByAddressBatch scan the blocks for each of the requests.
+func (this *blockScanAccesser) EthGetCurrentTransactionsByAddressBatch(ctx context.Context, reqs []*EthGetCurrentTransactionsByAddressRequest) ([]EthGetCurrentTransactionsByAddressResult, error) {
+	results := make([]EthGetCurrentTransactionsByAddressResult, len(reqs))
+	for i, req := range reqs {
+		results[i].Transactions, results[i].Err = this.EthGetCurrentTransactionsByAddress(ctx, req)
+	}
+	return results, nil
+}
+
+// scan go through the blocks from `fromBlock` to `toBlock` (both in hex), and find the transactions sent from or to any of the addresses.
+func (this *blockScanAccesser) scan(ctx context.Context, fromBlock, toBlock string, addresses []string, requestId string) ([]Transaction, error) {
+
+	from, err := strconv.ParseInt(fromBlock, 0, 64)
+	if err != nil {
+		this.logger.Errorf("invalid from block | from block: %s, err: %s", fromBlock, err.Error())
+		return nil, err
+	}
+	to, err := strconv.ParseInt(toBlock, 0, 64)
+	if err != nil {
+		this.logger.Errorf("invalid to block | to block: %s, err: %s", toBlock, err.Error())
+		return nil, err
+	}
+
+	watched := make(map[string]bool, len(addresses))
+	for _, addr := range addresses {
+		if addr != "" {
+			watched[strings.ToLower(addr)] = true
+		}
+	}
+
+	var res []Transaction
+	for bn := from; bn <= to; bn++ {
+		blockReq := &EthGetBlockByNumberRequest{
+			BlockNumber: fmt.Sprintf("0x%x", bn),
+			RequestId:   fmt.Sprintf("%s-%d", requestId, bn),
+		}
+		block, err := this.EthereumChainAccesser.EthGetBlockWithTransactionsByNumber(ctx, blockReq)
+		if err != nil {
+			this.logger.Errorf("get block fail | block number: %d, err: %s", bn, err.Error())
+			return nil, err
+		}
+
+		// the contract creations are fetched too, since the created address is only known from the receipt
+		var txs []BlockTransaction
+		var receiptReqs []*EthGetTransactionReceiptRequest
+		for _, tx := range block.Transactions {
+			if !watched[strings.ToLower(tx.From)] && !watched[strings.ToLower(tx.To)] && tx.To != "" {
+				continue
+			}
+			txs = append(txs, tx)
+			receiptReqs = append(receiptReqs, &EthGetTransactionReceiptRequest{
+				TransactionHash: tx.Hash,
+				// the IDs should be unique in a batch
+				RequestId: fmt.Sprintf("%s-%d-%d", requestId, bn, len(receiptReqs)),
+			})
+		}
+		if len(receiptReqs) == 0 {
+			continue
+		}
+
+		results, err := this.EthereumChainAccesser.EthGetTransactionReceiptBatch(ctx, receiptReqs)
+		if err != nil {
+			this.logger.Errorf("get receipts fail | block number: %d, size: %d, err: %s", bn, len(receiptReqs), err.Error())
+			return nil, err
+		}
+		if len(results) != len(receiptReqs) {
+			this.logger.Errorf("result missing in batch | block number: %d, size: %d, results: %d", bn, len(receiptReqs), len(results))
+			return nil, errors.New("result missing in batch")
+		}
+
+		for i, tx := range txs {
+			if results[i].Err != nil {
+				this.logger.Errorf("get receipt fail | transaction: %s, err: %s", tx.Hash, results[i].Err.Error())
+				return nil, results[i].Err
+			}
+			receipt := results[i].Receipt
+			if !watched[strings.ToLower(tx.From)] && !watched[strings.ToLower(tx.To)] && !watched[strings.ToLower(receipt.ContractAddress)] {
+				continue
+			}
+			trx, err := convertBlockTransaction(block, tx, receipt)
+			if err != nil {
+				this.logger.Errorf("convert transaction fail | transaction: %s, err: %s", tx.Hash, err.Error())
+				return nil, err
+			}
+			res = append(res, trx)
+		}
+	}
+	return res, nil
+}
+
+// convertBlockTransaction map a transaction in block and its receipt into the shape returned by `trace_filter`
+func convertBlockTransaction(block *BlockWithTransactions, tx BlockTransaction, receipt *Receipt) (Transaction, error) {
+
+	blockNumber := tx.BlockNumber
+	if blockNumber == "" {
+		blockNumber = block.Number
+	}
+	bn, err := ParseQuantity(blockNumber)
+	if err != nil {
+		return Transaction{}, err
+	}
+	var timestamp Quantity
+	if block.Timestamp != "" {
+		if timestamp, err = ParseQuantity(block.Timestamp); err != nil {
+			return Transaction{}, err
+		}
+	}
+	var position Quantity
+	if tx.TransactionIndex != "" {
+		if position, err = ParseQuantity(tx.TransactionIndex); err != nil {
+			return Transaction{}, err
+		}
+	}
+
+	trx := Transaction{
+		Action: Action{
+			From:  tx.From,
+			To:    tx.To,
+			Gas:   tx.Gas,
+			Input: tx.Input,
+			Value: tx.Value,
+		},
+		BlockHash:           block.Hash,
+		BlockNumber:         int(bn),
+		Result:              Result{GasUsed: receipt.GasUsed},
+		TraceAddress:        []string{},
+		TransactionHash:     tx.Hash,
+		TransactionPosition: int(position),
+		Type:                "call",
+		Receipt:             NewReceiptSummary(receipt),
+		Timestamp:           timestamp.Uint64(),
+	}
+	if tx.To == "" { // contract creation
+		trx.Type = "create"
+		trx.Result.Address = receipt.ContractAddress
+	} else {
+		trx.Action.CallType = "call"
+	}
+	if receipt.Status == receiptStatusFailure {
+		trx.Error = errorReverted
+	}
+	return trx, nil
+}
@@ -0,0 +1,197 @@
+// The test cases of scanning are more like integration test.
+// Need to start the testserver to run them
+// go run cmd/testserver/main.go
+
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockScanAccesser_EthGetCurrentTransactionsByAddress(t *testing.T) {
+	logger := logging.NewDefaultLogger(logging.LevelDebug)
+	this := NewBlockScanAccesser(NewEthJsonRpcClient(testEntryPoint, logger), logger)
+
+	// each block of the testserver has 2 transactions: 0x..01 -> 0x..02, and 0x..02 -> 0x..03 (failed)
+	tests := []struct {
+		name    string
+		address string
+		want    int
+	}{
+		{name: "normal case 1 - sender", address: fmt.Sprintf("0x%040x", 1), want: 3},
+		{name: "normal case 2 - both sender and receiver", address: fmt.Sprintf("0x%040x", 2), want: 6},
+		{name: "normal case 3 - no transaction", address: fmt.Sprintf("0x%040x", 4), want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &EthGetCurrentTransactionsByAddressRequest{
+				FromBlock:   "0x64",
+				ToBlock:     "0x66",
+				FromAddress: tt.address,
+				ToAddress:   tt.address,
+				RequestId:   "1024",
+			}
+			got, err := this.EthGetCurrentTransactionsByAddress(context.Background(), req)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tt.want, len(got))
+			for _, trx := range got {
+				assert.Equal(t, true, trx.BlockNumber >= 100 && trx.BlockNumber <= 102)
+				assert.Equal(t, Quantity(21000), trx.Result.GasUsed)
+				assert.Equal(t, "0.000021", trx.Receipt.Fee.Ether())
+			}
+		})
+	}
+}
+
+// fakeBlockAccesser serves the blocks and receipts for test. The other methods are not implemented.
+type fakeBlockAccesser struct {
+	EthereumChainAccesser
+	blocks   map[string]*BlockWithTransactions
+	receipts map[string]*Receipt
+	// the sizes of the receipt batches
+	batches []int
+}
+
+func (this *fakeBlockAccesser) EthGetBlockWithTransactionsByNumber(ctx context.Context, req *EthGetBlockByNumberRequest) (*BlockWithTransactions, error) {
+	block, ok := this.blocks[req.BlockNumber]
+	if !ok {
+		return nil, ErrBlockNotFound
+	}
+	return block, nil
+}
+
+func (this *fakeBlockAccesser) EthGetTransactionReceiptBatch(ctx context.Context, reqs []*EthGetTransactionReceiptRequest) ([]EthGetTransactionReceiptResult, error) {
+	this.batches = append(this.batches, len(reqs))
+	results := make([]EthGetTransactionReceiptResult, len(reqs))
+	for i, req := range reqs {
+		receipt, ok := this.receipts[req.TransactionHash]
+		if !ok {
+			results[i].Err = ErrReceiptNotFound
+			continue
+		}
+		results[i].Receipt = receipt
+	}
+	return results, nil
+}
+
+func TestBlockScanAccesser_scan(t *testing.T) {
+	watched := fmt.Sprintf("0x%040x", 1)
+	contract := fmt.Sprintf("0x%040x", 0xcc)
+	other := fmt.Sprintf("0x%040x", 2)
+
+	accesser := &fakeBlockAccesser{
+		blocks: map[string]*BlockWithTransactions{
+			"0x64": {
+				Block: Block{Number: "0x64", Hash: "0xb10c"},
+				Transactions: []BlockTransaction{
+					{Hash: "0x01", From: watched, To: other},
+					{Hash: "0x02", From: other, To: other},
+					{Hash: "0x03", From: other}, // creates the watched contract
+					{Hash: "0x04", From: other}, // creates another contract
+				},
+			},
+			"0x65": {
+				Block:        Block{Number: "0x65", Hash: "0xb10d"},
+				Transactions: []BlockTransaction{{Hash: "0x05", From: other, To: other}},
+			},
+		},
+		receipts: map[string]*Receipt{
+			"0x01": {Status: "0x1"},
+			"0x03": {Status: "0x1", ContractAddress: contract},
+			"0x04": {Status: "0x1", ContractAddress: fmt.Sprintf("0x%040x", 0xdd)},
+		},
+	}
+	this := NewBlockScanAccesser(accesser, logging.NewDefaultLogger(logging.LevelDebug))
+
+	got, err := this.EthGetCurrentTransactionsByAddresses(context.Background(), &EthGetCurrentTransactionsByAddressesRequest{
+		FromBlock: "0x64",
+		ToBlock:   "0x65",
+		Addresses: []string{watched, contract},
+		RequestId: "1024",
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, "0x01", got[0].TransactionHash)
+	assert.Equal(t, "0x03", got[1].TransactionHash)
+	assert.Equal(t, "create", got[1].Type)
+	assert.Equal(t, contract, got[1].Result.Address)
+	// the receipts of a block are fetched in one batch, and the blocks without candidates are skipped
+	assert.Equal(t, []int{3}, accesser.batches)
+
+	// the receipt is not found
+	delete(accesser.receipts, "0x04")
+	_, err = this.EthGetCurrentTransactionsByAddresses(context.Background(), &EthGetCurrentTransactionsByAddressesRequest{
+		FromBlock: "0x64",
+		ToBlock:   "0x64",
+		Addresses: []string{watched},
+		RequestId: "1025",
+	})
+	assert.Equal(t, true, errors.Is(err, ErrReceiptNotFound))
+}
+
+func Test_convertBlockTransaction(t *testing.T) {
+	block := &BlockWithTransactions{
+		Block: Block{Number: "0x64", Hash: "0xb10c", Timestamp: "0x65920080"},
+	}
+	tests := []struct {
+		name    string
+		tx      BlockTransaction
+		receipt *Receipt
+		want    Transaction
+	}{
+		{
+			name:    "normal case 1 - call",
+			tx:      BlockTransaction{Hash: "0x01", TransactionIndex: "0x2", From: "0xaa", To: "0xbb", Gas: 21000, Value: NewBigInt(big.NewInt(1)), Input: "0x"},
+			receipt: &Receipt{GasUsed: 21000, EffectiveGasPrice: NewBigInt(big.NewInt(1e9)), Status: "0x1", Logs: []Log{{}}},
+			want: Transaction{
+				Action:              Action{From: "0xaa", To: "0xbb", CallType: "call", Gas: 21000, Value: NewBigInt(big.NewInt(1)), Input: "0x"},
+				BlockHash:           "0xb10c",
+				BlockNumber:         100,
+				Result:              Result{GasUsed: 21000},
+				TraceAddress:        []string{},
+				TransactionHash:     "0x01",
+				TransactionPosition: 2,
+				Type:                "call",
+				Receipt: &ReceiptSummary{
+					Status:            TransactionStatusSuccess,
+					GasUsed:           21000,
+					EffectiveGasPrice: NewBigInt(big.NewInt(1e9)),
+					Fee:               NewBigInt(big.NewInt(21000 * 1e9)),
+					LogsCount:         1,
+				},
+				Timestamp: 1704067200,
+			},
+		},
+		{
+			name:    "normal case 2 - failed contract creation",
+			tx:      BlockTransaction{Hash: "0x02", BlockNumber: "0x64", TransactionIndex: "0x0", From: "0xaa", Input: "0x6080"},
+			receipt: &Receipt{GasUsed: 0x100, Status: "0x0", ContractAddress: "0xcc"},
+			want: Transaction{
+				Action:          Action{From: "0xaa", Input: "0x6080"},
+				BlockHash:       "0xb10c",
+				BlockNumber:     100,
+				Result:          Result{GasUsed: 0x100, Address: "0xcc"},
+				TraceAddress:    []string{},
+				TransactionHash: "0x02",
+				Type:            "create",
+				Error:           "Reverted",
+				Receipt:         &ReceiptSummary{Status: TransactionStatusFailure, GasUsed: 0x100},
+				Timestamp:       1704067200,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertBlockTransaction(block, tt.tx, tt.receipt)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
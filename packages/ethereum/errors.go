@@ -0,0 +1,182 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrRateLimited is matched by the errors of HTTP 429, and the JSON RPC errors of limit exceeded
+	ErrRateLimited = errors.New("rate limited")
+	// ErrTimeout is matched by the errors of call timeout
+	ErrTimeout = errors.New("timeout")
+	// ErrBadStatus is matched by the errors of non-200 HTTP status
+	ErrBadStatus = errors.New("bad http status")
+	// ErrFilterNotFound is matched by the JSON RPC errors of unknown filter id. The filter is expired, or installed on other node.
+	ErrFilterNotFound = errors.New("filter not found")
+	// ErrRangeTooLarge is matched by the JSON RPC errors of the block range or result size over the limit of the node.
+	// Retrying the same range would fail forever, it should be split into smaller ones.
+	ErrRangeTooLarge = errors.New("range too large")
+)
+
+const (
+	// the JSON RPC 2.0 errors, which mean the request is invalid and would never succeed
+	errCodeParseError     = -32700
+	errCodeInvalidRequest = -32600
+	errCodeMethodNotFound = -32601
+	errCodeInvalidParams  = -32602
+	// the `Limit exceeded` error defined by EIP-1474
+	errCodeLimitExceeded = -32005
+	// the generic server error, used by geth for most failures
+	errCodeServerError = -32000
+)
+
+// the messages of the range and result size limits, e.g. "query returned more than 10000 results" of Infura,
+// "exceed maximum block range: 5000" and "Log response size exceeded" of the others
+var rangeTooLargeMessages = []string{
+	"returned more than",
+	"block range",
+	"range too large",
+	"response size",
+	"too many results",
+}
+
+func (this *RPCError) Error() string {
+	return fmt.Sprintf("chain error | code: %d, message: %s", this.Code, this.Message)
+}
+
+// Is make the rate limit errors of JSON RPC match `ErrRateLimited`, the unknown filter errors match `ErrFilterNotFound`,
+// and the range or result size limit errors match `ErrRangeTooLarge`.
+// Some providers return the HTTP status code 429 as the JSON RPC error code.
+func (this *RPCError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		// `-32005` is also used for the result size limit by some providers, e.g. Infura
+		return (this.Code == errCodeLimitExceeded && !this.isRangeTooLarge()) || this.Code == http.StatusTooManyRequests
+	case ErrFilterNotFound:
+		// there is no dedicated code for it, `-32000` is used by geth
+		return strings.Contains(strings.ToLower(this.Message), "filter not found")
+	case ErrRangeTooLarge:
+		return this.isRangeTooLarge()
+	}
+	return false
+}
+
+// isRangeTooLarge check if it's the error of range or result size limit. There is no dedicated code for it, so the message is matched.
+func (this *RPCError) isRangeTooLarge() bool {
+	if this.Code != errCodeLimitExceeded && this.Code != errCodeServerError {
+		return false
+	}
+	message := strings.ToLower(this.Message)
+	for _, keyword := range rangeTooLargeMessages {
+		if strings.Contains(message, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusError is returned when the HTTP status is not 200. It matches `ErrBadStatus`, and `ErrRateLimited` for 429.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// the delay asked by the `Retry-After` header, 0 if it's not set
+	RetryAfter time.Duration
+}
+
+func (this *StatusError) Error() string {
+	return "status code not equal 200 | status: " + this.Status
+}
+
+func (this *StatusError) Is(target error) bool {
+	return target == ErrBadStatus || (target == ErrRateLimited && this.StatusCode == http.StatusTooManyRequests)
+}
+
+// timeoutError wraps the error of a timed out call, to match `ErrTimeout` while keeping the original one
+type timeoutError struct {
+	err error
+}
+
+func (this *timeoutError) Error() string {
+	return "timeout: " + this.err.Error()
+}
+
+func (this *timeoutError) Unwrap() error {
+	return this.err
+}
+
+func (this *timeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// IsRetryable check if a failed call may succeed by retrying later.
+// The invalid requests, the calls canceled by caller, the unknown filters, the ranges too large, and the HTTP 4xx errors except 408 and 429
+// are not retryable.
+// The unknown errors (e.g. network errors) are treated as retryable.
+func IsRetryable(err error) bool {
+
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrRangeTooLarge) {
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTimeout) {
+		return true
+	}
+	if errors.Is(err, ErrEmptyBatch) || errors.Is(err, ErrDuplicateRequestId) || errors.Is(err, ErrFilterNotFound) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError || statusErr.StatusCode == http.StatusRequestTimeout
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.Code {
+		case errCodeParseError, errCodeInvalidRequest, errCodeMethodNotFound, errCodeInvalidParams:
+			return false
+		}
+	}
+	return true
+}
+
+// wrapTransportError mark the timeout error of HTTP call as `ErrTimeout`
+func wrapTransportError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &timeoutError{err: err}
+	}
+	return err
+}
+
+// newStatusError construct the error of a non-200 response
+func newStatusError(resp *http.Response) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter parse the `Retry-After` header, in seconds or HTTP date. 0 is returned if it's invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,178 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "nil",
+			err:  nil,
+			want: false,
+		},
+		{
+			name: "rate limited by http status",
+			err:  &StatusError{StatusCode: http.StatusTooManyRequests},
+			want: true,
+		},
+		{
+			name: "rate limited by json rpc error",
+			err:  &RPCError{Code: errCodeLimitExceeded, Message: "limit exceeded"},
+			want: true,
+		},
+		{
+			name: "result size limit by json rpc error",
+			err:  &RPCError{Code: errCodeLimitExceeded, Message: "query returned more than 10000 results"},
+			want: false,
+		},
+		{
+			name: "block range limit",
+			err:  &RPCError{Code: -32000, Message: "exceed maximum block range: 5000"},
+			want: false,
+		},
+		{
+			name: "server error",
+			err:  &StatusError{StatusCode: http.StatusBadGateway},
+			want: true,
+		},
+		{
+			name: "bad request",
+			err:  &StatusError{StatusCode: http.StatusBadRequest},
+			want: false,
+		},
+		{
+			name: "timeout",
+			err:  wrapTransportError(context.DeadlineExceeded),
+			want: true,
+		},
+		{
+			name: "canceled",
+			err:  fmt.Errorf("call fail: %w", context.Canceled),
+			want: false,
+		},
+		{
+			name: "method not found",
+			err:  &RPCError{Code: errCodeMethodNotFound, Message: "the method trace_filter does not exist"},
+			want: false,
+		},
+		{
+			name: "invalid params",
+			err:  &RPCError{Code: errCodeInvalidParams, Message: "invalid params"},
+			want: false,
+		},
+		{
+			name: "internal error",
+			err:  &RPCError{Code: errCodeMissingResponse, Message: "response missing in batch"},
+			want: true,
+		},
+		{
+			name: "filter not found",
+			err:  &RPCError{Code: -32000, Message: "filter not found"},
+			want: false,
+		},
+		{
+			name: "duplicate request id",
+			err:  fmt.Errorf("%w: %s", ErrDuplicateRequestId, "1"),
+			want: false,
+		},
+		{
+			name: "unknown error",
+			err:  errors.New("connection reset by peer"),
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsRetryable(tt.err))
+		})
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+
+	var err error = &StatusError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"}
+	assert.True(t, errors.Is(err, ErrBadStatus))
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	assert.Equal(t, "status code not equal 200 | status: 429 Too Many Requests", err.Error())
+
+	err = &StatusError{StatusCode: http.StatusServiceUnavailable}
+	assert.True(t, errors.Is(err, ErrBadStatus))
+	assert.False(t, errors.Is(err, ErrRateLimited))
+
+	err = fmt.Errorf("batch fail: %w", &RPCError{Code: -32000, Message: "header not found"})
+	var rpcErr *RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, -32000, rpcErr.Code)
+	assert.False(t, errors.Is(err, ErrRateLimited))
+	assert.False(t, errors.Is(err, ErrFilterNotFound))
+	assert.True(t, errors.Is(&RPCError{Code: -32000, Message: "Filter not found"}, ErrFilterNotFound))
+	assert.False(t, errors.Is(err, ErrRangeTooLarge))
+
+	err = &RPCError{Code: errCodeLimitExceeded, Message: "query returned more than 10000 results"}
+	assert.True(t, errors.Is(err, ErrRangeTooLarge))
+	assert.False(t, errors.Is(err, ErrRateLimited))
+	assert.True(t, errors.Is(&RPCError{Code: errCodeLimitExceeded, Message: "limit exceeded"}, ErrRateLimited))
+	assert.False(t, errors.Is(&RPCError{Code: errCodeLimitExceeded, Message: "limit exceeded"}, ErrRangeTooLarge))
+	assert.True(t, errors.Is(&RPCError{Code: -32000, Message: "Log response size exceeded"}, ErrRangeTooLarge))
+
+	err = wrapTransportError(context.DeadlineExceeded)
+	assert.True(t, errors.Is(err, ErrTimeout))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.False(t, errors.Is(wrapTransportError(context.Canceled), ErrTimeout))
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  0,
+		},
+		{
+			name:  "seconds",
+			value: "3",
+			want:  3 * time.Second,
+		},
+		{
+			name:  "http date",
+			value: now.Add(10 * time.Second).Format(http.TimeFormat),
+			want:  10 * time.Second,
+		},
+		{
+			name:  "past date",
+			value: now.Add(-10 * time.Second).Format(http.TimeFormat),
+			want:  0,
+		},
+		{
+			name:  "invalid",
+			value: "soon",
+			want:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseRetryAfter(tt.value, now))
+		})
+	}
+}
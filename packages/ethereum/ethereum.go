@@ -1,21 +1,29 @@
 package ethereum
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/brofu/simple_ethereum_parser/packages/abi"
+)
 
 //go:generate mockgen -destination=../ethereum/mocks/mock_ethereum.go -package=mocks github.com/brofu/simple_ethereum_parser/packages/ethereum EthereumChainAccesser
 
 type Action struct {
-	From     string `json:"from"`
-	CallType string `json:"callType"`
-	Gas      string `json:"gas"`
-	Input    string `json:"input"`
-	To       string `json:"to"`
-	Value    string `json:"value"`
+	From     string   `json:"from"`
+	CallType string   `json:"callType"`
+	Gas      Quantity `json:"gas"`
+	Input    string   `json:"input"`
+	To       string   `json:"to"`
+	// in wei
+	Value BigInt `json:"value"`
 }
 
 type Result struct {
-	GasUsed string `json:"gasUsed"`
-	Output  string `json:"output"`
+	GasUsed Quantity `json:"gasUsed"`
+	Output  string   `json:"output"`
+	// the address of the created contract, for the transactions of type `create`
+	Address string `json:"address,omitempty"`
 }
 
 type Transaction struct {
@@ -28,6 +36,80 @@ type Transaction struct {
 	TransactionHash     string   `json:"transactionHash"`
 	TransactionPosition int      `json:"transactionPosition"`
 	Type                string   `json:"type"`
+	// the reason if the transaction fails, e.g. `Reverted`
+	Error string `json:"error,omitempty"`
+	// the outcome from the receipt of the transaction, nil if it's not enriched.
+	// It's of the whole transaction, so it's only attached to the top level call. The internal calls don't carry it,
+	// otherwise the fee would be counted once per call.
+	Receipt *ReceiptSummary `json:"receipt,omitempty"`
+	// the unix time of the block in seconds, 0 if it's not attached
+	Timestamp uint64 `json:"timestamp,omitempty"`
+	// the call decoded by the ABI of the contract, nil if the ABI is not registered.
+	// It's attached when the transactions are queried, instead of being stored.
+	Decoded *abi.DecodedCall `json:"decoded,omitempty"`
+}
+
+// IsTopLevel check if it's the top level call of the transaction, instead of an internal call
+func (this Transaction) IsTopLevel() bool {
+	return len(this.TraceAddress) == 0
+}
+
+// Block is the header fields of a block returned by `eth_getBlockByNumber`
+// The numeric fields are kept as hex string, as they are returned from chain.
+type Block struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// BlockWithTransactions is a block returned by `eth_getBlockByNumber`, with the full transaction objects
+type BlockWithTransactions struct {
+	Block
+	Transactions []BlockTransaction `json:"transactions"`
+}
+
+// BlockTransaction is the transaction object in a block. The block number and index are kept as hex string.
+// They are empty if the transaction is pending.
+type BlockTransaction struct {
+	Hash             string   `json:"hash"`
+	BlockHash        string   `json:"blockHash"`
+	BlockNumber      string   `json:"blockNumber"`
+	TransactionIndex string   `json:"transactionIndex"`
+	From             string   `json:"from"`
+	To               string   `json:"to"`
+	Gas              Quantity `json:"gas"`
+	GasPrice         BigInt   `json:"gasPrice"`
+	Value            BigInt   `json:"value"`
+	Input            string   `json:"input"`
+}
+
+// Receipt is returned by `eth_getTransactionReceipt`. The block number and status are kept as hex string.
+type Receipt struct {
+	TransactionHash   string   `json:"transactionHash"`
+	BlockHash         string   `json:"blockHash"`
+	BlockNumber       string   `json:"blockNumber"`
+	From              string   `json:"from"`
+	To                string   `json:"to"`
+	ContractAddress   string   `json:"contractAddress"`
+	GasUsed           Quantity `json:"gasUsed"`
+	EffectiveGasPrice BigInt   `json:"effectiveGasPrice"`
+	// `0x1` for success, `0x0` for failure
+	Status string `json:"status"`
+	Logs   []Log  `json:"logs"`
+}
+
+// Log is an event log emitted by a contract
+type Log struct {
+	Address          string   `json:"address"`
+	Topics           []string `json:"topics"`
+	Data             string   `json:"data"`
+	BlockNumber      string   `json:"blockNumber"`
+	BlockHash        string   `json:"blockHash"`
+	TransactionHash  string   `json:"transactionHash"`
+	TransactionIndex string   `json:"transactionIndex"`
+	LogIndex         string   `json:"logIndex"`
+	Removed          bool     `json:"removed"`
 }
 
 type EthGetCurrentBlockNumberRequest struct {
@@ -42,7 +124,104 @@ type EthGetCurrentTransactionsByAddressRequest struct {
 	RequestId   string `json:"request_id"`
 }
 
+// EthGetCurrentTransactionsByAddressesRequest query the transactions of several addresses in one call.
+// A transaction is returned if it's sent from or to any of the addresses.
+type EthGetCurrentTransactionsByAddressesRequest struct {
+	FromBlock string   `json:"from_block"`
+	ToBlock   string   `json:"to_block"`
+	Addresses []string `json:"addresses"`
+	RequestId string   `json:"request_id"`
+}
+
+// EthGetCurrentTransactionsByAddressResult is the result of one request in batch call
+type EthGetCurrentTransactionsByAddressResult struct {
+	Transactions []Transaction
+	Err          error
+}
+
+// EthGetTransactionReceiptResult is the result of one request in batch call. `Err` is `ErrReceiptNotFound` if it's not mined.
+type EthGetTransactionReceiptResult struct {
+	Receipt *Receipt
+	Err     error
+}
+
+type EthGetBlockByNumberRequest struct {
+	BlockNumber string `json:"block_number"`
+	RequestId   string `json:"request_id"`
+}
+
+type EthGetTransactionReceiptRequest struct {
+	TransactionHash string `json:"transaction_hash"`
+	RequestId       string `json:"request_id"`
+}
+
+// EthGetLogsRequest query the logs in the block range
+type EthGetLogsRequest struct {
+	FromBlock string `json:"from_block"`
+	ToBlock   string `json:"to_block"`
+	// the contracts emitting the logs. The logs of all contracts are returned if it's empty.
+	Addresses []string `json:"addresses"`
+	// the topic filters by position. `nil` matches any topic in that position, and a list matches any of the topics in it.
+	Topics    [][]string `json:"topics"`
+	RequestId string     `json:"request_id"`
+}
+
+// EthGetBalanceRequest query the balance of an address, in wei
+type EthGetBalanceRequest struct {
+	Address string `json:"address"`
+	// the block number in hex, or a tag like `latest`. `BlockTagLatest` is used if it's empty.
+	BlockNumber string `json:"block_number"`
+	RequestId   string `json:"request_id"`
+}
+
+// EthGetTransactionCountRequest query the nonce of an address, which is the number of transactions sent from it
+type EthGetTransactionCountRequest struct {
+	Address string `json:"address"`
+	// the block number in hex, or a tag like `latest`. `BlockTagLatest` is used if it's empty.
+	BlockNumber string `json:"block_number"`
+	RequestId   string `json:"request_id"`
+}
+
+type EthGetTransactionByHashRequest struct {
+	TransactionHash string `json:"transaction_hash"`
+	RequestId       string `json:"request_id"`
+}
+
+type EthNewPendingTransactionFilterRequest struct {
+	// get the full transaction objects from the filter, instead of the hashes. It's not supported by the old nodes.
+	FullTransactions bool   `json:"full_transactions"`
+	RequestId        string `json:"request_id"`
+}
+
+// EthGetFilterChangesRequest poll a filter. The changes are hashes, transaction objects or logs, depending on the filter.
+type EthGetFilterChangesRequest struct {
+	FilterId  string `json:"filter_id"`
+	RequestId string `json:"request_id"`
+}
+
+type EthUninstallFilterRequest struct {
+	FilterId  string `json:"filter_id"`
+	RequestId string `json:"request_id"`
+}
+
 type EthereumChainAccesser interface {
 	EthGetCurrentTransactionsByAddress(context.Context, *EthGetCurrentTransactionsByAddressRequest) ([]Transaction, error)
+	EthGetCurrentTransactionsByAddresses(context.Context, *EthGetCurrentTransactionsByAddressesRequest) ([]Transaction, error)
 	EthGetCurrentBlockNumber(context.Context, *EthGetCurrentBlockNumberRequest) (int, error)
+	EthGetBlockByNumber(context.Context, *EthGetBlockByNumberRequest) (*Block, error)
+	EthGetBlockWithTransactionsByNumber(context.Context, *EthGetBlockByNumberRequest) (*BlockWithTransactions, error)
+	EthGetTransactionReceipt(context.Context, *EthGetTransactionReceiptRequest) (*Receipt, error)
+	EthGetLogs(context.Context, *EthGetLogsRequest) ([]Log, error)
+	EthGetBalance(context.Context, *EthGetBalanceRequest) (BigInt, error)
+	EthGetTransactionCount(context.Context, *EthGetTransactionCountRequest) (Quantity, error)
+	EthGetTransactionByHash(context.Context, *EthGetTransactionByHashRequest) (*BlockTransaction, error)
+	// the filter is installed on one node. The caller should install it again if `ErrFilterNotFound` is returned.
+	EthNewPendingTransactionFilter(context.Context, *EthNewPendingTransactionFilterRequest) (string, error)
+	EthGetFilterChanges(context.Context, *EthGetFilterChangesRequest) ([]json.RawMessage, error)
+	EthUninstallFilter(context.Context, *EthUninstallFilterRequest) (bool, error)
+	// EthGetCurrentTransactionsByAddressBatch send the requests in one call. The results are in the same order of the requests.
+	// The error is returned only if the whole call fails, otherwise the error of each request is kept in its result.
+	EthGetCurrentTransactionsByAddressBatch(context.Context, []*EthGetCurrentTransactionsByAddressRequest) ([]EthGetCurrentTransactionsByAddressResult, error)
+	// EthGetTransactionReceiptBatch get the receipts in one call, in the same way as `EthGetCurrentTransactionsByAddressBatch`
+	EthGetTransactionReceiptBatch(context.Context, []*EthGetTransactionReceiptRequest) ([]EthGetTransactionReceiptResult, error)
 }
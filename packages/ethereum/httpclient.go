@@ -2,12 +2,16 @@ package ethereum
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/brofu/simple_ethereum_parser/packages/logging"
 )
@@ -20,6 +24,34 @@ var (
 	JsonRpcVersion              = "2.0"
 	MethodTraceFilter           = "trace_filter"
 	MethodGetCurrentBlockNumber = "eth_blockNumber"
+	MethodGetBlockByNumber      = "eth_getBlockByNumber"
+	MethodGetTransactionReceipt = "eth_getTransactionReceipt"
+	MethodGetLogs               = "eth_getLogs"
+	MethodGetBalance            = "eth_getBalance"
+	MethodGetTransactionCount   = "eth_getTransactionCount"
+	MethodGetTransactionByHash  = "eth_getTransactionByHash"
+
+	MethodNewPendingTransactionFilter = "eth_newPendingTransactionFilter"
+	MethodGetFilterChanges            = "eth_getFilterChanges"
+	MethodUninstallFilter             = "eth_uninstallFilter"
+
+	// the block tag of the latest block
+	BlockTagLatest = "latest"
+
+	ErrBlockNotFound      = errors.New("block not found")
+	ErrReceiptNotFound    = errors.New("receipt not found")
+	ErrEmptyBatch         = errors.New("empty batch")
+	ErrDuplicateRequestId = errors.New("duplicate request id in batch")
+	ErrNullResult         = errors.New("null result")
+	// ErrTransactionNotFound is returned if the transaction is neither mined nor in the mempool
+	ErrTransactionNotFound = errors.New("transaction not found")
+)
+
+const (
+	// the name used in logs of batch call
+	methodBatch = "batch"
+	// the error code set to the request whose response is missing in batch call. It's the `Internal error` of JSON RPC 2.0
+	errCodeMissingResponse = -32603
 )
 
 type JsonRpcTraceFilterParams struct {
@@ -29,6 +61,13 @@ type JsonRpcTraceFilterParams struct {
 	ToAddress   []string
 }
 
+type JsonRpcLogFilterParams struct {
+	FromBlock string     `json:"fromBlock"`
+	ToBlock   string     `json:"toBlock"`
+	Address   []string   `json:"address,omitempty"`
+	Topics    [][]string `json:"topics,omitempty"`
+}
+
 type RPCRequest struct {
 	Jsonrpc string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
@@ -37,10 +76,11 @@ type RPCRequest struct {
 }
 
 type RPCResponse struct {
-	Jsonrpc string      `json:"jsonrpc"`
-	Result  interface{} `json:"result,omitempty"`
-	Error   *RPCError   `json:"error,omitempty"`
-	ID      interface{} `json:"id"`
+	Jsonrpc string `json:"jsonrpc"`
+	// the raw result, which is decoded by the caller into the typed one
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *RPCError       `json:"error,omitempty"`
+	ID     interface{}     `json:"id"`
 }
 
 type RPCError struct {
@@ -51,152 +91,554 @@ type RPCError struct {
 // EthJsonRpcClient implements interface `EthereumChainAccesser`
 // It is based on HTTP and JsonRPC 2.0
 type EthJsonRpcClient struct {
-	entryPoint string
-	logger     logging.Logger
+	// the ID of the last request sent by `Call`. It's the first field, to be 64-bit aligned for atomic operations.
+	lastRequestId uint64
+	entryPoint    string
+	logger        logging.Logger
+	retryPolicy   RetryPolicy
+	httpClient    *http.Client
+	// the extra headers of each request, including the authentication
+	headers http.Header
+	gzip    bool
+	// nil if the requests are not throttled
+	throttle *throttle
 }
 
-func NewEthJsonRpcClient(entryPoint string, logger logging.Logger) EthereumChainAccesser {
+// NewEthJsonRpcClient construct a client with `options`.
+// By default, the client retries with `DefaultRetryPolicy`, and reuses the connections of a dedicated transport.
+func NewEthJsonRpcClient(entryPoint string, logger logging.Logger, options ...ClientOption) EthereumChainAccesser {
+
+	opts := &clientOptions{
+		retryPolicy: DefaultRetryPolicy,
+		headers:     http.Header{},
+	}
+	for _, option := range options {
+		option(opts)
+	}
+
 	return &EthJsonRpcClient{
-		entryPoint: entryPoint,
-		logger:     logger,
+		entryPoint:  entryPoint,
+		logger:      logger,
+		retryPolicy: opts.retryPolicy,
+		httpClient:  opts.buildHTTPClient(),
+		headers:     opts.headers,
+		gzip:        opts.gzip,
+		throttle:    newThrottle(opts.throttle, logger),
 	}
 }
 
+// ThrottleStats get the state and counters of the throttle. `false` is returned if it's not throttled.
+func (this *EthJsonRpcClient) ThrottleStats() (ThrottleStats, bool) {
+	if this.throttle == nil {
+		return ThrottleStats{}, false
+	}
+	return this.throttle.stats(), true
+}
+
 // EthGetCurrentBlockNumber get the block number
-// TODO: Refactor these 2 functions
 func (this *EthJsonRpcClient) EthGetCurrentBlockNumber(ctx context.Context, req *EthGetCurrentBlockNumberRequest) (int, error) {
 
-	r := RPCRequest{
-		Jsonrpc: JsonRpcVersion,
-		Method:  MethodGetCurrentBlockNumber,
-		ID:      req.RequestId,
+	var bn Quantity
+	if err := this.call(ctx, MethodGetCurrentBlockNumber, nil, req.RequestId, &bn); err != nil {
+		return 0, err
 	}
+	return int(bn), nil
+}
 
-	rawReq, err := json.Marshal(r)
-	if err != nil {
-		this.logger.Errorf("marshal data fail | method: %s, err: %s", MethodGetCurrentBlockNumber, err.Error())
-		return 0, err
+func (this *EthJsonRpcClient) EthGetCurrentTransactionsByAddress(ctx context.Context, req *EthGetCurrentTransactionsByAddressRequest) ([]Transaction, error) {
+
+	params := []JsonRpcTraceFilterParams{
+		{
+			FromBlock:   req.FromBlock,
+			ToBlock:     req.ToBlock,
+			FromAddress: []string{req.FromAddress},
+			ToAddress:   []string{req.ToAddress},
+		},
 	}
 
-	httpReq, err := constructHttpRequest(ctx, http.MethodPost, this.entryPoint, contentType, bytes.NewBuffer(rawReq))
-	if err != nil {
-		this.logger.Errorf("construct request fail | method: %s, err: %s", MethodGetCurrentBlockNumber, err.Error())
-		return 0, err
+	var res []Transaction
+	if err := this.call(ctx, MethodTraceFilter, params, req.RequestId, &res); err != nil && !errors.Is(err, ErrNullResult) {
+		return nil, err
 	}
+	return res, nil
+}
 
-	resp, err := http.DefaultClient.Do(httpReq)
-	if err != nil {
-		this.logger.Errorf("chain call fail | method: %s, err: %s", MethodGetCurrentBlockNumber, err.Error())
-		return 0, err
+// EthGetCurrentTransactionsByAddresses get the transactions of several addresses with one `trace_filter` call
+func (this *EthJsonRpcClient) EthGetCurrentTransactionsByAddresses(ctx context.Context, req *EthGetCurrentTransactionsByAddressesRequest) ([]Transaction, error) {
+
+	params := []JsonRpcTraceFilterParams{
+		{
+			FromBlock:   req.FromBlock,
+			ToBlock:     req.ToBlock,
+			FromAddress: req.Addresses,
+			ToAddress:   req.Addresses,
+		},
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		this.logger.Errorf("chain call fail | method: %s, StatusCode: %d", MethodGetCurrentBlockNumber, resp.StatusCode)
-		return 0, errors.New("status code not equal 200 | status: " + resp.Status)
+	var res []Transaction
+	if err := this.call(ctx, MethodTraceFilter, params, req.RequestId, &res); err != nil && !errors.Is(err, ErrNullResult) {
+		return nil, err
 	}
+	return res, nil
+}
+
+// EthGetBlockByNumber get the header of a block. `ErrBlockNotFound` is returned if the block is not on chain yet.
+func (this *EthJsonRpcClient) EthGetBlockByNumber(ctx context.Context, req *EthGetBlockByNumberRequest) (*Block, error) {
 
-	rawData, err := io.ReadAll(resp.Body)
+	// the 2nd param `false` means only the hashes of the transactions are returned
+	block := &Block{}
+	err := this.call(ctx, MethodGetBlockByNumber, []interface{}{req.BlockNumber, false}, req.RequestId, block)
+	if errors.Is(err, ErrNullResult) {
+		return nil, ErrBlockNotFound
+	}
 	if err != nil {
-		this.logger.Errorf("read response data fail| method: %s, err: %s", MethodGetCurrentBlockNumber, err.Error())
-		return 0, err
+		return nil, err
 	}
+	return block, nil
+}
+
+// EthGetBlockWithTransactionsByNumber get a block with the full transaction objects.
+// `ErrBlockNotFound` is returned if the block is not on chain yet.
+func (this *EthJsonRpcClient) EthGetBlockWithTransactionsByNumber(ctx context.Context, req *EthGetBlockByNumberRequest) (*BlockWithTransactions, error) {
 
-	data := RPCResponse{}
-	err = json.Unmarshal(rawData, &data)
+	block := &BlockWithTransactions{}
+	err := this.call(ctx, MethodGetBlockByNumber, []interface{}{req.BlockNumber, true}, req.RequestId, block)
+	if errors.Is(err, ErrNullResult) {
+		return nil, ErrBlockNotFound
+	}
 	if err != nil {
-		this.logger.Errorf("unmarshal response data fail | method: %s, err: %s", MethodGetCurrentBlockNumber, err)
-		return 0, err
+		return nil, err
+	}
+	return block, nil
+}
+
+// EthGetTransactionReceipt get the receipt of a transaction. `ErrReceiptNotFound` is returned if the transaction is not mined yet.
+func (this *EthJsonRpcClient) EthGetTransactionReceipt(ctx context.Context, req *EthGetTransactionReceiptRequest) (*Receipt, error) {
+
+	receipt := &Receipt{}
+	err := this.call(ctx, MethodGetTransactionReceipt, []interface{}{req.TransactionHash}, req.RequestId, receipt)
+	if errors.Is(err, ErrNullResult) {
+		return nil, ErrReceiptNotFound
 	}
-	bnString := data.Result.(string)
-	bnInt, err := strconv.ParseInt(bnString, 0, 64)
 	if err != nil {
-		this.logger.Errorf("convert block number fail | bnString: %s, err: %s", bnString, err)
-		return 0, err
+		return nil, err
 	}
-	return int(bnInt), nil
+	return receipt, nil
 }
 
-func (this *EthJsonRpcClient) EthGetCurrentTransactionsByAddress(ctx context.Context, req *EthGetCurrentTransactionsByAddressRequest) ([]Transaction, error) {
+// EthGetLogs get the logs matching the filter in `req`
+func (this *EthJsonRpcClient) EthGetLogs(ctx context.Context, req *EthGetLogsRequest) ([]Log, error) {
 
-	params := []JsonRpcTraceFilterParams{
+	params := []JsonRpcLogFilterParams{
 		{
-			FromBlock:   req.FromBlock,
-			ToBlock:     req.ToBlock,
-			FromAddress: []string{req.FromAddress},
-			ToAddress:   []string{req.ToAddress},
+			FromBlock: req.FromBlock,
+			ToBlock:   req.ToBlock,
+			Address:   req.Addresses,
+			Topics:    req.Topics,
 		},
 	}
 
-	rawParams, err := json.Marshal(params)
+	var logs []Log
+	if err := this.call(ctx, MethodGetLogs, params, req.RequestId, &logs); err != nil && !errors.Is(err, ErrNullResult) {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// EthGetBalance get the balance of an address, in wei
+func (this *EthJsonRpcClient) EthGetBalance(ctx context.Context, req *EthGetBalanceRequest) (BigInt, error) {
 
+	var balance BigInt
+	if err := this.call(ctx, MethodGetBalance, []interface{}{req.Address, blockTag(req.BlockNumber)}, req.RequestId, &balance); err != nil {
+		return BigInt{}, err
+	}
+	return balance, nil
+}
+
+// EthGetTransactionCount get the nonce of an address
+func (this *EthJsonRpcClient) EthGetTransactionCount(ctx context.Context, req *EthGetTransactionCountRequest) (Quantity, error) {
+
+	var nonce Quantity
+	if err := this.call(ctx, MethodGetTransactionCount, []interface{}{req.Address, blockTag(req.BlockNumber)}, req.RequestId, &nonce); err != nil {
+		return 0, err
+	}
+	return nonce, nil
+}
+
+func (this *EthJsonRpcClient) EthGetTransactionByHash(ctx context.Context, req *EthGetTransactionByHashRequest) (*BlockTransaction, error) {
+
+	trx := &BlockTransaction{}
+	err := this.call(ctx, MethodGetTransactionByHash, []interface{}{req.TransactionHash}, req.RequestId, trx)
+	if errors.Is(err, ErrNullResult) {
+		return nil, ErrTransactionNotFound
+	}
 	if err != nil {
-		this.logger.Errorf("marshal params fail | method: %s, err: %s", MethodTraceFilter, err.Error())
 		return nil, err
 	}
+	return trx, nil
+}
+
+// EthNewPendingTransactionFilter install a filter of the pending transactions, and return the filter id
+func (this *EthJsonRpcClient) EthNewPendingTransactionFilter(ctx context.Context, req *EthNewPendingTransactionFilterRequest) (string, error) {
+
+	params := []interface{}{}
+	if req.FullTransactions { // the old nodes reject any param
+		params = append(params, true)
+	}
+	var filterId string
+	if err := this.call(ctx, MethodNewPendingTransactionFilter, params, req.RequestId, &filterId); err != nil {
+		return "", err
+	}
+	return filterId, nil
+}
+
+// EthGetFilterChanges get the changes of a filter since last poll. Error `ErrFilterNotFound` is returned if the filter is expired.
+func (this *EthJsonRpcClient) EthGetFilterChanges(ctx context.Context, req *EthGetFilterChangesRequest) ([]json.RawMessage, error) {
+
+	var changes []json.RawMessage
+	err := this.call(ctx, MethodGetFilterChanges, []interface{}{req.FilterId}, req.RequestId, &changes)
+	if err != nil && !errors.Is(err, ErrNullResult) {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (this *EthJsonRpcClient) EthUninstallFilter(ctx context.Context, req *EthUninstallFilterRequest) (bool, error) {
+
+	var uninstalled bool
+	if err := this.call(ctx, MethodUninstallFilter, []interface{}{req.FilterId}, req.RequestId, &uninstalled); err != nil {
+		return false, err
+	}
+	return uninstalled, nil
+}
+
+// Call send a JSON RPC request with `params`, and decode the result into `out`, which should be a pointer.
+// `ErrNullResult` is returned if the result is null, e.g. the block is not found. `out` can be nil if the result is not needed.
+func (this *EthJsonRpcClient) Call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	requestId := strconv.FormatUint(atomic.AddUint64(&this.lastRequestId, 1), 10)
+	return this.call(ctx, method, params, requestId, out)
+}
+
+// call send a JSON RPC request, and decode the result into `out`
+func (this *EthJsonRpcClient) call(ctx context.Context, method string, params interface{}, requestId string, out interface{}) error {
+
+	// some nodes reject the null params
+	if params == nil {
+		params = []interface{}{}
+	}
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		this.logger.Errorf("marshal params fail | method: %s, err: %s", method, err.Error())
+		return err
+	}
 
 	r := RPCRequest{
 		Jsonrpc: JsonRpcVersion,
-		Method:  MethodTraceFilter,
+		Method:  method,
 		Params:  rawParams,
-		ID:      req.RequestId,
+		ID:      requestId,
 	}
 	rawReq, err := json.Marshal(r)
 	if err != nil {
-		this.logger.Errorf("marshal data fail | method: %s, err: %s", MethodTraceFilter, err.Error())
-		return nil, err
+		this.logger.Errorf("marshal data fail | method: %s, err: %s", method, err.Error())
+		return err
 	}
 
-	httpReq, err := constructHttpRequest(ctx, http.MethodPost, this.entryPoint, contentType, bytes.NewBuffer(rawReq))
+	var data *RPCResponse
+	err = this.retry(ctx, method, func() error {
+		rawData, err := this.post(ctx, method, rawReq, 1)
+		if err != nil {
+			return err
+		}
+
+		data = &RPCResponse{}
+		if err := json.Unmarshal(rawData, data); err != nil {
+			this.logger.Errorf("unmarshal response data fail | method: %s, err: %s", method, err)
+			return err
+		}
+		if data.Error != nil {
+			this.logger.Errorf("get error from chain | method: %s, err code: %d, err msg: %s", method, data.Error.Code, data.Error.Message)
+			return data.Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := decodeResult(data.Result, out); err != nil {
+		if !errors.Is(err, ErrNullResult) {
+			this.logger.Errorf("unmarshal result fail | method: %s, err: %s", method, err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// Batch send the requests in one JSON RPC 2.0 batch call.
+// The responses are matched back by `ID`, and returned in the same order of the requests, so the IDs should be unique.
+// The error of a single request is kept in its response. If the response of a request is missing, `Error` of it is set.
+func (this *EthJsonRpcClient) Batch(ctx context.Context, requests []RPCRequest) ([]RPCResponse, error) {
+
+	if len(requests) == 0 {
+		return nil, ErrEmptyBatch
+	}
+
+	index := make(map[string]int, len(requests))
+	for i, r := range requests {
+		key, err := batchKey(r.ID)
+		if err != nil {
+			this.logger.Errorf("marshal request id fail | id: %v, err: %s", r.ID, err.Error())
+			return nil, err
+		}
+		if _, ok := index[key]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateRequestId, key)
+		}
+		index[key] = i
+	}
+
+	rawReq, err := json.Marshal(requests)
 	if err != nil {
-		this.logger.Errorf("construct request fail | method: %s, err: %s", MethodTraceFilter, err.Error())
+		this.logger.Errorf("marshal data fail | method: %s, err: %s", methodBatch, err.Error())
 		return nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	var rawData []byte
+	err = this.retry(ctx, methodBatch, func() error {
+		rawData, err = this.post(ctx, methodBatch, rawReq, len(requests))
+		if err != nil {
+			return err
+		}
+
+		// the whole batch is rejected, a single response is returned in this case
+		if trimmed := bytes.TrimSpace(rawData); len(trimmed) > 0 && trimmed[0] == '{' {
+			data := &RPCResponse{}
+			if err := json.Unmarshal(trimmed, data); err != nil {
+				this.logger.Errorf("unmarshal response data fail | method: %s, err: %s", methodBatch, err)
+				return err
+			}
+			if data.Error == nil {
+				return errors.New("unexpected single response of batch call")
+			}
+			this.logger.Errorf("get error from chain | method: %s, err code: %d, err msg: %s", methodBatch, data.Error.Code, data.Error.Message)
+			return data.Error
+		}
+		return nil
+	})
 	if err != nil {
-		this.logger.Errorf("chain call fail | method: %s, err: %s", MethodTraceFilter, err.Error())
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		this.logger.Errorf("chain call fail | method: %s, StatusCode: %s", MethodTraceFilter, resp.StatusCode)
-		return nil, errors.New("status code not equal 200 | status: " + resp.Status)
+	var data []RPCResponse
+	if err := json.Unmarshal(rawData, &data); err != nil {
+		this.logger.Errorf("unmarshal response data fail | method: %s, err: %s", methodBatch, err)
+		return nil, err
 	}
 
-	rawData, err := io.ReadAll(resp.Body)
+	responses := make([]RPCResponse, len(requests))
+	found := make([]bool, len(requests))
+	for _, resp := range data {
+		key, err := batchKey(resp.ID)
+		if err != nil {
+			continue
+		}
+		i, ok := index[key]
+		if !ok || found[i] { // unknown or duplicated response
+			this.logger.Errorf("unexpected response of batch call | id: %s", key)
+			continue
+		}
+		responses[i] = resp
+		found[i] = true
+	}
+	for i := range responses {
+		if !found[i] {
+			responses[i] = RPCResponse{
+				Jsonrpc: JsonRpcVersion,
+				ID:      requests[i].ID,
+				Error:   &RPCError{Code: errCodeMissingResponse, Message: "response missing in batch"},
+			}
+		}
+	}
+	return responses, nil
+}
+
+// EthGetCurrentTransactionsByAddressBatch get the transactions of several requests in one batch call
+func (this *EthJsonRpcClient) EthGetCurrentTransactionsByAddressBatch(ctx context.Context, reqs []*EthGetCurrentTransactionsByAddressRequest) ([]EthGetCurrentTransactionsByAddressResult, error) {
+
+	requests := make([]RPCRequest, 0, len(reqs))
+	for _, req := range reqs {
+		rawParams, err := json.Marshal([]JsonRpcTraceFilterParams{
+			{
+				FromBlock:   req.FromBlock,
+				ToBlock:     req.ToBlock,
+				FromAddress: []string{req.FromAddress},
+				ToAddress:   []string{req.ToAddress},
+			},
+		})
+		if err != nil {
+			this.logger.Errorf("marshal params fail | method: %s, err: %s", MethodTraceFilter, err.Error())
+			return nil, err
+		}
+		requests = append(requests, RPCRequest{
+			Jsonrpc: JsonRpcVersion,
+			Method:  MethodTraceFilter,
+			Params:  rawParams,
+			ID:      req.RequestId,
+		})
+	}
+
+	responses, err := this.Batch(ctx, requests)
 	if err != nil {
-		this.logger.Errorf("read response data fail| method: %s, err: %s", MethodTraceFilter, err.Error())
 		return nil, err
 	}
 
-	data := &RPCResponse{}
-	err = json.Unmarshal(rawData, data)
+	results := make([]EthGetCurrentTransactionsByAddressResult, len(responses))
+	for i, resp := range responses {
+		if resp.Error != nil {
+			this.logger.Errorf("get error from chain | method: %s, id: %v, err code: %d, err msg: %s", MethodTraceFilter, resp.ID, resp.Error.Code, resp.Error.Message)
+			results[i].Err = resp.Error
+			continue
+		}
+		err := decodeResult(resp.Result, &results[i].Transactions)
+		if err != nil && !errors.Is(err, ErrNullResult) {
+			this.logger.Errorf("converting transaction data fail | err: %s", err.Error())
+			results[i].Err = err
+		}
+	}
+	return results, nil
+}
+
+// EthGetTransactionReceiptBatch get the receipts of several transactions in one batch call. `ErrReceiptNotFound` is set for the ones not mined yet.
+func (this *EthJsonRpcClient) EthGetTransactionReceiptBatch(ctx context.Context, reqs []*EthGetTransactionReceiptRequest) ([]EthGetTransactionReceiptResult, error) {
+
+	requests := make([]RPCRequest, 0, len(reqs))
+	for _, req := range reqs {
+		rawParams, err := json.Marshal([]string{req.TransactionHash})
+		if err != nil {
+			this.logger.Errorf("marshal params fail | method: %s, err: %s", MethodGetTransactionReceipt, err.Error())
+			return nil, err
+		}
+		requests = append(requests, RPCRequest{
+			Jsonrpc: JsonRpcVersion,
+			Method:  MethodGetTransactionReceipt,
+			Params:  rawParams,
+			ID:      req.RequestId,
+		})
+	}
+
+	responses, err := this.Batch(ctx, requests)
 	if err != nil {
-		this.logger.Errorf("unmarshal response data fail | method: %s, err: %s", MethodTraceFilter, err)
 		return nil, err
 	}
-	if data.Error != nil {
-		this.logger.Errorf("get error from chain | method: %s, err code: %d, err msg: %s", MethodTraceFilter, data.Error.Code, data.Error.Message)
-		return nil, err
+
+	results := make([]EthGetTransactionReceiptResult, len(responses))
+	for i, resp := range responses {
+		if resp.Error != nil {
+			this.logger.Errorf("get error from chain | method: %s, id: %v, err code: %d, err msg: %s", MethodGetTransactionReceipt, resp.ID, resp.Error.Code, resp.Error.Message)
+			results[i].Err = resp.Error
+			continue
+		}
+		receipt := &Receipt{}
+		err := decodeResult(resp.Result, receipt)
+		if errors.Is(err, ErrNullResult) {
+			results[i].Err = ErrReceiptNotFound
+			continue
+		}
+		if err != nil {
+			this.logger.Errorf("converting receipt data fail | err: %s", err.Error())
+			results[i].Err = err
+			continue
+		}
+		results[i].Receipt = receipt
+	}
+	return results, nil
+}
+
+// post send the raw request to chain, and return the raw response data.
+// It waits for the throttle if it's set, and `calls` is the number of calls in the request, e.g. the size of batch.
+func (this *EthJsonRpcClient) post(ctx context.Context, method string, rawReq []byte, calls int) ([]byte, error) {
+
+	if this.throttle != nil {
+		if err := this.throttle.acquire(ctx, method, calls); err != nil {
+			return nil, err
+		}
+		defer this.throttle.release()
 	}
 
-	// if get correct response from chain,
-	// usually, there should be NO error for the following steps.
-	rawTrx, err := json.Marshal(data.Result)
+	httpReq, err := constructHttpRequest(ctx, http.MethodPost, this.entryPoint, contentType, bytes.NewBuffer(rawReq))
 	if err != nil {
-		this.logger.Errorf("converting transaction data fail | err: %s", err.Error())
+		this.logger.Errorf("construct request fail | method: %s, err: %s", method, err.Error())
 		return nil, err
 	}
-	var res []Transaction
-	err = json.Unmarshal(rawTrx, &res)
+	for key, values := range this.headers {
+		httpReq.Header[key] = values
+	}
+	if this.gzip {
+		httpReq.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	httpClient := this.httpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(httpReq)
 	if err != nil {
-		this.logger.Errorf("converting transaction data fail | err: %s", err.Error())
-		return nil, err
+		this.logger.Errorf("chain call fail | method: %s, err: %s", method, err.Error())
+		return nil, wrapTransportError(err)
 	}
+	defer resp.Body.Close()
 
-	return res, nil
+	if resp.StatusCode != http.StatusOK {
+		this.logger.Errorf("chain call fail | method: %s, StatusCode: %d", method, resp.StatusCode)
+		return nil, newStatusError(resp)
+	}
+
+	// the transport decodes the body only if it asks for gzip by itself
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			this.logger.Errorf("decode gzip response fail | method: %s, err: %s", method, err.Error())
+			return nil, wrapTransportError(err)
+		}
+		defer gzipReader.Close()
+		body = gzipReader
+	}
+
+	rawData, err := io.ReadAll(body)
+	if err != nil {
+		this.logger.Errorf("read response data fail| method: %s, err: %s", method, err.Error())
+		return nil, wrapTransportError(err)
+	}
+	return rawData, nil
+}
+
+// decodeResult decode the raw result into `out`. `ErrNullResult` is returned if it's null or missing.
+func decodeResult(raw json.RawMessage, out interface{}) error {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return ErrNullResult
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// blockTag return the block param, `BlockTagLatest` if it's not set
+func blockTag(blockNumber string) string {
+	if blockNumber == "" {
+		return BlockTagLatest
+	}
+	return blockNumber
+}
+
+// batchKey is the key to match the response of batch call. The ID is compared in JSON form, since its type is changed by decoding.
+func batchKey(id interface{}) (string, error) {
+	raw, err := json.Marshal(id)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
 }
 
 func constructHttpRequest(ctx context.Context, method, url, contentType string, body io.Reader) (*http.Request, error) {
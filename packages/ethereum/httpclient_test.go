@@ -6,10 +6,15 @@ package ethereum
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -94,3 +99,381 @@ func TestEthJsonRpcClient_EthGetCurrentTransactionsByAddress(t *testing.T) {
 		})
 	}
 }
+
+func TestEthJsonRpcClient_EthGetCurrentTransactionsByAddresses(t *testing.T) {
+	this := &EthJsonRpcClient{
+		entryPoint: testEntryPoint,
+	}
+
+	req := &EthGetCurrentTransactionsByAddressesRequest{
+		FromBlock: "0x64",
+		ToBlock:   "0xc8",
+		Addresses: []string{"0xffff", "0xfffe"},
+		RequestId: "1024",
+	}
+	got, err := this.EthGetCurrentTransactionsByAddresses(context.Background(), req)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 4, len(got))
+	assert.Equal(t, "0xffff", got[0].Action.From)
+	assert.Equal(t, "0xfffe", got[2].Action.From)
+}
+
+func TestEthJsonRpcClient_EthGetBlockByNumber(t *testing.T) {
+	type args struct {
+		context context.Context
+		req     *EthGetBlockByNumberRequest
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Block
+		wantErr bool
+	}{
+		{
+			name: "normal case 1",
+			args: args{
+				context: context.Background(),
+				req: &EthGetBlockByNumberRequest{
+					BlockNumber: "0x64",
+					RequestId:   "1024",
+				},
+			},
+			want: &Block{
+				Number:     "0x64",
+				Hash:       fmt.Sprintf("0x%064x", 100),
+				ParentHash: fmt.Sprintf("0x%064x", 99),
+				Timestamp:  "0x64",
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			this := &EthJsonRpcClient{
+				entryPoint: testEntryPoint,
+			}
+
+			got, err := this.EthGetBlockByNumber(tt.args.context, tt.args.req)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEthJsonRpcClient_EthGetCurrentTransactionsByAddressBatch(t *testing.T) {
+	this := &EthJsonRpcClient{
+		entryPoint: testEntryPoint,
+	}
+
+	reqs := []*EthGetCurrentTransactionsByAddressRequest{
+		// the test server only parses the block numbers in decimal
+		{FromBlock: "100", ToBlock: "200", FromAddress: "0xffff", ToAddress: "0xffff", RequestId: "1024-0"},
+		{FromBlock: "101", ToBlock: "201", FromAddress: "0xfffe", ToAddress: "0xfffe", RequestId: "1024-1"},
+	}
+	got, err := this.EthGetCurrentTransactionsByAddressBatch(context.Background(), reqs)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(got))
+	for i, res := range got {
+		assert.Equal(t, nil, res.Err)
+		assert.Equal(t, 2, len(res.Transactions))
+		assert.Equal(t, 100+i, res.Transactions[0].BlockNumber)
+	}
+}
+
+func TestEthJsonRpcClient_AccountState(t *testing.T) {
+	this := &EthJsonRpcClient{
+		entryPoint: testEntryPoint,
+		logger:     logging.NewDefaultLogger(logging.LevelDebug),
+	}
+
+	tests := []struct {
+		name        string
+		address     string
+		blockNumber string
+		wantBalance string
+		wantNonce   Quantity
+		wantErr     bool
+	}{
+		{
+			name:        "normal case 1 - latest block",
+			address:     fmt.Sprintf("0x%040x", 2),
+			wantBalance: "2.5",
+			wantNonce:   2,
+		},
+		{
+			name:        "normal case 2 - at block",
+			address:     fmt.Sprintf("0x%040x", 0),
+			blockNumber: "0x64",
+			wantBalance: "0.5",
+			wantNonce:   0,
+		},
+		{
+			name:    "invalid address",
+			address: "0xffff",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balance, err := this.EthGetBalance(context.Background(), &EthGetBalanceRequest{Address: tt.address, BlockNumber: tt.blockNumber, RequestId: "1024"})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantBalance, balance.Ether())
+
+			nonce, err := this.EthGetTransactionCount(context.Background(), &EthGetTransactionCountRequest{Address: tt.address, BlockNumber: tt.blockNumber, RequestId: "1024"})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantNonce, nonce)
+		})
+	}
+}
+
+func TestEthJsonRpcClient_Batch(t *testing.T) {
+	tests := []struct {
+		name      string
+		requests  []RPCRequest
+		respBody  string
+		want      []RPCResponse
+		wantError error
+	}{
+		{
+			name: "responses out of order",
+			requests: []RPCRequest{
+				{Jsonrpc: JsonRpcVersion, Method: MethodGetCurrentBlockNumber, ID: "a"},
+				{Jsonrpc: JsonRpcVersion, Method: MethodGetCurrentBlockNumber, ID: 2},
+			},
+			respBody: `[{"jsonrpc":"2.0","result":"0x2","id":2},{"jsonrpc":"2.0","result":"0x1","id":"a"}]`,
+			want: []RPCResponse{
+				{Jsonrpc: JsonRpcVersion, Result: json.RawMessage(`"0x1"`), ID: "a"},
+				{Jsonrpc: JsonRpcVersion, Result: json.RawMessage(`"0x2"`), ID: float64(2)},
+			},
+		},
+		{
+			name: "response missing",
+			requests: []RPCRequest{
+				{Jsonrpc: JsonRpcVersion, Method: MethodGetCurrentBlockNumber, ID: "a"},
+				{Jsonrpc: JsonRpcVersion, Method: MethodGetCurrentBlockNumber, ID: "b"},
+			},
+			respBody: `[{"jsonrpc":"2.0","error":{"code":-32000,"message":"oops"},"id":"b"}]`,
+			want: []RPCResponse{
+				{Jsonrpc: JsonRpcVersion, Error: &RPCError{Code: errCodeMissingResponse, Message: "response missing in batch"}, ID: "a"},
+				{Jsonrpc: JsonRpcVersion, Error: &RPCError{Code: -32000, Message: "oops"}, ID: "b"},
+			},
+		},
+		{
+			name: "duplicate id",
+			requests: []RPCRequest{
+				{Jsonrpc: JsonRpcVersion, Method: MethodGetCurrentBlockNumber, ID: "a"},
+				{Jsonrpc: JsonRpcVersion, Method: MethodGetCurrentBlockNumber, ID: "a"},
+			},
+			wantError: ErrDuplicateRequestId,
+		},
+		{
+			name:      "empty batch",
+			wantError: ErrEmptyBatch,
+		},
+		{
+			name: "batch rejected",
+			requests: []RPCRequest{
+				{Jsonrpc: JsonRpcVersion, Method: MethodGetCurrentBlockNumber, ID: "a"},
+			},
+			respBody:  `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`,
+			wantError: errors.New("chain error | code: -32600, message: Invalid Request"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.respBody))
+			}))
+			defer server.Close()
+
+			this := &EthJsonRpcClient{
+				entryPoint: server.URL,
+				logger:     logging.NewDefaultLogger(logging.LevelDebug),
+			}
+			got, err := this.Batch(context.Background(), tt.requests)
+			if tt.wantError != nil {
+				if !errors.Is(err, tt.wantError) {
+					assert.Equal(t, tt.wantError.Error(), err.Error())
+				}
+				return
+			}
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEthJsonRpcClient_Call(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     interface{}
+		respBody   string
+		out        interface{}
+		wantParams string
+		want       interface{}
+		wantError  error
+		// the result is in unexpected type
+		wantTypeError bool
+	}{
+		{
+			name:       "typed result",
+			params:     []interface{}{"0x64", false},
+			respBody:   `{"jsonrpc":"2.0","id":"1","result":{"number":"0x64","hash":"0xb10c"}}`,
+			out:        &Block{},
+			wantParams: `["0x64",false]`,
+			want:       &Block{Number: "0x64", Hash: "0xb10c"},
+		},
+		{
+			name:       "quantity result without params",
+			respBody:   `{"jsonrpc":"2.0","id":"1","result":"0x5208"}`,
+			out:        new(Quantity),
+			wantParams: `[]`,
+			want:       func() *Quantity { q := Quantity(21000); return &q }(),
+		},
+		{
+			name:       "null result",
+			respBody:   `{"jsonrpc":"2.0","id":"1","result":null}`,
+			out:        &Block{},
+			wantParams: `[]`,
+			wantError:  ErrNullResult,
+		},
+		{
+			name:       "missing result",
+			respBody:   `{"jsonrpc":"2.0","id":"1"}`,
+			out:        new(Quantity),
+			wantParams: `[]`,
+			wantError:  ErrNullResult,
+		},
+		{
+			name:       "result not needed",
+			respBody:   `{"jsonrpc":"2.0","id":"1","result":true}`,
+			wantParams: `[]`,
+		},
+		{
+			name:          "unexpected result type",
+			respBody:      `{"jsonrpc":"2.0","id":"1","result":{"number":1}}`,
+			out:           new(Quantity),
+			wantParams:    `[]`,
+			wantTypeError: true,
+		},
+		{
+			name:       "error from chain",
+			respBody:   `{"jsonrpc":"2.0","id":"1","error":{"code":-32602,"message":"Invalid params"}}`,
+			out:        new(Quantity),
+			wantParams: `[]`,
+			wantError:  &RPCError{Code: errCodeInvalidParams, Message: "Invalid params"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req := RPCRequest{}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+				assert.Equal(t, "eth_test", req.Method)
+				assert.Equal(t, tt.wantParams, string(req.Params))
+				w.Write([]byte(tt.respBody))
+			}))
+			defer server.Close()
+
+			this := NewEthJsonRpcClient(server.URL, logging.NewDefaultLogger(logging.LevelDebug), WithRetryPolicy(NoRetryPolicy)).(*EthJsonRpcClient)
+			err := this.Call(context.Background(), "eth_test", tt.params, tt.out)
+			if tt.wantTypeError {
+				typeErr := &json.UnmarshalTypeError{}
+				assert.True(t, errors.As(err, &typeErr), err)
+				return
+			}
+			if tt.wantError != nil {
+				if !errors.Is(err, tt.wantError) {
+					assert.Equal(t, tt.wantError.Error(), err.Error())
+				}
+				return
+			}
+			assert.NoError(t, err)
+			if tt.want != nil {
+				assert.Equal(t, tt.want, tt.out)
+			}
+		})
+	}
+}
+
+func TestEthJsonRpcClient_PendingTransactionFilter(t *testing.T) {
+	this := &EthJsonRpcClient{
+		entryPoint: testEntryPoint,
+		logger:     logging.NewDefaultLogger(logging.LevelDebug),
+	}
+	ctx := context.Background()
+
+	// the full transactions are not supported by the test server
+	_, err := this.EthNewPendingTransactionFilter(ctx, &EthNewPendingTransactionFilterRequest{FullTransactions: true, RequestId: "1024"})
+	var rpcErr *RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, errCodeInvalidParams, rpcErr.Code)
+
+	filterId, err := this.EthNewPendingTransactionFilter(ctx, &EthNewPendingTransactionFilterRequest{RequestId: "1024"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, filterId)
+
+	time.Sleep(1100 * time.Millisecond)
+	changes, err := this.EthGetFilterChanges(ctx, &EthGetFilterChangesRequest{FilterId: filterId, RequestId: "1024"})
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, changes) {
+		var hash string
+		assert.NoError(t, json.Unmarshal(changes[0], &hash))
+		trx, err := this.EthGetTransactionByHash(ctx, &EthGetTransactionByHashRequest{TransactionHash: hash, RequestId: "1024"})
+		assert.NoError(t, err)
+		assert.Equal(t, hash, trx.Hash)
+		// pending
+		assert.Empty(t, trx.BlockNumber)
+	}
+
+	uninstalled, err := this.EthUninstallFilter(ctx, &EthUninstallFilterRequest{FilterId: filterId, RequestId: "1024"})
+	assert.NoError(t, err)
+	assert.True(t, uninstalled)
+
+	_, err = this.EthGetFilterChanges(ctx, &EthGetFilterChangesRequest{FilterId: filterId, RequestId: "1024"})
+	assert.True(t, errors.Is(err, ErrFilterNotFound), err)
+
+	// mined
+	trx, err := this.EthGetTransactionByHash(ctx, &EthGetTransactionByHashRequest{TransactionHash: fmt.Sprintf("0x%064x", 1001), RequestId: "1024"})
+	assert.NoError(t, err)
+	assert.Equal(t, "0x64", trx.BlockNumber)
+
+	_, err = this.EthGetTransactionByHash(ctx, &EthGetTransactionByHashRequest{TransactionHash: fmt.Sprintf("0x%064x", 1005), RequestId: "1024"})
+	assert.Equal(t, ErrTransactionNotFound, err)
+}
+
+func TestEthJsonRpcClient_EthGetTransactionReceiptBatch(t *testing.T) {
+	this := &EthJsonRpcClient{
+		entryPoint: testEntryPoint,
+		logger:     logging.NewDefaultLogger(logging.LevelDebug),
+	}
+
+	// the 2nd transaction of each block of the testserver fails
+	reqs := []*EthGetTransactionReceiptRequest{
+		{TransactionHash: fmt.Sprintf("0x%064x", 1000), RequestId: "1"},
+		{TransactionHash: fmt.Sprintf("0x%064x", 1001), RequestId: "2"},
+		{TransactionHash: "invalid", RequestId: "3"},
+	}
+	results, err := this.EthGetTransactionReceiptBatch(context.Background(), reqs)
+	assert.NoError(t, err)
+	if !assert.Len(t, results, 3) {
+		return
+	}
+
+	assert.NoError(t, results[0].Err)
+	summary := NewReceiptSummary(results[0].Receipt)
+	assert.Equal(t, TransactionStatusSuccess, summary.Status)
+	assert.Equal(t, "0.000021", summary.Fee.Ether())
+	assert.Equal(t, "0x64", results[0].Receipt.BlockNumber)
+
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, TransactionStatusFailure, NewReceiptSummary(results[1].Receipt).Status)
+
+	var rpcErr *RPCError
+	assert.True(t, errors.As(results[2].Err, &rpcErr))
+	assert.Nil(t, results[2].Receipt)
+}
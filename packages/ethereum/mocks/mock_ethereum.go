@@ -6,6 +6,7 @@ package mocks
 
 import (
 	context "context"
+	jsontext "encoding/json/jsontext"
 	reflect "reflect"
 
 	ethereum "github.com/brofu/simple_ethereum_parser/packages/ethereum"
@@ -35,6 +36,51 @@ func (m *MockEthereumChainAccesser) EXPECT() *MockEthereumChainAccesserMockRecor
 	return m.recorder
 }
 
+// EthGetBalance mocks base method.
+func (m *MockEthereumChainAccesser) EthGetBalance(arg0 context.Context, arg1 *ethereum.EthGetBalanceRequest) (ethereum.BigInt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthGetBalance", arg0, arg1)
+	ret0, _ := ret[0].(ethereum.BigInt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthGetBalance indicates an expected call of EthGetBalance.
+func (mr *MockEthereumChainAccesserMockRecorder) EthGetBalance(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetBalance", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetBalance), arg0, arg1)
+}
+
+// EthGetBlockByNumber mocks base method.
+func (m *MockEthereumChainAccesser) EthGetBlockByNumber(arg0 context.Context, arg1 *ethereum.EthGetBlockByNumberRequest) (*ethereum.Block, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthGetBlockByNumber", arg0, arg1)
+	ret0, _ := ret[0].(*ethereum.Block)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthGetBlockByNumber indicates an expected call of EthGetBlockByNumber.
+func (mr *MockEthereumChainAccesserMockRecorder) EthGetBlockByNumber(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetBlockByNumber", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetBlockByNumber), arg0, arg1)
+}
+
+// EthGetBlockWithTransactionsByNumber mocks base method.
+func (m *MockEthereumChainAccesser) EthGetBlockWithTransactionsByNumber(arg0 context.Context, arg1 *ethereum.EthGetBlockByNumberRequest) (*ethereum.BlockWithTransactions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthGetBlockWithTransactionsByNumber", arg0, arg1)
+	ret0, _ := ret[0].(*ethereum.BlockWithTransactions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthGetBlockWithTransactionsByNumber indicates an expected call of EthGetBlockWithTransactionsByNumber.
+func (mr *MockEthereumChainAccesserMockRecorder) EthGetBlockWithTransactionsByNumber(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetBlockWithTransactionsByNumber", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetBlockWithTransactionsByNumber), arg0, arg1)
+}
+
 // EthGetCurrentBlockNumber mocks base method.
 func (m *MockEthereumChainAccesser) EthGetCurrentBlockNumber(arg0 context.Context, arg1 *ethereum.EthGetCurrentBlockNumberRequest) (int, error) {
 	m.ctrl.T.Helper()
@@ -64,3 +110,153 @@ func (mr *MockEthereumChainAccesserMockRecorder) EthGetCurrentTransactionsByAddr
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetCurrentTransactionsByAddress", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetCurrentTransactionsByAddress), arg0, arg1)
 }
+
+// EthGetCurrentTransactionsByAddressBatch mocks base method.
+func (m *MockEthereumChainAccesser) EthGetCurrentTransactionsByAddressBatch(arg0 context.Context, arg1 []*ethereum.EthGetCurrentTransactionsByAddressRequest) ([]ethereum.EthGetCurrentTransactionsByAddressResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthGetCurrentTransactionsByAddressBatch", arg0, arg1)
+	ret0, _ := ret[0].([]ethereum.EthGetCurrentTransactionsByAddressResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthGetCurrentTransactionsByAddressBatch indicates an expected call of EthGetCurrentTransactionsByAddressBatch.
+func (mr *MockEthereumChainAccesserMockRecorder) EthGetCurrentTransactionsByAddressBatch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetCurrentTransactionsByAddressBatch", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetCurrentTransactionsByAddressBatch), arg0, arg1)
+}
+
+// EthGetCurrentTransactionsByAddresses mocks base method.
+func (m *MockEthereumChainAccesser) EthGetCurrentTransactionsByAddresses(arg0 context.Context, arg1 *ethereum.EthGetCurrentTransactionsByAddressesRequest) ([]ethereum.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthGetCurrentTransactionsByAddresses", arg0, arg1)
+	ret0, _ := ret[0].([]ethereum.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthGetCurrentTransactionsByAddresses indicates an expected call of EthGetCurrentTransactionsByAddresses.
+func (mr *MockEthereumChainAccesserMockRecorder) EthGetCurrentTransactionsByAddresses(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetCurrentTransactionsByAddresses", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetCurrentTransactionsByAddresses), arg0, arg1)
+}
+
+// EthGetFilterChanges mocks base method.
+func (m *MockEthereumChainAccesser) EthGetFilterChanges(arg0 context.Context, arg1 *ethereum.EthGetFilterChangesRequest) ([]jsontext.Value, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthGetFilterChanges", arg0, arg1)
+	ret0, _ := ret[0].([]jsontext.Value)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthGetFilterChanges indicates an expected call of EthGetFilterChanges.
+func (mr *MockEthereumChainAccesserMockRecorder) EthGetFilterChanges(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetFilterChanges", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetFilterChanges), arg0, arg1)
+}
+
+// EthGetLogs mocks base method.
+func (m *MockEthereumChainAccesser) EthGetLogs(arg0 context.Context, arg1 *ethereum.EthGetLogsRequest) ([]ethereum.Log, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthGetLogs", arg0, arg1)
+	ret0, _ := ret[0].([]ethereum.Log)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthGetLogs indicates an expected call of EthGetLogs.
+func (mr *MockEthereumChainAccesserMockRecorder) EthGetLogs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetLogs", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetLogs), arg0, arg1)
+}
+
+// EthGetTransactionByHash mocks base method.
+func (m *MockEthereumChainAccesser) EthGetTransactionByHash(arg0 context.Context, arg1 *ethereum.EthGetTransactionByHashRequest) (*ethereum.BlockTransaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthGetTransactionByHash", arg0, arg1)
+	ret0, _ := ret[0].(*ethereum.BlockTransaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthGetTransactionByHash indicates an expected call of EthGetTransactionByHash.
+func (mr *MockEthereumChainAccesserMockRecorder) EthGetTransactionByHash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetTransactionByHash", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetTransactionByHash), arg0, arg1)
+}
+
+// EthGetTransactionCount mocks base method.
+func (m *MockEthereumChainAccesser) EthGetTransactionCount(arg0 context.Context, arg1 *ethereum.EthGetTransactionCountRequest) (ethereum.Quantity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthGetTransactionCount", arg0, arg1)
+	ret0, _ := ret[0].(ethereum.Quantity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthGetTransactionCount indicates an expected call of EthGetTransactionCount.
+func (mr *MockEthereumChainAccesserMockRecorder) EthGetTransactionCount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetTransactionCount", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetTransactionCount), arg0, arg1)
+}
+
+// EthGetTransactionReceipt mocks base method.
+func (m *MockEthereumChainAccesser) EthGetTransactionReceipt(arg0 context.Context, arg1 *ethereum.EthGetTransactionReceiptRequest) (*ethereum.Receipt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthGetTransactionReceipt", arg0, arg1)
+	ret0, _ := ret[0].(*ethereum.Receipt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthGetTransactionReceipt indicates an expected call of EthGetTransactionReceipt.
+func (mr *MockEthereumChainAccesserMockRecorder) EthGetTransactionReceipt(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetTransactionReceipt", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetTransactionReceipt), arg0, arg1)
+}
+
+// EthGetTransactionReceiptBatch mocks base method.
+func (m *MockEthereumChainAccesser) EthGetTransactionReceiptBatch(arg0 context.Context, arg1 []*ethereum.EthGetTransactionReceiptRequest) ([]ethereum.EthGetTransactionReceiptResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthGetTransactionReceiptBatch", arg0, arg1)
+	ret0, _ := ret[0].([]ethereum.EthGetTransactionReceiptResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthGetTransactionReceiptBatch indicates an expected call of EthGetTransactionReceiptBatch.
+func (mr *MockEthereumChainAccesserMockRecorder) EthGetTransactionReceiptBatch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthGetTransactionReceiptBatch", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthGetTransactionReceiptBatch), arg0, arg1)
+}
+
+// EthNewPendingTransactionFilter mocks base method.
+func (m *MockEthereumChainAccesser) EthNewPendingTransactionFilter(arg0 context.Context, arg1 *ethereum.EthNewPendingTransactionFilterRequest) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthNewPendingTransactionFilter", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthNewPendingTransactionFilter indicates an expected call of EthNewPendingTransactionFilter.
+func (mr *MockEthereumChainAccesserMockRecorder) EthNewPendingTransactionFilter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthNewPendingTransactionFilter", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthNewPendingTransactionFilter), arg0, arg1)
+}
+
+// EthUninstallFilter mocks base method.
+func (m *MockEthereumChainAccesser) EthUninstallFilter(arg0 context.Context, arg1 *ethereum.EthUninstallFilterRequest) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EthUninstallFilter", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EthUninstallFilter indicates an expected call of EthUninstallFilter.
+func (mr *MockEthereumChainAccesserMockRecorder) EthUninstallFilter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EthUninstallFilter", reflect.TypeOf((*MockEthereumChainAccesser)(nil).EthUninstallFilter), arg0, arg1)
+}
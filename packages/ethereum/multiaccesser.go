@@ -0,0 +1,552 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+)
+
+var ErrNoEndpoint = errors.New("no endpoint")
+
+const (
+	// the default number of consecutive failures to mark an endpoint unhealthy
+	defaultFailureThreshold = 3
+	// the default timeout of the health check of one endpoint
+	defaultHealthCheckTimeout = 3 * time.Second
+)
+
+// Endpoint is an upstream of the multi-endpoint accesser
+type Endpoint struct {
+	// the name shown in logs and stats, e.g. the entry point
+	Name     string
+	Accesser EthereumChainAccesser
+	// the share of traffic in weighted round-robin. 0 is treated as 1.
+	Weight int
+}
+
+type MultiAccesserConfiguration struct {
+	// the interval to check the health and head of endpoints by `eth_blockNumber`. 0 means disabled.
+	HealthCheckInterval time.Duration
+	// the timeout of the health check of one endpoint. `defaultHealthCheckTimeout` is used if it's 0.
+	HealthCheckTimeout time.Duration
+	// number of consecutive failures to mark an endpoint unhealthy. `defaultFailureThreshold` is used if it's 0.
+	FailureThreshold int
+	// the endpoints whose head is more than `MaxHeadLag` blocks behind the highest one are skipped. 0 means disabled.
+	MaxHeadLag int
+}
+
+// EndpointStats is the state and counters of an endpoint
+type EndpointStats struct {
+	Name    string `json:"name"`
+	Weight  int    `json:"weight"`
+	Healthy bool   `json:"healthy"`
+	// the latest head seen, 0 if unknown
+	Head      int    `json:"head"`
+	Lagging   bool   `json:"lagging"`
+	Requests  uint64 `json:"requests"`
+	Failures  uint64 `json:"failures"`
+	LastError string `json:"last_error,omitempty"`
+	// the stats of client side throttling, if the accesser of endpoint is throttled
+	Throttle *ThrottleStats `json:"throttle,omitempty"`
+}
+
+// StatsReporter is implemented by the accessers which expose the stats of their endpoints
+type StatsReporter interface {
+	Stats() []EndpointStats
+}
+
+// endpointState is the state of an endpoint, guarded by the lock of `multiAccesser`
+type endpointState struct {
+	Endpoint
+	// the current weight of smooth weighted round-robin
+	currentWeight       int
+	healthy             bool
+	head                int
+	consecutiveFailures int
+	requests            uint64
+	failures            uint64
+	lastError           string
+}
+
+// multiAccesser implements interface `EthereumChainAccesser` over several endpoints.
+// Each call is served by an endpoint picked by smooth weighted round-robin, among the healthy ones which are not lagging behind.
+// If the call fails, it's retried on the other endpoints, so an outage or rate limit of one upstream doesn't stop indexing.
+type multiAccesser struct {
+	lock      sync.Mutex
+	endpoints []*endpointState
+
+	healthCheckTimeout time.Duration
+	failureThreshold   int
+	maxHeadLag         int
+	logger             logging.Logger
+}
+
+// NewMultiAccesser construct an accesser over the endpoints. The health check runs until `ctx` is done.
+func NewMultiAccesser(ctx context.Context, endpoints []Endpoint, config MultiAccesserConfiguration, logger logging.Logger) (EthereumChainAccesser, error) {
+
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoint
+	}
+
+	this := &multiAccesser{
+		healthCheckTimeout: config.HealthCheckTimeout,
+		failureThreshold:   config.FailureThreshold,
+		maxHeadLag:         config.MaxHeadLag,
+		logger:             logger,
+	}
+	if this.healthCheckTimeout <= 0 {
+		this.healthCheckTimeout = defaultHealthCheckTimeout
+	}
+	if this.failureThreshold <= 0 {
+		this.failureThreshold = defaultFailureThreshold
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.Accesser == nil {
+			return nil, fmt.Errorf("nil accesser of endpoint: %s", endpoint.Name)
+		}
+		if endpoint.Weight <= 0 {
+			endpoint.Weight = 1
+		}
+		this.endpoints = append(this.endpoints, &endpointState{Endpoint: endpoint, healthy: true})
+	}
+
+	if config.HealthCheckInterval > 0 {
+		go this.startHealthCheck(ctx, config.HealthCheckInterval)
+	}
+	return this, nil
+}
+
+func (this *multiAccesser) EthGetCurrentTransactionsByAddress(ctx context.Context, req *EthGetCurrentTransactionsByAddressRequest) ([]Transaction, error) {
+	var transactions []Transaction
+	err := this.do(ctx, MethodTraceFilter, func(accesser EthereumChainAccesser) error {
+		var err error
+		transactions, err = accesser.EthGetCurrentTransactionsByAddress(ctx, req)
+		return err
+	})
+	return transactions, err
+}
+
+func (this *multiAccesser) EthGetCurrentTransactionsByAddresses(ctx context.Context, req *EthGetCurrentTransactionsByAddressesRequest) ([]Transaction, error) {
+	var transactions []Transaction
+	err := this.do(ctx, MethodTraceFilter, func(accesser EthereumChainAccesser) error {
+		var err error
+		transactions, err = accesser.EthGetCurrentTransactionsByAddresses(ctx, req)
+		return err
+	})
+	return transactions, err
+}
+
+// EthGetCurrentBlockNumber get the block number, and update the head of the endpoint serving it
+func (this *multiAccesser) EthGetCurrentBlockNumber(ctx context.Context, req *EthGetCurrentBlockNumberRequest) (int, error) {
+	var bn int
+	err := this.do(ctx, MethodGetCurrentBlockNumber, func(accesser EthereumChainAccesser) error {
+		var err error
+		bn, err = accesser.EthGetCurrentBlockNumber(ctx, req)
+		if err == nil {
+			this.updateHead(accesser, bn)
+		}
+		return err
+	})
+	return bn, err
+}
+
+func (this *multiAccesser) EthGetBlockByNumber(ctx context.Context, req *EthGetBlockByNumberRequest) (*Block, error) {
+	var block *Block
+	err := this.do(ctx, MethodGetBlockByNumber, func(accesser EthereumChainAccesser) error {
+		var err error
+		block, err = accesser.EthGetBlockByNumber(ctx, req)
+		return err
+	})
+	return block, err
+}
+
+func (this *multiAccesser) EthGetBlockWithTransactionsByNumber(ctx context.Context, req *EthGetBlockByNumberRequest) (*BlockWithTransactions, error) {
+	var block *BlockWithTransactions
+	err := this.do(ctx, MethodGetBlockByNumber, func(accesser EthereumChainAccesser) error {
+		var err error
+		block, err = accesser.EthGetBlockWithTransactionsByNumber(ctx, req)
+		return err
+	})
+	return block, err
+}
+
+func (this *multiAccesser) EthGetTransactionReceipt(ctx context.Context, req *EthGetTransactionReceiptRequest) (*Receipt, error) {
+	var receipt *Receipt
+	err := this.do(ctx, MethodGetTransactionReceipt, func(accesser EthereumChainAccesser) error {
+		var err error
+		receipt, err = accesser.EthGetTransactionReceipt(ctx, req)
+		return err
+	})
+	return receipt, err
+}
+
+func (this *multiAccesser) EthGetLogs(ctx context.Context, req *EthGetLogsRequest) ([]Log, error) {
+	var logs []Log
+	err := this.do(ctx, MethodGetLogs, func(accesser EthereumChainAccesser) error {
+		var err error
+		logs, err = accesser.EthGetLogs(ctx, req)
+		return err
+	})
+	return logs, err
+}
+
+func (this *multiAccesser) EthGetBalance(ctx context.Context, req *EthGetBalanceRequest) (BigInt, error) {
+	var balance BigInt
+	err := this.do(ctx, MethodGetBalance, func(accesser EthereumChainAccesser) error {
+		var err error
+		balance, err = accesser.EthGetBalance(ctx, req)
+		return err
+	})
+	return balance, err
+}
+
+func (this *multiAccesser) EthGetTransactionCount(ctx context.Context, req *EthGetTransactionCountRequest) (Quantity, error) {
+	var nonce Quantity
+	err := this.do(ctx, MethodGetTransactionCount, func(accesser EthereumChainAccesser) error {
+		var err error
+		nonce, err = accesser.EthGetTransactionCount(ctx, req)
+		return err
+	})
+	return nonce, err
+}
+
+func (this *multiAccesser) EthGetTransactionByHash(ctx context.Context, req *EthGetTransactionByHashRequest) (*BlockTransaction, error) {
+	var trx *BlockTransaction
+	err := this.do(ctx, MethodGetTransactionByHash, func(accesser EthereumChainAccesser) error {
+		var err error
+		trx, err = accesser.EthGetTransactionByHash(ctx, req)
+		return err
+	})
+	return trx, err
+}
+
+// EthNewPendingTransactionFilter install the filter on one endpoint. The polling fails over to the others until it reaches the endpoint.
+func (this *multiAccesser) EthNewPendingTransactionFilter(ctx context.Context, req *EthNewPendingTransactionFilterRequest) (string, error) {
+	var filterId string
+	err := this.do(ctx, MethodNewPendingTransactionFilter, func(accesser EthereumChainAccesser) error {
+		var err error
+		filterId, err = accesser.EthNewPendingTransactionFilter(ctx, req)
+		return err
+	})
+	return filterId, err
+}
+
+func (this *multiAccesser) EthGetFilterChanges(ctx context.Context, req *EthGetFilterChangesRequest) ([]json.RawMessage, error) {
+	var changes []json.RawMessage
+	err := this.do(ctx, MethodGetFilterChanges, func(accesser EthereumChainAccesser) error {
+		var err error
+		changes, err = accesser.EthGetFilterChanges(ctx, req)
+		return err
+	})
+	return changes, err
+}
+
+func (this *multiAccesser) EthUninstallFilter(ctx context.Context, req *EthUninstallFilterRequest) (bool, error) {
+	var uninstalled bool
+	err := this.do(ctx, MethodUninstallFilter, func(accesser EthereumChainAccesser) error {
+		var err error
+		uninstalled, err = accesser.EthUninstallFilter(ctx, req)
+		return err
+	})
+	return uninstalled, err
+}
+
+// EthGetCurrentTransactionsByAddressBatch send the whole batch to one endpoint. It fails over only if the whole call fails.
+func (this *multiAccesser) EthGetCurrentTransactionsByAddressBatch(ctx context.Context, reqs []*EthGetCurrentTransactionsByAddressRequest) ([]EthGetCurrentTransactionsByAddressResult, error) {
+	var results []EthGetCurrentTransactionsByAddressResult
+	err := this.do(ctx, methodBatch, func(accesser EthereumChainAccesser) error {
+		var err error
+		results, err = accesser.EthGetCurrentTransactionsByAddressBatch(ctx, reqs)
+		return err
+	})
+	return results, err
+}
+
+// EthGetTransactionReceiptBatch send the whole batch to one endpoint. It fails over only if the whole call fails.
+func (this *multiAccesser) EthGetTransactionReceiptBatch(ctx context.Context, reqs []*EthGetTransactionReceiptRequest) ([]EthGetTransactionReceiptResult, error) {
+	var results []EthGetTransactionReceiptResult
+	err := this.do(ctx, methodBatch, func(accesser EthereumChainAccesser) error {
+		var err error
+		results, err = accesser.EthGetTransactionReceiptBatch(ctx, reqs)
+		return err
+	})
+	return results, err
+}
+
+// Stats get the state and counters of the endpoints, in the order of configuration
+func (this *multiAccesser) Stats() []EndpointStats {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	bestHead := this.bestHead()
+	stats := make([]EndpointStats, 0, len(this.endpoints))
+	for _, state := range this.endpoints {
+		endpointStats := EndpointStats{
+			Name:      state.Name,
+			Weight:    state.Weight,
+			Healthy:   state.healthy,
+			Head:      state.head,
+			Lagging:   this.isLagging(state, bestHead),
+			Requests:  state.requests,
+			Failures:  state.failures,
+			LastError: state.lastError,
+		}
+		if reporter, ok := state.Accesser.(ThrottleReporter); ok {
+			if throttleStats, throttled := reporter.ThrottleStats(); throttled {
+				endpointStats.Throttle = &throttleStats
+			}
+		}
+		stats = append(stats, endpointStats)
+	}
+	return stats
+}
+
+// do call `fn` with the endpoints in the order of `candidates`, until one succeeds.
+// If the result is not found, it's tried on the endpoints ahead of the one answering, in case that one is lagging.
+// The not-found error is returned if none of them finds it.
+func (this *multiAccesser) do(ctx context.Context, method string, fn func(EthereumChainAccesser) error) error {
+
+	var err, notFoundErr error
+	notFoundHead := 0
+	for _, state := range this.candidates() {
+		if notFoundErr != nil && !this.isAhead(state, notFoundHead) {
+			continue
+		}
+		err = fn(state.Accesser)
+		this.record(state, err)
+		if err == nil {
+			return nil
+		}
+		if !shouldFailover(ctx, err) {
+			return err
+		}
+		if isNotFound(err) {
+			this.logger.Debugf("not found on endpoint, try the ones ahead | endpoint: %s, method: %s, err: %s", state.Name, method, err.Error())
+			if notFoundErr == nil {
+				notFoundErr = err
+				notFoundHead = this.headOf(state)
+			}
+			continue
+		}
+		this.logger.Errorf("endpoint call fail, fail over | endpoint: %s, method: %s, err: %s", state.Name, method, err.Error())
+	}
+	if notFoundErr != nil {
+		return notFoundErr
+	}
+	return err
+}
+
+// candidates get the endpoints to try in order. The first one is picked by smooth weighted round-robin among the available ones,
+// followed by the other available ones in weight order. The unavailable ones are the last resort.
+func (this *multiAccesser) candidates() []*endpointState {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	bestHead := this.bestHead()
+	var available, unavailable []*endpointState
+	for _, state := range this.endpoints {
+		if state.healthy && !this.isLagging(state, bestHead) {
+			available = append(available, state)
+		} else {
+			unavailable = append(unavailable, state)
+		}
+	}
+	if len(available) == 0 {
+		return unavailable
+	}
+
+	// smooth weighted round-robin, the same as nginx
+	total := 0
+	var picked *endpointState
+	for _, state := range available {
+		state.currentWeight += state.Weight
+		total += state.Weight
+		if picked == nil || state.currentWeight > picked.currentWeight {
+			picked = state
+		}
+	}
+	picked.currentWeight -= total
+
+	candidates := make([]*endpointState, 0, len(this.endpoints))
+	candidates = append(candidates, picked)
+	for _, state := range sortByWeight(available) {
+		if state != picked {
+			candidates = append(candidates, state)
+		}
+	}
+	return append(candidates, unavailable...)
+}
+
+// record update the counters and health of an endpoint with the result of a call
+func (this *multiAccesser) record(state *endpointState, err error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	state.requests++
+	if err == nil {
+		state.consecutiveFailures = 0
+		if !state.healthy {
+			this.logger.Infof("endpoint recovered | endpoint: %s", state.Name)
+		}
+		state.healthy = true
+		return
+	}
+
+	if !isEndpointFailure(err) { // answered by the endpoint, e.g. not found, or the request is invalid
+		return
+	}
+	state.failures++
+	state.consecutiveFailures++
+	state.lastError = err.Error()
+	if state.healthy && state.consecutiveFailures >= this.failureThreshold {
+		this.logger.Errorf("endpoint unhealthy | endpoint: %s, failures: %d, err: %s", state.Name, state.consecutiveFailures, err.Error())
+		state.healthy = false
+	}
+}
+
+func (this *multiAccesser) headOf(state *endpointState) int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return state.head
+}
+
+// isAhead check if the endpoint may have newer data than the head. It's true if either of the heads is unknown.
+func (this *multiAccesser) isAhead(state *endpointState, head int) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return head == 0 || state.head == 0 || state.head > head
+}
+
+func (this *multiAccesser) updateHead(accesser EthereumChainAccesser, head int) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for _, state := range this.endpoints {
+		if state.Accesser == accesser {
+			state.head = head
+		}
+	}
+}
+
+// startHealthCheck check all the endpoints in every interval, including the unhealthy ones, so they can recover
+func (this *multiAccesser) startHealthCheck(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			this.checkHealth(ctx)
+		}
+	}
+}
+
+func (this *multiAccesser) checkHealth(ctx context.Context) {
+
+	this.lock.Lock()
+	endpoints := make([]*endpointState, len(this.endpoints))
+	copy(endpoints, this.endpoints)
+	this.lock.Unlock()
+
+	wg := sync.WaitGroup{}
+	for _, state := range endpoints {
+		wg.Add(1)
+		go func(state *endpointState) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, this.healthCheckTimeout)
+			defer cancel()
+			req := &EthGetCurrentBlockNumberRequest{RequestId: fmt.Sprintf("health-%d", time.Now().UnixNano())}
+			bn, err := state.Accesser.EthGetCurrentBlockNumber(checkCtx, req)
+
+			this.lock.Lock()
+			defer this.lock.Unlock()
+			if err != nil {
+				if state.healthy {
+					this.logger.Errorf("endpoint health check fail | endpoint: %s, err: %s", state.Name, err.Error())
+				}
+				state.healthy = false
+				state.lastError = err.Error()
+				return
+			}
+			if !state.healthy {
+				this.logger.Infof("endpoint recovered | endpoint: %s, head: %d", state.Name, bn)
+			}
+			state.healthy = true
+			state.consecutiveFailures = 0
+			state.head = bn
+		}(state)
+	}
+	wg.Wait()
+}
+
+// bestHead get the highest head of the healthy endpoints. It should be called with the lock held.
+func (this *multiAccesser) bestHead() int {
+	best := 0
+	for _, state := range this.endpoints {
+		if state.healthy && state.head > best {
+			best = state.head
+		}
+	}
+	return best
+}
+
+// isLagging check if the endpoint is too far behind. The endpoints with unknown head are not lagging.
+func (this *multiAccesser) isLagging(state *endpointState, bestHead int) bool {
+	return this.maxHeadLag > 0 && state.head > 0 && bestHead-state.head > this.maxHeadLag
+}
+
+// shouldFailover check if the call should be retried on other endpoints.
+// All the upstream errors (network, HTTP 429 / 5xx, JSON RPC error) are retried, except the ones caused by the caller.
+func shouldFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return !errors.Is(err, ErrEmptyBatch) && !errors.Is(err, ErrDuplicateRequestId)
+}
+
+// isEndpointFailure check if the error counts against the health of the endpoint.
+// Only the transport errors (e.g. network errors and timeouts), rate limits and HTTP 5xx count.
+// The JSON RPC errors and not-found results are answered by a working endpoint, e.g. the params of caller are invalid, or the node is lagging.
+func isEndpointFailure(err error) bool {
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return false
+	}
+	return !errors.Is(err, context.Canceled) && !isNotFound(err) && !errors.Is(err, ErrFilterNotFound) &&
+		!errors.Is(err, ErrEmptyBatch) && !errors.Is(err, ErrDuplicateRequestId)
+}
+
+// isNotFound check if the result is not found, which may be found on the endpoints ahead
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrBlockNotFound) || errors.Is(err, ErrReceiptNotFound) ||
+		errors.Is(err, ErrTransactionNotFound) || errors.Is(err, ErrNullResult)
+}
+
+// sortByWeight sort the endpoints by weight in descending order, stably
+func sortByWeight(endpoints []*endpointState) []*endpointState {
+	sorted := make([]*endpointState, len(endpoints))
+	copy(sorted, endpoints)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Weight > sorted[j].Weight
+	})
+	return sorted
+}
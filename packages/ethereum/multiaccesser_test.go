@@ -0,0 +1,268 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAccesser serves the block number and logs for test. The other methods are not implemented.
+type fakeAccesser struct {
+	EthereumChainAccesser
+	lock  sync.Mutex
+	name  string
+	head  int
+	err   error
+	calls int
+}
+
+func (this *fakeAccesser) EthGetCurrentBlockNumber(ctx context.Context, req *EthGetCurrentBlockNumberRequest) (int, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.calls++
+	return this.head, this.err
+}
+
+func (this *fakeAccesser) EthGetLogs(ctx context.Context, req *EthGetLogsRequest) ([]Log, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.calls++
+	if this.err != nil {
+		return nil, this.err
+	}
+	return []Log{{Address: this.name}}, nil
+}
+
+func (this *fakeAccesser) setErr(err error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.err = err
+}
+
+func newTestMultiAccesser(t *testing.T, config MultiAccesserConfiguration, endpoints ...Endpoint) *multiAccesser {
+	accesser, err := NewMultiAccesser(context.Background(), endpoints, config, logging.NewDefaultLogger(logging.LevelDebug))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return accesser.(*multiAccesser)
+}
+
+func TestNewMultiAccesser(t *testing.T) {
+
+	logger := logging.NewDefaultLogger(logging.LevelDebug)
+
+	_, err := NewMultiAccesser(context.Background(), nil, MultiAccesserConfiguration{}, logger)
+	assert.Equal(t, ErrNoEndpoint, err)
+
+	_, err = NewMultiAccesser(context.Background(), []Endpoint{{Name: "a"}}, MultiAccesserConfiguration{}, logger)
+	assert.Error(t, err)
+}
+
+func TestMultiAccesser_WeightedRoundRobin(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		weights []int
+		calls   int
+		want    []int
+	}{
+		{
+			name:    "equal weights",
+			weights: []int{1, 1},
+			calls:   4,
+			want:    []int{2, 2},
+		},
+		{
+			name:    "weighted",
+			weights: []int{3, 1},
+			calls:   8,
+			want:    []int{6, 2},
+		},
+		{
+			name:    "zero weight treated as 1",
+			weights: []int{0, 2},
+			calls:   6,
+			want:    []int{2, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakes := make([]*fakeAccesser, len(tt.weights))
+			endpoints := make([]Endpoint, len(tt.weights))
+			for i, weight := range tt.weights {
+				fakes[i] = &fakeAccesser{name: string(rune('a' + i))}
+				endpoints[i] = Endpoint{Name: fakes[i].name, Accesser: fakes[i], Weight: weight}
+			}
+			accesser := newTestMultiAccesser(t, MultiAccesserConfiguration{}, endpoints...)
+
+			for i := 0; i < tt.calls; i++ {
+				_, err := accesser.EthGetLogs(context.Background(), &EthGetLogsRequest{})
+				assert.NoError(t, err)
+			}
+			for i, fake := range fakes {
+				assert.Equal(t, tt.want[i], fake.calls, fake.name)
+			}
+			for i, stats := range accesser.Stats() {
+				assert.Equal(t, uint64(tt.want[i]), stats.Requests)
+			}
+		})
+	}
+}
+
+func TestMultiAccesser_Failover(t *testing.T) {
+
+	errUpstream := errors.New("status code not equal 200 | status: 429 Too Many Requests")
+	a := &fakeAccesser{name: "a", err: errUpstream}
+	b := &fakeAccesser{name: "b"}
+	accesser := newTestMultiAccesser(t, MultiAccesserConfiguration{FailureThreshold: 2},
+		Endpoint{Name: "a", Accesser: a, Weight: 10},
+		Endpoint{Name: "b", Accesser: b, Weight: 1},
+	)
+
+	// served by b after a fails
+	for i := 0; i < 2; i++ {
+		logs, err := accesser.EthGetLogs(context.Background(), &EthGetLogsRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, "b", logs[0].Address)
+	}
+	stats := accesser.Stats()
+	assert.False(t, stats[0].Healthy)
+	assert.Equal(t, uint64(2), stats[0].Failures)
+	assert.Equal(t, errUpstream.Error(), stats[0].LastError)
+	assert.True(t, stats[1].Healthy)
+
+	// a is skipped when it's unhealthy
+	a.calls = 0
+	_, err := accesser.EthGetLogs(context.Background(), &EthGetLogsRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, a.calls)
+
+	// all failed, the last error is returned
+	b.setErr(errUpstream)
+	_, err = accesser.EthGetLogs(context.Background(), &EthGetLogsRequest{})
+	assert.Equal(t, errUpstream, err)
+
+	// no failover for the errors of caller
+	b.setErr(ErrEmptyBatch)
+	a.calls = 0
+	_, err = accesser.EthGetLogs(context.Background(), &EthGetLogsRequest{})
+	assert.Equal(t, ErrEmptyBatch, err)
+	assert.Equal(t, 0, a.calls)
+
+	// recovered by health check
+	a.setErr(nil)
+	accesser.checkHealth(context.Background())
+	assert.True(t, accesser.Stats()[0].Healthy)
+}
+
+func TestMultiAccesser_NotEndpointFailure(t *testing.T) {
+
+	a := &fakeAccesser{name: "a", head: 100}
+	b := &fakeAccesser{name: "b", head: 100}
+	accesser := newTestMultiAccesser(t, MultiAccesserConfiguration{FailureThreshold: 1},
+		Endpoint{Name: "a", Accesser: a, Weight: 10},
+		Endpoint{Name: "b", Accesser: b, Weight: 1},
+	)
+
+	// the heads are unknown, not found is tried on b in case a is lagging
+	a.setErr(ErrTransactionNotFound)
+	logs, err := accesser.EthGetLogs(context.Background(), &EthGetLogsRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "b", logs[0].Address)
+
+	// b is not ahead of a, the not found result is returned
+	a.setErr(nil)
+	accesser.checkHealth(context.Background())
+	a.setErr(ErrTransactionNotFound)
+	b.setErr(ErrTransactionNotFound)
+	a.calls, b.calls = 0, 0
+	_, err = accesser.EthGetLogs(context.Background(), &EthGetLogsRequest{})
+	assert.Equal(t, ErrTransactionNotFound, err)
+	assert.Equal(t, 1, a.calls)
+	assert.Equal(t, 0, b.calls)
+
+	// the errors of caller are failed over, but not counted
+	a.setErr(&RPCError{Code: errCodeInvalidParams, Message: "block range too large"})
+	b.setErr(nil)
+	_, err = accesser.EthGetLogs(context.Background(), &EthGetLogsRequest{})
+	assert.NoError(t, err)
+
+	for _, stats := range accesser.Stats() {
+		assert.True(t, stats.Healthy, stats.Name)
+		assert.Equal(t, uint64(0), stats.Failures, stats.Name)
+	}
+
+	// the errors of endpoint are counted
+	a.setErr(&StatusError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"})
+	_, err = accesser.EthGetLogs(context.Background(), &EthGetLogsRequest{})
+	assert.NoError(t, err)
+	assert.False(t, accesser.Stats()[0].Healthy)
+}
+
+func Test_isEndpointFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "transport error", err: errors.New("connection refused"), want: true},
+		{name: "timeout", err: &timeoutError{err: context.DeadlineExceeded}, want: true},
+		{name: "HTTP 429", err: &StatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "HTTP 503", err: &StatusError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "JSON RPC rate limit", err: &RPCError{Code: errCodeLimitExceeded}, want: true},
+		{name: "HTTP 400", err: &StatusError{StatusCode: http.StatusBadRequest}, want: false},
+		{name: "invalid params", err: &RPCError{Code: errCodeInvalidParams}, want: false},
+		{name: "not found", err: ErrBlockNotFound, want: false},
+		{name: "null result", err: ErrNullResult, want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isEndpointFailure(tt.err))
+		})
+	}
+}
+
+func TestMultiAccesser_HeadLag(t *testing.T) {
+
+	a := &fakeAccesser{name: "a", head: 100}
+	b := &fakeAccesser{name: "b", head: 90}
+	accesser := newTestMultiAccesser(t, MultiAccesserConfiguration{MaxHeadLag: 5},
+		Endpoint{Name: "a", Accesser: a, Weight: 1},
+		Endpoint{Name: "b", Accesser: b, Weight: 1},
+	)
+
+	// the heads are unknown before the health check, both are used
+	for i := 0; i < 2; i++ {
+		accesser.EthGetLogs(context.Background(), &EthGetLogsRequest{})
+	}
+	assert.Equal(t, 1, a.calls)
+	assert.Equal(t, 1, b.calls)
+
+	accesser.checkHealth(context.Background())
+	stats := accesser.Stats()
+	assert.Equal(t, 100, stats[0].Head)
+	assert.Equal(t, 90, stats[1].Head)
+	assert.False(t, stats[0].Lagging)
+	assert.True(t, stats[1].Lagging)
+
+	// b is skipped while lagging
+	a.calls, b.calls = 0, 0
+	for i := 0; i < 4; i++ {
+		logs, err := accesser.EthGetLogs(context.Background(), &EthGetLogsRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, "a", logs[0].Address)
+	}
+	assert.Equal(t, 0, b.calls)
+
+	// b catches up
+	b.head = 98
+	accesser.checkHealth(context.Background())
+	assert.False(t, accesser.Stats()[1].Lagging)
+}
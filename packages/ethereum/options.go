@@ -0,0 +1,177 @@
+package ethereum
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// the default number of idle connections kept for the entry point.
+	// The default one of `net/http` is 2, which is too small for the concurrent workers.
+	defaultMaxIdleConnsPerHost = 16
+)
+
+// ClientOption is the option of `NewEthJsonRpcClient`
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	httpClient  *http.Client
+	transport   http.RoundTripper
+	retryPolicy RetryPolicy
+	timeout     time.Duration
+	headers     http.Header
+	gzip        bool
+	throttle    ThrottleConfiguration
+
+	// the tuning of `*http.Transport`
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+	idleConnTimeout     time.Duration
+	disableKeepAlives   bool
+	tlsConfig           *tls.Config
+	proxy               func(*http.Request) (*url.URL, error)
+	proxySet            bool
+}
+
+// WithHTTPClient use the client to send requests. The transport options are ignored if it's set.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(options *clientOptions) {
+		options.httpClient = client
+	}
+}
+
+// WithTransport use the transport to send requests. The tuning options are applied on a clone of it, if it's an `*http.Transport`.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(options *clientOptions) {
+		options.transport = transport
+	}
+}
+
+// WithConnectionPool set the sizes of connection pool. 0 means the default one.
+func WithConnectionPool(maxIdleConns, maxIdleConnsPerHost, maxConnsPerHost int) ClientOption {
+	return func(options *clientOptions) {
+		options.maxIdleConns = maxIdleConns
+		options.maxIdleConnsPerHost = maxIdleConnsPerHost
+		options.maxConnsPerHost = maxConnsPerHost
+	}
+}
+
+// WithKeepAlive set how long an idle connection is kept. The keep-alive is disabled if `enabled` is false.
+func WithKeepAlive(enabled bool, idleConnTimeout time.Duration) ClientOption {
+	return func(options *clientOptions) {
+		options.disableKeepAlives = !enabled
+		options.idleConnTimeout = idleConnTimeout
+	}
+}
+
+// WithTLSConfig set the TLS configuration, e.g. the CA of a private node
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(options *clientOptions) {
+		options.tlsConfig = config
+	}
+}
+
+// WithProxy set the proxy of requests. `nil` means no proxy, instead of the one from environment variables.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) ClientOption {
+	return func(options *clientOptions) {
+		options.proxy = proxy
+		options.proxySet = true
+	}
+}
+
+// WithTimeout set the timeout of one HTTP request, including the reading of response. 0 means no timeout.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(options *clientOptions) {
+		options.timeout = timeout
+	}
+}
+
+// WithHeader add an extra header to each request, e.g. the API key of provider
+func WithHeader(key, value string) ClientOption {
+	return func(options *clientOptions) {
+		options.headers.Add(key, value)
+	}
+}
+
+// WithBasicAuth authenticate each request with the username and password
+func WithBasicAuth(username, password string) ClientOption {
+	return func(options *clientOptions) {
+		credential := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		options.headers.Set("Authorization", "Basic "+credential)
+	}
+}
+
+// WithBearerToken authenticate each request with the token
+func WithBearerToken(token string) ClientOption {
+	return func(options *clientOptions) {
+		options.headers.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithGzip ask for the gzip compressed responses, and decode them. It saves the bandwidth of the large responses, e.g. `trace_filter`.
+func WithGzip() ClientOption {
+	return func(options *clientOptions) {
+		options.gzip = true
+	}
+}
+
+// WithThrottle limit the rate and concurrency of the HTTP requests, so they're queued on client side
+// instead of being rejected by the rate limit of upstream. Each attempt of the retries takes a token, and a batch of N calls takes N.
+func WithThrottle(config ThrottleConfiguration) ClientOption {
+	return func(options *clientOptions) {
+		options.throttle = config
+	}
+}
+
+// WithRetryPolicy set the policy to retry the failed calls. `DefaultRetryPolicy` is used if it's not set.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(options *clientOptions) {
+		options.retryPolicy = policy
+	}
+}
+
+// buildHTTPClient build the client with the options
+func (this *clientOptions) buildHTTPClient() *http.Client {
+
+	if this.httpClient != nil {
+		return this.httpClient
+	}
+
+	transport := this.transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if t, ok := transport.(*http.Transport); ok {
+		t = t.Clone()
+		t.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+		if this.maxIdleConns > 0 {
+			t.MaxIdleConns = this.maxIdleConns
+		}
+		if this.maxIdleConnsPerHost > 0 {
+			t.MaxIdleConnsPerHost = this.maxIdleConnsPerHost
+		}
+		if this.maxConnsPerHost > 0 {
+			t.MaxConnsPerHost = this.maxConnsPerHost
+		}
+		if this.idleConnTimeout > 0 {
+			t.IdleConnTimeout = this.idleConnTimeout
+		}
+		t.DisableKeepAlives = this.disableKeepAlives
+		if this.tlsConfig != nil {
+			t.TLSClientConfig = this.tlsConfig
+		}
+		if this.proxySet {
+			t.Proxy = this.proxy
+		}
+		transport = t
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   this.timeout,
+	}
+}
@@ -0,0 +1,135 @@
+package ethereum
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEthJsonRpcClient_Options(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		options     []ClientOption
+		wantHeaders map[string]string
+		// the server compresses the response if it's asked
+		wantGzip bool
+	}{
+		{
+			name:    "default",
+			options: nil,
+		},
+		{
+			name:        "extra headers",
+			options:     []ClientOption{WithHeader("X-Api-Key", "key"), WithHeader("User-Agent", "parser")},
+			wantHeaders: map[string]string{"X-Api-Key": "key", "User-Agent": "parser"},
+		},
+		{
+			name:        "basic auth",
+			options:     []ClientOption{WithBasicAuth("user", "pass")},
+			wantHeaders: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+		},
+		{
+			name:        "bearer token",
+			options:     []ClientOption{WithBearerToken("token")},
+			wantHeaders: map[string]string{"Authorization": "Bearer token"},
+		},
+		{
+			name:        "gzip",
+			options:     []ClientOption{WithGzip()},
+			wantHeaders: map[string]string{"Accept-Encoding": "gzip"},
+			wantGzip:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, value := range tt.wantHeaders {
+					assert.Equal(t, value, r.Header.Get(key), key)
+				}
+				assert.Equal(t, contentType, r.Header.Get("Content-Type"))
+
+				body := `{"jsonrpc":"2.0","id":"1","result":"0x10"}`
+				if r.Header.Get("Accept-Encoding") == "gzip" && tt.wantGzip {
+					w.Header().Set("Content-Encoding", "gzip")
+					gzipWriter := gzip.NewWriter(w)
+					defer gzipWriter.Close()
+					fmt.Fprint(gzipWriter, body)
+					return
+				}
+				fmt.Fprint(w, body)
+			}))
+			defer server.Close()
+
+			client := NewEthJsonRpcClient(server.URL, logging.NewDefaultLogger(logging.LevelDebug), tt.options...)
+			bn, err := client.EthGetCurrentBlockNumber(context.Background(), &EthGetCurrentBlockNumberRequest{RequestId: "1"})
+			assert.NoError(t, err)
+			assert.Equal(t, 16, bn)
+		})
+	}
+}
+
+func Test_clientOptions_buildHTTPClient(t *testing.T) {
+
+	tlsConfig := &tls.Config{ServerName: "node"}
+	opts := &clientOptions{headers: http.Header{}}
+	for _, option := range []ClientOption{
+		WithConnectionPool(50, 20, 30),
+		WithKeepAlive(true, time.Minute),
+		WithTLSConfig(tlsConfig),
+		WithProxy(nil),
+		WithTimeout(5 * time.Second),
+	} {
+		option(opts)
+	}
+
+	client := opts.buildHTTPClient()
+	assert.Equal(t, 5*time.Second, client.Timeout)
+	transport, ok := client.Transport.(*http.Transport)
+	assert.True(t, ok)
+	assert.Equal(t, 50, transport.MaxIdleConns)
+	assert.Equal(t, 20, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 30, transport.MaxConnsPerHost)
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+	assert.False(t, transport.DisableKeepAlives)
+	assert.Equal(t, tlsConfig, transport.TLSClientConfig)
+	assert.Nil(t, transport.Proxy)
+	// the default transport is not changed
+	assert.NotSame(t, http.DefaultTransport, transport)
+	assert.NotNil(t, http.DefaultTransport.(*http.Transport).Proxy)
+
+	// the default pool size
+	client = (&clientOptions{}).buildHTTPClient()
+	assert.Equal(t, defaultMaxIdleConnsPerHost, client.Transport.(*http.Transport).MaxIdleConnsPerHost)
+
+	// the custom transport is used as it is, if it's not an `*http.Transport`
+	custom := roundTripperFunc(func(r *http.Request) (*http.Response, error) { return nil, fmt.Errorf("not used") })
+	opts = &clientOptions{}
+	WithTransport(custom)(opts)
+	assert.NotNil(t, opts.buildHTTPClient().Transport)
+	_, ok = opts.buildHTTPClient().Transport.(*http.Transport)
+	assert.False(t, ok)
+
+	// the custom client overrides the others
+	httpClient := &http.Client{}
+	opts = &clientOptions{}
+	WithHTTPClient(httpClient)(opts)
+	WithProxy(http.ProxyURL(&url.URL{Host: "proxy:8080"}))(opts)
+	assert.Same(t, httpClient, opts.buildHTTPClient())
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (this roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return this(r)
+}
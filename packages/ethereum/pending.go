@@ -0,0 +1,200 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+)
+
+const (
+	// the default interval to poll the pending transaction filter
+	defaultPendingPollInterval = 2 * time.Second
+	// the default timeout of each call to chain
+	defaultPendingCallTimeout = 10 * time.Second
+	// the buffer size of the pending transaction channel
+	pendingChannelSize = 256
+)
+
+// PendingTransactionWatcher is implemented by the accessers which can push the transactions entering the mempool
+type PendingTransactionWatcher interface {
+	// WatchPendingTransactions watch the pending transactions. It's kept until `ctx` is done, and then the channel is closed.
+	WatchPendingTransactions(ctx context.Context) (<-chan *BlockTransaction, error)
+}
+
+// pendingTransactionPoller implements interface `PendingTransactionWatcher`
+// It polls a pending transaction filter by `eth_getFilterChanges`. The filter is installed again if it's expired.
+// The full transaction objects are requested from the filter, and they are got by hash if the node returns the hashes only.
+type pendingTransactionPoller struct {
+	chainAccesser EthereumChainAccesser
+	logger        logging.Logger
+	interval      time.Duration
+	callTimeout   time.Duration
+
+	// only accessed by the polling goroutine, after the first install
+	filterId string
+	watching bool
+	lock     sync.Mutex
+}
+
+// NewPendingTransactionPoller create a watcher polling the chain every `interval`. The default interval is used if it's not positive.
+func NewPendingTransactionPoller(chainAccesser EthereumChainAccesser, interval time.Duration, logger logging.Logger) PendingTransactionWatcher {
+	if interval <= 0 {
+		interval = defaultPendingPollInterval
+	}
+	return &pendingTransactionPoller{
+		chainAccesser: chainAccesser,
+		logger:        logger,
+		interval:      interval,
+		callTimeout:   defaultPendingCallTimeout,
+	}
+}
+
+// WatchPendingTransactions install the filter, and start polling in background. It can be called only once.
+// The error is returned if the filter can't be installed, e.g. it's not supported by the node.
+func (this *pendingTransactionPoller) WatchPendingTransactions(ctx context.Context) (<-chan *BlockTransaction, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.watching {
+		return nil, ErrAlreadySubscribed
+	}
+	if err := this.install(ctx); err != nil {
+		return nil, err
+	}
+	this.watching = true
+
+	trxs := make(chan *BlockTransaction, pendingChannelSize)
+	go this.run(ctx, trxs)
+	return trxs, nil
+}
+
+// run poll the filter until `ctx` is done
+func (this *pendingTransactionPoller) run(ctx context.Context, trxs chan<- *BlockTransaction) {
+	defer close(trxs)
+	defer this.uninstall()
+
+	ticker := time.NewTicker(this.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			this.logger.Infof("pending transaction watching exiting | filter: %s", this.filterId)
+			return
+		case <-ticker.C:
+			if err := this.poll(ctx, trxs); err != nil && ctx.Err() == nil {
+				this.logger.Errorf("poll pending transactions fail | filter: %s, retryable: %t, err: %s", this.filterId, IsRetryable(err), err.Error())
+			}
+		}
+	}
+}
+
+// poll get the changes of the filter, and push the transactions to `trxs`.
+// The filter is installed again if it's lost. The transactions entering the mempool in between are missed.
+func (this *pendingTransactionPoller) poll(ctx context.Context, trxs chan<- *BlockTransaction) error {
+
+	if this.filterId == "" {
+		if err := this.install(ctx); err != nil {
+			return err
+		}
+	}
+
+	callCtx, cancelFunc := context.WithTimeout(ctx, this.callTimeout)
+	changes, err := this.chainAccesser.EthGetFilterChanges(callCtx, &EthGetFilterChangesRequest{
+		FilterId:  this.filterId,
+		RequestId: fmt.Sprintf("%s-%d", MethodGetFilterChanges, time.Now().UnixNano()),
+	})
+	cancelFunc()
+	if errors.Is(err, ErrFilterNotFound) {
+		this.logger.Infof("pending transaction filter lost, install again | filter: %s", this.filterId)
+		this.filterId = ""
+		return this.install(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, raw := range changes {
+		trx, err := this.decode(ctx, raw)
+		if errors.Is(err, ErrTransactionNotFound) { // dropped from the mempool already
+			continue
+		}
+		if err != nil {
+			this.logger.Errorf("get pending transaction fail | change: %s, err: %s", string(raw), err.Error())
+			continue
+		}
+		select {
+		case trxs <- trx:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// decode get the transaction of a filter change, which is either a transaction object or a hash
+func (this *pendingTransactionPoller) decode(ctx context.Context, raw json.RawMessage) (*BlockTransaction, error) {
+
+	var hash string
+	if err := json.Unmarshal(raw, &hash); err != nil {
+		trx := &BlockTransaction{}
+		if err := json.Unmarshal(raw, trx); err != nil {
+			return nil, err
+		}
+		return trx, nil
+	}
+
+	ctx, cancelFunc := context.WithTimeout(ctx, this.callTimeout)
+	defer cancelFunc()
+	return this.chainAccesser.EthGetTransactionByHash(ctx, &EthGetTransactionByHashRequest{
+		TransactionHash: hash,
+		RequestId:       fmt.Sprintf("%s-%d", MethodGetTransactionByHash, time.Now().UnixNano()),
+	})
+}
+
+// install install the filter, requesting the full transaction objects first, and then the hashes if it's not supported
+func (this *pendingTransactionPoller) install(ctx context.Context) error {
+
+	ctx, cancelFunc := context.WithTimeout(ctx, this.callTimeout)
+	defer cancelFunc()
+
+	filterId, err := this.chainAccesser.EthNewPendingTransactionFilter(ctx, &EthNewPendingTransactionFilterRequest{
+		FullTransactions: true,
+		RequestId:        fmt.Sprintf("%s-%d", MethodNewPendingTransactionFilter, time.Now().UnixNano()),
+	})
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == errCodeInvalidParams {
+		this.logger.Infof("full pending transactions not supported, fall back to hashes | err: %s", err.Error())
+		filterId, err = this.chainAccesser.EthNewPendingTransactionFilter(ctx, &EthNewPendingTransactionFilterRequest{
+			RequestId: fmt.Sprintf("%s-%d", MethodNewPendingTransactionFilter, time.Now().UnixNano()),
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	this.filterId = filterId
+	this.logger.Infof("pending transaction filter installed | filter: %s", filterId)
+	return nil
+}
+
+// uninstall remove the filter from the node. It's expired by the node anyway if it fails.
+func (this *pendingTransactionPoller) uninstall() {
+	if this.filterId == "" {
+		return
+	}
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), this.callTimeout)
+	defer cancelFunc()
+	if _, err := this.chainAccesser.EthUninstallFilter(ctx, &EthUninstallFilterRequest{
+		FilterId:  this.filterId,
+		RequestId: fmt.Sprintf("%s-%d", MethodUninstallFilter, time.Now().UnixNano()),
+	}); err != nil {
+		this.logger.Errorf("uninstall pending transaction filter fail | filter: %s, err: %s", this.filterId, err.Error())
+	}
+}
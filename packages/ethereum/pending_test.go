@@ -0,0 +1,165 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFilterAccesser serves the pending transaction filter for test. The other methods are not implemented.
+type fakeFilterAccesser struct {
+	EthereumChainAccesser
+	lock sync.Mutex
+	// the full transaction objects are supported
+	full bool
+	// the changes returned by each poll in order. The filter is lost if it's nil.
+	changes  [][]json.RawMessage
+	trxs     map[string]*BlockTransaction
+	installs int
+	polls    int
+	// the filters uninstalled
+	uninstalled []string
+}
+
+func (this *fakeFilterAccesser) EthNewPendingTransactionFilter(ctx context.Context, req *EthNewPendingTransactionFilterRequest) (string, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if req.FullTransactions && !this.full {
+		return "", &RPCError{Code: errCodeInvalidParams, Message: "too many arguments, want at most 0"}
+	}
+	this.installs++
+	return fmt.Sprintf("0x%x", this.installs), nil
+}
+
+func (this *fakeFilterAccesser) EthGetFilterChanges(ctx context.Context, req *EthGetFilterChangesRequest) ([]json.RawMessage, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.polls >= len(this.changes) {
+		return nil, nil
+	}
+	changes := this.changes[this.polls]
+	this.polls++
+	if changes == nil {
+		return nil, &RPCError{Code: -32000, Message: "filter not found"}
+	}
+	return changes, nil
+}
+
+func (this *fakeFilterAccesser) EthGetTransactionByHash(ctx context.Context, req *EthGetTransactionByHashRequest) (*BlockTransaction, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	trx, ok := this.trxs[req.TransactionHash]
+	if !ok {
+		return nil, ErrTransactionNotFound
+	}
+	return trx, nil
+}
+
+func (this *fakeFilterAccesser) EthUninstallFilter(ctx context.Context, req *EthUninstallFilterRequest) (bool, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.uninstalled = append(this.uninstalled, req.FilterId)
+	return true, nil
+}
+
+func TestPendingTransactionPoller(t *testing.T) {
+
+	trx1 := &BlockTransaction{Hash: "0x01", From: "0xa", To: "0xb"}
+	trx2 := &BlockTransaction{Hash: "0x02", From: "0xb", To: "0xc"}
+	rawTrx1, _ := json.Marshal(trx1)
+	rawTrx2, _ := json.Marshal(trx2)
+
+	tests := []struct {
+		name          string
+		accesser      *fakeFilterAccesser
+		want          []string
+		wantInstalls  int
+		wantUninstall string
+	}{
+		{
+			name: "normal case 1 - full transactions",
+			accesser: &fakeFilterAccesser{
+				full:    true,
+				changes: [][]json.RawMessage{{rawTrx1}, {}, {rawTrx2}},
+			},
+			want:          []string{"0x01", "0x02"},
+			wantInstalls:  1,
+			wantUninstall: "0x1",
+		},
+		{
+			name: "normal case 2 - fall back to hashes",
+			accesser: &fakeFilterAccesser{
+				changes: [][]json.RawMessage{{json.RawMessage(`"0x01"`), json.RawMessage(`"0x03"`), json.RawMessage(`"0x02"`)}},
+				trxs:    map[string]*BlockTransaction{"0x01": trx1, "0x02": trx2},
+			},
+			// `0x03` is dropped before it's got
+			want:          []string{"0x01", "0x02"},
+			wantInstalls:  1,
+			wantUninstall: "0x1",
+		},
+		{
+			name: "normal case 3 - filter lost",
+			accesser: &fakeFilterAccesser{
+				full:    true,
+				changes: [][]json.RawMessage{{rawTrx1}, nil, {rawTrx2}},
+			},
+			want:          []string{"0x01", "0x02"},
+			wantInstalls:  2,
+			wantUninstall: "0x2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			watcher := NewPendingTransactionPoller(tt.accesser, 10*time.Millisecond, logging.NewDefaultLogger(logging.LevelDebug))
+			trxs, err := watcher.WatchPendingTransactions(ctx)
+			assert.NoError(t, err)
+
+			_, err = watcher.WatchPendingTransactions(ctx)
+			assert.Equal(t, ErrAlreadySubscribed, err)
+
+			var got []string
+			for len(got) < len(tt.want) {
+				select {
+				case trx := <-trxs:
+					got = append(got, trx.Hash)
+				case <-time.After(time.Second):
+					t.Fatalf("pending transactions not received | got: %v", got)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+
+			cancelFunc()
+			for range trxs { // wait for closing
+			}
+			tt.accesser.lock.Lock()
+			defer tt.accesser.lock.Unlock()
+			assert.Equal(t, tt.wantInstalls, tt.accesser.installs)
+			assert.Equal(t, []string{tt.wantUninstall}, tt.accesser.uninstalled)
+		})
+	}
+}
+
+func TestPendingTransactionPoller_NotSupported(t *testing.T) {
+
+	watcher := NewPendingTransactionPoller(&fakeUnsupportedAccesser{}, 0, logging.NewDefaultLogger(logging.LevelDebug))
+	_, err := watcher.WatchPendingTransactions(context.Background())
+	assert.Error(t, err)
+	assert.False(t, IsRetryable(err))
+}
+
+// fakeUnsupportedAccesser reject the filter methods
+type fakeUnsupportedAccesser struct {
+	EthereumChainAccesser
+}
+
+func (this *fakeUnsupportedAccesser) EthNewPendingTransactionFilter(ctx context.Context, req *EthNewPendingTransactionFilterRequest) (string, error) {
+	return "", &RPCError{Code: errCodeMethodNotFound, Message: "the method eth_newPendingTransactionFilter does not exist"}
+}
@@ -0,0 +1,219 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidQuantity = errors.New("invalid quantity")
+
+// Quantity is an unsigned integer encoded as `0x` prefixed hex in JSON RPC, e.g. gas and block numbers.
+type Quantity uint64
+
+// ParseQuantity parse a quantity in hex with the `0x` prefix, or in decimal
+func ParseQuantity(s string) (Quantity, error) {
+	var (
+		value uint64
+		err   error
+	)
+	if raw, ok := trimHexPrefix(s); ok {
+		value, err = strconv.ParseUint(raw, 16, 64)
+	} else {
+		value, err = strconv.ParseUint(s, 10, 64)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidQuantity, s)
+	}
+	return Quantity(value), nil
+}
+
+func (this Quantity) Uint64() uint64 {
+	return uint64(this)
+}
+
+// Hex return the hex form, with the `0x` prefix
+func (this Quantity) Hex() string {
+	return "0x" + strconv.FormatUint(uint64(this), 16)
+}
+
+func (this Quantity) String() string {
+	return strconv.FormatUint(uint64(this), 10)
+}
+
+func (this Quantity) MarshalText() ([]byte, error) {
+	return []byte(this.Hex()), nil
+}
+
+// UnmarshalText parse the quantity. The empty one is taken as 0.
+func (this *Quantity) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*this = 0
+		return nil
+	}
+	value, err := ParseQuantity(string(text))
+	if err != nil {
+		return err
+	}
+	*this = value
+	return nil
+}
+
+// BigInt is a big integer encoded as `0x` prefixed hex in JSON RPC, e.g. the value in wei.
+// The zero value is 0. It's immutable, so it's safe to be copied.
+type BigInt struct {
+	// nil for 0, so the equal values are in the same form
+	value *big.Int
+}
+
+// NewBigInt construct a BigInt with a copy of `value`
+func NewBigInt(value *big.Int) BigInt {
+	if value == nil || value.Sign() == 0 {
+		return BigInt{}
+	}
+	return BigInt{value: new(big.Int).Set(value)}
+}
+
+// ParseBigInt parse a non-negative integer in hex with the `0x` prefix, or in decimal
+func ParseBigInt(s string) (BigInt, error) {
+	value, ok := new(big.Int), false
+	if raw, isHex := trimHexPrefix(s); isHex {
+		value, ok = value.SetString(raw, 16)
+	} else {
+		value, ok = value.SetString(s, 10)
+	}
+	if !ok || value.Sign() < 0 {
+		return BigInt{}, fmt.Errorf("%w: %q", ErrInvalidQuantity, s)
+	}
+	return NewBigInt(value), nil
+}
+
+// Int return a copy of the value
+func (this BigInt) Int() *big.Int {
+	if this.value == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(this.value)
+}
+
+func (this BigInt) IsZero() bool {
+	return this.value == nil || this.value.Sign() == 0
+}
+
+// Hex return the hex form, with the `0x` prefix
+func (this BigInt) Hex() string {
+	return "0x" + this.Int().Text(16)
+}
+
+func (this BigInt) String() string {
+	return this.Int().String()
+}
+
+// Ether return the value in ether, taking it as wei
+func (this BigInt) Ether() string {
+	return FormatEther(this.Int())
+}
+
+// Gwei return the value in gwei, taking it as wei
+func (this BigInt) Gwei() string {
+	return FormatGwei(this.Int())
+}
+
+func (this BigInt) MarshalText() ([]byte, error) {
+	return []byte(this.Hex()), nil
+}
+
+// UnmarshalText parse the value. The empty one is taken as 0.
+func (this *BigInt) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*this = BigInt{}
+		return nil
+	}
+	value, err := ParseBigInt(string(text))
+	if err != nil {
+		return err
+	}
+	*this = value
+	return nil
+}
+
+// trimHexPrefix trim the `0x` prefix. false is returned if there is no prefix.
+func trimHexPrefix(s string) (string, bool) {
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		return s[2:], true
+	}
+	return s, false
+}
+
+// the decimals of the units, relative to wei
+const (
+	WeiDecimals   = 0
+	GweiDecimals  = 9
+	EtherDecimals = 18
+)
+
+// FormatUnits format `value` in the unit with `decimals`, e.g. 1.5 for 1500000000000000000 wei in ether.
+// The result is exact, and the trailing zeros of the fraction are trimmed.
+func FormatUnits(value *big.Int, decimals int) string {
+	if value == nil {
+		return "0"
+	}
+	digits := new(big.Int).Abs(value).String()
+	if decimals > 0 {
+		if len(digits) <= decimals {
+			digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+		}
+		integer, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+		digits = integer
+		if fraction != "" {
+			digits += "." + fraction
+		}
+	}
+	if value.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// ParseUnits parse a decimal amount in the unit with `decimals` into the base unit, e.g. 1500000000000000000 wei for 1.5 ether.
+// An error is returned if there are more fraction digits than `decimals`.
+func ParseUnits(s string, decimals int) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidQuantity, s)
+	}
+	integer, fraction, _ := strings.Cut(s, ".")
+	if len(fraction) > decimals {
+		return nil, fmt.Errorf("%w: %q, too many decimals", ErrInvalidQuantity, s)
+	}
+	if integer == "" || integer == "-" || integer == "+" {
+		integer += "0"
+	}
+	value, ok := new(big.Int).SetString(integer+fraction+strings.Repeat("0", decimals-len(fraction)), 10)
+	if !ok || strings.ContainsAny(fraction, "+-") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidQuantity, s)
+	}
+	return value, nil
+}
+
+// FormatEther format the value in wei into ether
+func FormatEther(wei *big.Int) string {
+	return FormatUnits(wei, EtherDecimals)
+}
+
+// FormatGwei format the value in wei into gwei
+func FormatGwei(wei *big.Int) string {
+	return FormatUnits(wei, GweiDecimals)
+}
+
+// ParseEther parse the amount in ether into wei
+func ParseEther(s string) (*big.Int, error) {
+	return ParseUnits(s, EtherDecimals)
+}
+
+// ParseGwei parse the amount in gwei into wei
+func ParseGwei(s string) (*big.Int, error) {
+	return ParseUnits(s, GweiDecimals)
+}
@@ -0,0 +1,166 @@
+package ethereum
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuantity(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		s       string
+		want    Quantity
+		wantErr bool
+	}{
+		{name: "hex", s: "0x5208", want: 21000},
+		{name: "upper case prefix", s: "0X10", want: 16},
+		{name: "decimal", s: "21000", want: 21000},
+		{name: "max", s: "0xffffffffffffffff", want: Quantity(^uint64(0))},
+		{name: "overflow", s: "0x10000000000000000", wantErr: true},
+		{name: "empty hex", s: "0x", wantErr: true},
+		{name: "invalid", s: "0xzz", wantErr: true},
+		{name: "negative", s: "-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseQuantity(tt.s)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidQuantity), err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseBigInt(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{name: "hex", s: "0xde0b6b3a7640000", want: "1000000000000000000"},
+		{name: "beyond int64", s: "0x1bc16d674ec800000", want: "32000000000000000000"},
+		{name: "decimal", s: "1000", want: "1000"},
+		{name: "zero", s: "0x0", want: "0"},
+		{name: "empty hex", s: "0x", wantErr: true},
+		{name: "negative", s: "-1", wantErr: true},
+		{name: "invalid", s: "1e18", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBigInt(tt.s)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidQuantity), err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestQuantity_JSON(t *testing.T) {
+
+	trx := Transaction{}
+	raw := `{"action":{"gas":"0x5208","value":"0x1bc16d674ec800000"},"result":{"gasUsed":""}}`
+	assert.NoError(t, json.Unmarshal([]byte(raw), &trx))
+	assert.Equal(t, Quantity(21000), trx.Action.Gas)
+	assert.Equal(t, "32", trx.Action.Value.Ether())
+	// the empty one kept by old versions is taken as 0
+	assert.Equal(t, Quantity(0), trx.Result.GasUsed)
+
+	encoded, err := json.Marshal(trx.Action)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"gas":"0x5208"`)
+	assert.Contains(t, string(encoded), `"value":"0x1bc16d674ec800000"`)
+
+	// the zero value
+	encoded, err = json.Marshal(Action{})
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"gas":"0x0"`)
+	assert.Contains(t, string(encoded), `"value":"0x0"`)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"gas":"0xzz"}`), &Action{}))
+	assert.Error(t, json.Unmarshal([]byte(`{"value":"-0x1"}`), &Action{}))
+}
+
+func TestBigInt_immutable(t *testing.T) {
+
+	value := big.NewInt(100)
+	b := NewBigInt(value)
+	value.SetInt64(1)
+	assert.Equal(t, "100", b.String())
+
+	b.Int().SetInt64(2)
+	assert.Equal(t, "100", b.String())
+	assert.True(t, BigInt{}.IsZero())
+	assert.Equal(t, "0x64", b.Hex())
+}
+
+func TestFormatUnits(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		value    *big.Int
+		decimals int
+		want     string
+	}{
+		{name: "ether", value: big.NewInt(1500000000000000000), decimals: EtherDecimals, want: "1.5"},
+		{name: "less than 1", value: big.NewInt(1), decimals: EtherDecimals, want: "0.000000000000000001"},
+		{name: "integer", value: big.NewInt(2000000000), decimals: GweiDecimals, want: "2"},
+		{name: "wei", value: big.NewInt(123), decimals: WeiDecimals, want: "123"},
+		{name: "negative", value: big.NewInt(-1500000000), decimals: GweiDecimals, want: "-1.5"},
+		{name: "zero", value: big.NewInt(0), decimals: EtherDecimals, want: "0"},
+		{name: "nil", value: nil, decimals: EtherDecimals, want: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FormatUnits(tt.value, tt.decimals))
+		})
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		s        string
+		decimals int
+		want     string
+		wantErr  bool
+	}{
+		{name: "ether", s: "1.5", decimals: EtherDecimals, want: "1500000000000000000"},
+		{name: "integer", s: "32", decimals: EtherDecimals, want: "32000000000000000000"},
+		{name: "no integer part", s: ".5", decimals: GweiDecimals, want: "500000000"},
+		{name: "negative", s: "-0.5", decimals: GweiDecimals, want: "-500000000"},
+		{name: "wei", s: "123", decimals: WeiDecimals, want: "123"},
+		{name: "too many decimals", s: "0.0000000001", decimals: GweiDecimals, wantErr: true},
+		{name: "invalid", s: "1.5 ether", decimals: EtherDecimals, wantErr: true},
+		{name: "sign in fraction", s: "1.-5", decimals: EtherDecimals, wantErr: true},
+		{name: "empty", s: "", decimals: EtherDecimals, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseUnits(tt.s, tt.decimals)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidQuantity), err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package ethereum
+
+import "math/big"
+
+const (
+	TransactionStatusSuccess = "success"
+	TransactionStatusFailure = "failure"
+)
+
+// ReceiptSummary is the outcome of a transaction taken from its receipt
+type ReceiptSummary struct {
+	// `TransactionStatusSuccess` or `TransactionStatusFailure`
+	Status  string   `json:"status"`
+	GasUsed Quantity `json:"gasUsed"`
+	// in wei. It's the base fee plus the priority fee since EIP-1559.
+	EffectiveGasPrice BigInt `json:"effectiveGasPrice"`
+	// the fee paid by the sender in wei, `GasUsed * EffectiveGasPrice`
+	Fee       BigInt `json:"fee"`
+	LogsCount int    `json:"logsCount"`
+}
+
+// NewReceiptSummary summarize a receipt
+func NewReceiptSummary(receipt *Receipt) *ReceiptSummary {
+
+	status := TransactionStatusSuccess
+	if receipt.Status == receiptStatusFailure {
+		status = TransactionStatusFailure
+	}
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed.Uint64()), receipt.EffectiveGasPrice.Int())
+	return &ReceiptSummary{
+		Status:            status,
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: receipt.EffectiveGasPrice,
+		Fee:               NewBigInt(fee),
+		LogsCount:         len(receipt.Logs),
+	}
+}
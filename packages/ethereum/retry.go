@@ -0,0 +1,88 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy is the policy to retry the failed calls, which are `IsRetryable`.
+// The delay before the n-th retry is `InitialBackoff * Multiplier^(n-1)`, capped by `MaxBackoff`, and reduced by a random `Jitter` fraction.
+// If the server asks for a longer delay by `Retry-After`, it's honored.
+type RetryPolicy struct {
+	// the max number of attempts, including the first one. 0 or 1 means no retry.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// 2 is used if it's less than 1
+	Multiplier float64
+	// the max fraction of the delay reduced randomly, in [0, 1]. It spreads the retries of the concurrent calls.
+	Jitter float64
+}
+
+var (
+	// DefaultRetryPolicy is used by `NewEthJsonRpcClient`
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+	}
+	// NoRetryPolicy never retries
+	NoRetryPolicy = RetryPolicy{MaxAttempts: 1}
+)
+
+// backoff get the delay before the retry after `attempt` failed attempts
+func (this RetryPolicy) backoff(attempt int, err error) time.Duration {
+
+	multiplier := this.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(this.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if this.MaxBackoff > 0 && delay > float64(this.MaxBackoff) {
+		delay = float64(this.MaxBackoff)
+	}
+	if this.Jitter > 0 {
+		delay -= delay * math.Min(this.Jitter, 1) * rand.Float64()
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > time.Duration(delay) {
+		return statusErr.RetryAfter
+	}
+	return time.Duration(delay)
+}
+
+// retry call `fn` until it succeeds, the error is not retryable, or the attempts are used up.
+// It gives up early if `ctx` would be done before the next attempt.
+func (this *EthJsonRpcClient) retry(ctx context.Context, method string, fn func() error) error {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= this.retryPolicy.MaxAttempts || !IsRetryable(err) {
+			return err
+		}
+
+		delay := this.retryPolicy.backoff(attempt, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+		this.logger.Infof("retry chain call | method: %s, attempt: %d, delay: %s, err: %s", method, attempt, delay, err.Error())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
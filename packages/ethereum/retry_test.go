@@ -0,0 +1,150 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_backoff(t *testing.T) {
+
+	policy := RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+		Multiplier:     2,
+	}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1, nil))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2, nil))
+	assert.Equal(t, 300*time.Millisecond, policy.backoff(3, nil))
+
+	// Retry-After is honored if it's longer
+	err := &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second}
+	assert.Equal(t, time.Second, policy.backoff(1, err))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		delay := policy.backoff(2, nil)
+		assert.True(t, delay >= 100*time.Millisecond && delay <= 200*time.Millisecond, delay)
+	}
+}
+
+func TestEthJsonRpcClient_retry(t *testing.T) {
+
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name string
+		// the responses of the attempts in order, the last one is repeated
+		statuses     []int
+		bodies       []string
+		retryAfter   string
+		timeout      time.Duration
+		wantAttempts int32
+		wantErr      error
+	}{
+		{
+			name:         "success after rate limited",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			bodies:       []string{"", `{"jsonrpc":"2.0","id":"1","result":"0x10"}`},
+			retryAfter:   "0",
+			wantAttempts: 2,
+		},
+		{
+			name:         "success after json rpc error",
+			statuses:     []int{http.StatusOK},
+			bodies:       []string{`{"jsonrpc":"2.0","id":"1","error":{"code":-32000,"message":"header not found"}}`, `{"jsonrpc":"2.0","id":"1","result":"0x10"}`},
+			wantAttempts: 2,
+		},
+		{
+			name:         "attempts used up",
+			statuses:     []int{http.StatusServiceUnavailable},
+			bodies:       []string{""},
+			wantAttempts: 3,
+			wantErr:      ErrBadStatus,
+		},
+		{
+			name:         "permanent error not retried",
+			statuses:     []int{http.StatusOK},
+			bodies:       []string{`{"jsonrpc":"2.0","id":"1","error":{"code":-32601,"message":"Method not found"}}`},
+			wantAttempts: 1,
+			wantErr:      &RPCError{Code: errCodeMethodNotFound, Message: "Method not found"},
+		},
+		{
+			name:         "Retry-After beyond deadline",
+			statuses:     []int{http.StatusTooManyRequests},
+			bodies:       []string{""},
+			retryAfter:   "10",
+			timeout:      time.Second,
+			wantAttempts: 1,
+			wantErr:      ErrRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := int(atomic.AddInt32(&attempts, 1)) - 1
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statuses[minIndex(i, len(tt.statuses))])
+				fmt.Fprint(w, tt.bodies[minIndex(i, len(tt.bodies))])
+			}))
+			defer server.Close()
+
+			client := NewEthJsonRpcClient(server.URL, logging.NewDefaultLogger(logging.LevelDebug), WithRetryPolicy(policy))
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			bn, err := client.EthGetCurrentBlockNumber(ctx, &EthGetCurrentBlockNumberRequest{RequestId: "1"})
+			assert.Equal(t, tt.wantAttempts, atomic.LoadInt32(&attempts))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					assert.Equal(t, tt.wantErr, err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 16, bn)
+		})
+	}
+}
+
+func TestEthJsonRpcClient_Timeout(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := NewEthJsonRpcClient(server.URL, logging.NewDefaultLogger(logging.LevelDebug), WithRetryPolicy(NoRetryPolicy))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.EthGetCurrentBlockNumber(ctx, &EthGetCurrentBlockNumberRequest{RequestId: "1"})
+	assert.True(t, errors.Is(err, ErrTimeout), err)
+	assert.True(t, IsRetryable(err))
+}
+
+func minIndex(i, length int) int {
+	if i < length {
+		return i
+	}
+	return length - 1
+}
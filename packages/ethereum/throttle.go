@@ -0,0 +1,200 @@
+package ethereum
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+)
+
+type ThrottleConfiguration struct {
+	// the sustained rate of calls, including the retries. A batch of N calls is counted as N. 0 means no rate limit.
+	RequestsPerSecond float64
+	// the max number of calls sent at once above the rate. 1 is used if it's less than 1.
+	Burst int
+	// the max number of HTTP requests in flight. 0 means no limit.
+	MaxInFlight int
+}
+
+// ThrottleStats is the state and counters of a throttled accesser
+type ThrottleStats struct {
+	// number of HTTP requests waiting for the rate limit or in-flight budget
+	Waiting  int64 `json:"waiting"`
+	InFlight int64 `json:"in_flight"`
+	// number of HTTP requests passed the throttle, including the retries
+	Requests uint64 `json:"requests"`
+	// number of tokens taken by the requests, a batch of N calls takes N
+	Tokens uint64 `json:"tokens"`
+	// number of HTTP requests given up while waiting, since the context is done
+	Rejected  uint64  `json:"rejected"`
+	AvgWaitMs float64 `json:"avg_wait_ms"`
+	MaxWaitMs float64 `json:"max_wait_ms"`
+}
+
+// ThrottleReporter is implemented by the accessers which may throttle the calls. `false` is returned if they're not throttled.
+type ThrottleReporter interface {
+	ThrottleStats() (ThrottleStats, bool)
+}
+
+// tokenBucket is a token bucket rate limiter. The tokens can be borrowed, so the waiting callers are served in order.
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// reserve take `n` tokens, and return the time to wait until they're available
+func (this *tokenBucket) reserve(now time.Time, n int) time.Duration {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if elapsed := now.Sub(this.last); elapsed > 0 {
+		this.tokens = math.Min(this.burst, this.tokens+elapsed.Seconds()*this.rate)
+		this.last = now
+	}
+	this.tokens -= float64(n)
+	if this.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-this.tokens / this.rate * float64(time.Second))
+}
+
+// cancel give back `n` reserved tokens, when the caller doesn't wait for them
+func (this *tokenBucket) cancel(n int) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.tokens = math.Min(this.burst, this.tokens+float64(n))
+}
+
+// throttle limits the rate and concurrency of the HTTP requests of a client, so the calls are queued on client side
+// instead of being rejected by the rate limit of upstream.
+// It's applied on each HTTP attempt, so the retries are counted as the provider does. A batch of N calls takes N tokens.
+type throttle struct {
+	// nil if there is no rate limit
+	bucket *tokenBucket
+	// the in-flight budget, nil if there is no limit
+	inflightSlots chan struct{}
+	logger        logging.Logger
+
+	waiting   int64
+	inflight  int64
+	requests  uint64
+	tokens    uint64
+	rejected  uint64
+	totalWait int64
+	maxWait   int64
+}
+
+// newThrottle construct the throttle with the rate limit and in-flight budget in `config`. nil is returned if there is no limit.
+func newThrottle(config ThrottleConfiguration, logger logging.Logger) *throttle {
+	if config.RequestsPerSecond <= 0 && config.MaxInFlight <= 0 {
+		return nil
+	}
+	this := &throttle{
+		logger: logger,
+	}
+	if config.RequestsPerSecond > 0 {
+		this.bucket = newTokenBucket(config.RequestsPerSecond, config.Burst, time.Now())
+	}
+	if config.MaxInFlight > 0 {
+		this.inflightSlots = make(chan struct{}, config.MaxInFlight)
+	}
+	return this
+}
+
+func (this *throttle) stats() ThrottleStats {
+	stats := ThrottleStats{
+		Waiting:   atomic.LoadInt64(&this.waiting),
+		InFlight:  atomic.LoadInt64(&this.inflight),
+		Requests:  atomic.LoadUint64(&this.requests),
+		Tokens:    atomic.LoadUint64(&this.tokens),
+		Rejected:  atomic.LoadUint64(&this.rejected),
+		MaxWaitMs: durationToMs(time.Duration(atomic.LoadInt64(&this.maxWait))),
+	}
+	if stats.Requests > 0 {
+		stats.AvgWaitMs = durationToMs(time.Duration(atomic.LoadInt64(&this.totalWait) / int64(stats.Requests)))
+	}
+	return stats
+}
+
+// acquire wait for `tokens` of rate limit, and then a slot of the in-flight budget, before an HTTP request is sent.
+// The error of `ctx` is returned if it's done before that.
+func (this *throttle) acquire(ctx context.Context, method string, tokens int) error {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	start := time.Now()
+	atomic.AddInt64(&this.waiting, 1)
+	defer atomic.AddInt64(&this.waiting, -1)
+
+	if this.bucket != nil {
+		if delay := this.bucket.reserve(start, tokens); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				this.bucket.cancel(tokens)
+				return this.reject(ctx, method)
+			case <-timer.C:
+			}
+		}
+	}
+
+	if this.inflightSlots != nil {
+		select {
+		case <-ctx.Done():
+			return this.reject(ctx, method)
+		case this.inflightSlots <- struct{}{}:
+		}
+	}
+
+	wait := int64(time.Since(start))
+	atomic.AddInt64(&this.inflight, 1)
+	atomic.AddUint64(&this.requests, 1)
+	atomic.AddUint64(&this.tokens, uint64(tokens))
+	atomic.AddInt64(&this.totalWait, wait)
+	for {
+		maxWait := atomic.LoadInt64(&this.maxWait)
+		if wait <= maxWait || atomic.CompareAndSwapInt64(&this.maxWait, maxWait, wait) {
+			break
+		}
+	}
+	return nil
+}
+
+func (this *throttle) release() {
+	atomic.AddInt64(&this.inflight, -1)
+	if this.inflightSlots != nil {
+		<-this.inflightSlots
+	}
+}
+
+func (this *throttle) reject(ctx context.Context, method string) error {
+	atomic.AddUint64(&this.rejected, 1)
+	err := wrapTransportError(ctx.Err())
+	this.logger.Errorf("call given up while throttled | method: %s, err: %s", method, err.Error())
+	return err
+}
+
+func durationToMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
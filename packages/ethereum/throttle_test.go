@@ -0,0 +1,208 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_tokenBucket_reserve(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucket := newTokenBucket(10, 2, now)
+
+	// the burst is served at once
+	assert.Equal(t, time.Duration(0), bucket.reserve(now, 1))
+	assert.Equal(t, time.Duration(0), bucket.reserve(now, 1))
+	// then in the rate, the waiting callers are queued
+	assert.Equal(t, 100*time.Millisecond, bucket.reserve(now, 1))
+	assert.Equal(t, 200*time.Millisecond, bucket.reserve(now, 1))
+
+	// the cancelled reservation is given back
+	bucket.cancel(1)
+	assert.Equal(t, 200*time.Millisecond, bucket.reserve(now, 1))
+
+	// refilled, but not beyond the burst
+	later := now.Add(time.Second)
+	assert.Equal(t, time.Duration(0), bucket.reserve(later, 1))
+	assert.Equal(t, time.Duration(0), bucket.reserve(later, 1))
+	assert.Equal(t, 100*time.Millisecond, bucket.reserve(later, 1))
+
+	// a batch takes several tokens at once
+	later = later.Add(time.Second)
+	assert.Equal(t, 100*time.Millisecond, bucket.reserve(later, 3))
+	bucket.cancel(3)
+	assert.Equal(t, time.Duration(0), bucket.reserve(later, 2))
+}
+
+// newThrottleTestServer answer `eth_blockNumber` and the batch calls, after the status codes in `statuses` in order.
+// The handler waits for `unblock` if it's set.
+func newThrottleTestServer(statuses []int, unblock chan struct{}) (*httptest.Server, *int32) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&attempts, 1)) - 1
+		if unblock != nil {
+			<-unblock
+		}
+		if i < len(statuses) {
+			w.WriteHeader(statuses[i])
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if len(body) > 0 && body[0] == '[' {
+			var requests []RPCRequest
+			json.Unmarshal(body, &requests)
+			responses := make([]RPCResponse, 0, len(requests))
+			for _, req := range requests {
+				responses = append(responses, RPCResponse{Jsonrpc: JsonRpcVersion, ID: req.ID, Result: json.RawMessage(`{"status":"0x1"}`)})
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+		fmt.Fprint(w, `{"jsonrpc":"2.0","id":"1","result":"0x10"}`)
+	}))
+	return server, &attempts
+}
+
+func TestEthJsonRpcClient_Throttle_RateLimit(t *testing.T) {
+
+	server, _ := newThrottleTestServer(nil, nil)
+	defer server.Close()
+	client := NewEthJsonRpcClient(server.URL, logging.NewDefaultLogger(logging.LevelDebug),
+		WithThrottle(ThrottleConfiguration{RequestsPerSecond: 100, Burst: 1}))
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := client.EthGetCurrentBlockNumber(context.Background(), &EthGetCurrentBlockNumberRequest{RequestId: "1"})
+		assert.NoError(t, err)
+	}
+	assert.True(t, time.Since(start) >= 35*time.Millisecond, time.Since(start))
+
+	stats, ok := client.(ThrottleReporter).ThrottleStats()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(5), stats.Requests)
+	assert.Equal(t, uint64(5), stats.Tokens)
+	assert.Equal(t, int64(0), stats.Waiting)
+	assert.Equal(t, int64(0), stats.InFlight)
+	assert.True(t, stats.MaxWaitMs > 0)
+	assert.True(t, stats.AvgWaitMs > 0 && stats.AvgWaitMs <= stats.MaxWaitMs)
+
+	// given up when ctx is done while waiting
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	client.EthGetCurrentBlockNumber(context.Background(), &EthGetCurrentBlockNumberRequest{RequestId: "1"})
+	_, err := client.EthGetCurrentBlockNumber(ctx, &EthGetCurrentBlockNumberRequest{RequestId: "1"})
+	assert.True(t, errors.Is(err, ErrTimeout), err)
+	stats, _ = client.(ThrottleReporter).ThrottleStats()
+	assert.Equal(t, uint64(1), stats.Rejected)
+}
+
+func TestEthJsonRpcClient_Throttle_Retries(t *testing.T) {
+
+	// rate limited twice, and then served
+	server, attempts := newThrottleTestServer([]int{http.StatusTooManyRequests, http.StatusTooManyRequests}, nil)
+	defer server.Close()
+	client := NewEthJsonRpcClient(server.URL, logging.NewDefaultLogger(logging.LevelDebug),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		WithThrottle(ThrottleConfiguration{RequestsPerSecond: 50, Burst: 1}))
+
+	// each retry waits for its own token, 20ms apart
+	start := time.Now()
+	bn, err := client.EthGetCurrentBlockNumber(context.Background(), &EthGetCurrentBlockNumberRequest{RequestId: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 16, bn)
+	assert.True(t, time.Since(start) >= 35*time.Millisecond, time.Since(start))
+	assert.Equal(t, int32(3), atomic.LoadInt32(attempts))
+
+	stats, _ := client.(ThrottleReporter).ThrottleStats()
+	assert.Equal(t, uint64(3), stats.Requests)
+	assert.Equal(t, uint64(3), stats.Tokens)
+}
+
+func TestEthJsonRpcClient_Throttle_Batch(t *testing.T) {
+
+	server, _ := newThrottleTestServer(nil, nil)
+	defer server.Close()
+	client := NewEthJsonRpcClient(server.URL, logging.NewDefaultLogger(logging.LevelDebug),
+		WithThrottle(ThrottleConfiguration{RequestsPerSecond: 100, Burst: 1}))
+
+	reqs := []*EthGetTransactionReceiptRequest{
+		{TransactionHash: "0x01", RequestId: "1"},
+		{TransactionHash: "0x02", RequestId: "2"},
+		{TransactionHash: "0x03", RequestId: "3"},
+	}
+	// the batch of 3 calls takes 3 tokens, so it and the next call take 4 tokens, 10ms apart
+	start := time.Now()
+	results, err := client.EthGetTransactionReceiptBatch(context.Background(), reqs)
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	_, err = client.EthGetCurrentBlockNumber(context.Background(), &EthGetCurrentBlockNumberRequest{RequestId: "1"})
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 25*time.Millisecond, time.Since(start))
+
+	stats, _ := client.(ThrottleReporter).ThrottleStats()
+	assert.Equal(t, uint64(2), stats.Requests)
+	assert.Equal(t, uint64(4), stats.Tokens)
+}
+
+func TestEthJsonRpcClient_Throttle_MaxInFlight(t *testing.T) {
+
+	unblock := make(chan struct{})
+	server, attempts := newThrottleTestServer(nil, unblock)
+	defer server.Close()
+	client := NewEthJsonRpcClient(server.URL, logging.NewDefaultLogger(logging.LevelDebug),
+		WithThrottle(ThrottleConfiguration{MaxInFlight: 2}))
+	reporter := client.(ThrottleReporter)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.EthGetCurrentBlockNumber(context.Background(), &EthGetCurrentBlockNumberRequest{RequestId: "1"})
+		}()
+	}
+
+	// 2 requests in flight, and the 3rd one is queued
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(attempts) == 2 }, time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool {
+		stats, _ := reporter.ThrottleStats()
+		return stats.Waiting == 1
+	}, time.Second, time.Millisecond)
+	stats, _ := reporter.ThrottleStats()
+	assert.Equal(t, int64(2), stats.InFlight)
+
+	close(unblock)
+	wg.Wait()
+	stats, _ = reporter.ThrottleStats()
+	assert.Equal(t, uint64(3), stats.Requests)
+	assert.Equal(t, int64(0), stats.Waiting)
+	assert.Equal(t, int64(0), stats.InFlight)
+}
+
+func TestMultiAccesser_ThrottleStats(t *testing.T) {
+
+	logger := logging.NewDefaultLogger(logging.LevelDebug)
+	throttled := NewEthJsonRpcClient(testEntryPoint, logger, WithThrottle(ThrottleConfiguration{MaxInFlight: 1}))
+	accesser := newTestMultiAccesser(t, MultiAccesserConfiguration{},
+		Endpoint{Name: "a", Accesser: throttled},
+		Endpoint{Name: "b", Accesser: NewEthJsonRpcClient(testEntryPoint, logger)},
+		Endpoint{Name: "c", Accesser: &fakeAccesser{name: "c"}},
+	)
+
+	stats := accesser.Stats()
+	assert.NotNil(t, stats[0].Throttle)
+	assert.Nil(t, stats[1].Throttle)
+	assert.Nil(t, stats[2].Throttle)
+}
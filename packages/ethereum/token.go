@@ -0,0 +1,172 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TransferTopic is the keccak256 hash of `Transfer(address,address,uint256)`.
+	// ERC-20 and ERC-721 share the same event signature, but the value (or token ID) of ERC-721 is indexed.
+	TransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+	TokenStandardERC20  = "erc20"
+	TokenStandardERC721 = "erc721"
+)
+
+var ErrNotTransferLog = errors.New("not a transfer log")
+
+// TokenTransfer is a decoded `Transfer` event of ERC-20 or ERC-721 token
+type TokenTransfer struct {
+	// the contract address of the token
+	Token    string `json:"token"`
+	Standard string `json:"standard"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	// the amount of ERC-20 token, in the smallest unit of the token. It's 0 for ERC-721.
+	Value BigInt `json:"value"`
+	// the token ID of ERC-721 token. It's 0 for ERC-20.
+	TokenId         BigInt `json:"tokenId"`
+	BlockNumber     int    `json:"blockNumber"`
+	BlockHash       string `json:"blockHash"`
+	TransactionHash string `json:"transactionHash"`
+	LogIndex        int    `json:"logIndex"`
+}
+
+// DecodeTransferLog decode a `Transfer` event. `ErrNotTransferLog` is returned if the log is not a standard one.
+func DecodeTransferLog(log Log) (TokenTransfer, error) {
+
+	if len(log.Topics) == 0 || !strings.EqualFold(log.Topics[0], TransferTopic) {
+		return TokenTransfer{}, ErrNotTransferLog
+	}
+
+	transfer := TokenTransfer{
+		Token:           strings.ToLower(log.Address),
+		BlockHash:       log.BlockHash,
+		TransactionHash: log.TransactionHash,
+	}
+
+	var err error
+	switch len(log.Topics) {
+	case 3: // ERC-20, the value is in data
+		transfer.Standard = TokenStandardERC20
+		transfer.Value, err = decodeWord(log.Data)
+	case 4: // ERC-721, the token ID is indexed
+		transfer.Standard = TokenStandardERC721
+		transfer.TokenId, err = decodeWord(log.Topics[3])
+	default:
+		return TokenTransfer{}, ErrNotTransferLog
+	}
+	if err != nil {
+		return TokenTransfer{}, fmt.Errorf("%w: %s", ErrNotTransferLog, err.Error())
+	}
+
+	if transfer.From, err = decodeAddressTopic(log.Topics[1]); err != nil {
+		return TokenTransfer{}, fmt.Errorf("%w: %s", ErrNotTransferLog, err.Error())
+	}
+	if transfer.To, err = decodeAddressTopic(log.Topics[2]); err != nil {
+		return TokenTransfer{}, fmt.Errorf("%w: %s", ErrNotTransferLog, err.Error())
+	}
+
+	bn, err := strconv.ParseInt(log.BlockNumber, 0, 64)
+	if err != nil {
+		return TokenTransfer{}, err
+	}
+	transfer.BlockNumber = int(bn)
+	if log.LogIndex != "" {
+		index, err := strconv.ParseInt(log.LogIndex, 0, 64)
+		if err != nil {
+			return TokenTransfer{}, err
+		}
+		transfer.LogIndex = int(index)
+	}
+	return transfer, nil
+}
+
+// AddressTopic encode an address as an indexed topic, which is left padded to 32 bytes
+func AddressTopic(address string) string {
+	raw := strings.ToLower(strings.TrimPrefix(address, "0x"))
+	return "0x" + strings.Repeat("0", 64-len(raw)) + raw
+}
+
+// GetTokenTransfers query the `Transfer` events sent from or to any of the addresses, in the block range (in hex).
+// The topics in different positions can't be OR-ed in one filter, so `eth_getLogs` is called twice.
+// The transfers are in the order of block number and log index.
+func GetTokenTransfers(ctx context.Context, chainAccesser EthereumChainAccesser, fromBlock, toBlock string, addresses []string, requestId string) ([]TokenTransfer, error) {
+
+	topics := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		topics = append(topics, AddressTopic(addr))
+	}
+
+	filters := [][][]string{
+		{{TransferTopic}, topics},      // sent from the addresses
+		{{TransferTopic}, nil, topics}, // sent to the addresses
+	}
+
+	var transfers []TokenTransfer
+	seen := make(map[string]bool)
+	for i, filter := range filters {
+		req := &EthGetLogsRequest{
+			FromBlock: fromBlock,
+			ToBlock:   toBlock,
+			Topics:    filter,
+			RequestId: fmt.Sprintf("%s-%d", requestId, i),
+		}
+		logs, err := chainAccesser.EthGetLogs(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			if log.Removed {
+				continue
+			}
+			transfer, err := DecodeTransferLog(log)
+			if err != nil { // non-standard event with the same signature
+				continue
+			}
+			// the transfers between 2 of the addresses are returned by both queries
+			key := fmt.Sprintf("%s-%d", transfer.TransactionHash, transfer.LogIndex)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			transfers = append(transfers, transfer)
+		}
+	}
+
+	sort.SliceStable(transfers, func(i, j int) bool {
+		if transfers[i].BlockNumber != transfers[j].BlockNumber {
+			return transfers[i].BlockNumber < transfers[j].BlockNumber
+		}
+		return transfers[i].LogIndex < transfers[j].LogIndex
+	})
+	return transfers, nil
+}
+
+// decodeWord decode a 32 bytes word as an unsigned integer
+func decodeWord(word string) (BigInt, error) {
+	raw := strings.TrimPrefix(word, "0x")
+	if len(raw) != 64 {
+		return BigInt{}, fmt.Errorf("invalid word length: %d", len(raw))
+	}
+	value, ok := new(big.Int).SetString(raw, 16)
+	if !ok {
+		return BigInt{}, fmt.Errorf("invalid word: %s", word)
+	}
+	return NewBigInt(value), nil
+}
+
+// decodeAddressTopic decode the address in an indexed topic, in lower case
+func decodeAddressTopic(topic string) (string, error) {
+	raw := strings.TrimPrefix(topic, "0x")
+	if len(raw) != 64 {
+		return "", fmt.Errorf("invalid topic length: %d", len(raw))
+	}
+	return "0x" + strings.ToLower(raw[24:]), nil
+}
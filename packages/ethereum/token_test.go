@@ -0,0 +1,113 @@
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeTransferLog(t *testing.T) {
+	from := fmt.Sprintf("0x%040x", 1)
+	to := fmt.Sprintf("0x%040x", 2)
+
+	tests := []struct {
+		name    string
+		log     Log
+		want    TokenTransfer
+		wantErr bool
+	}{
+		{
+			name: "normal case 1 - ERC-20",
+			log: Log{
+				Address:         "0x00000000000000000000000000000000000000CC",
+				Topics:          []string{TransferTopic, AddressTopic(from), AddressTopic(to)},
+				Data:            fmt.Sprintf("0x%064x", 1000),
+				BlockNumber:     "0x64",
+				BlockHash:       "0xb10c",
+				TransactionHash: "0x01",
+				LogIndex:        "0x3",
+			},
+			want: TokenTransfer{
+				Token:           "0x00000000000000000000000000000000000000cc",
+				Standard:        TokenStandardERC20,
+				From:            from,
+				To:              to,
+				Value:           NewBigInt(big.NewInt(1000)),
+				BlockNumber:     100,
+				BlockHash:       "0xb10c",
+				TransactionHash: "0x01",
+				LogIndex:        3,
+			},
+		},
+		{
+			name: "normal case 2 - ERC-721",
+			log: Log{
+				Address:         "0x00000000000000000000000000000000000000cc",
+				Topics:          []string{TransferTopic, AddressTopic(from), AddressTopic(to), fmt.Sprintf("0x%064x", 7)},
+				Data:            "0x",
+				BlockNumber:     "0x64",
+				TransactionHash: "0x01",
+				LogIndex:        "0x0",
+			},
+			want: TokenTransfer{
+				Token:           "0x00000000000000000000000000000000000000cc",
+				Standard:        TokenStandardERC721,
+				From:            from,
+				To:              to,
+				TokenId:         NewBigInt(big.NewInt(7)),
+				BlockNumber:     100,
+				TransactionHash: "0x01",
+			},
+		},
+		{
+			name:    "error case 1 - other event",
+			log:     Log{Topics: []string{fmt.Sprintf("0x%064x", 1), AddressTopic(from), AddressTopic(to)}},
+			wantErr: true,
+		},
+		{
+			name:    "error case 2 - value not in data",
+			log:     Log{Topics: []string{TransferTopic, AddressTopic(from), AddressTopic(to)}, Data: "0x"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeTransferLog(tt.log)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAddressTopic(t *testing.T) {
+	assert.Equal(t, "0x000000000000000000000000000000000000000000000000000000000000ffff", AddressTopic("0xFFFF"))
+}
+
+// Need to start the testserver to run this case
+// go run cmd/testserver/main.go
+func TestGetTokenTransfers(t *testing.T) {
+	this := &EthJsonRpcClient{
+		entryPoint: testEntryPoint,
+	}
+
+	// the testserver returns one transfer sent from, and one sent to each address in `toBlock`
+	addresses := []string{fmt.Sprintf("0x%040x", 1), fmt.Sprintf("0x%040x", 2)}
+	got, err := GetTokenTransfers(context.Background(), this, "0x64", "0xc8", addresses, "1024")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 4, len(got))
+	for i, transfer := range got {
+		assert.Equal(t, 200, transfer.BlockNumber)
+		assert.Equal(t, TokenStandardERC20, transfer.Standard)
+		if i > 0 { // in the order of log index
+			assert.Equal(t, true, got[i-1].LogIndex < transfer.LogIndex)
+		}
+	}
+	assert.Equal(t, addresses[0], got[0].From)
+	assert.Equal(t, addresses[1], got[3].To)
+}
@@ -0,0 +1,202 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/brofu/simple_ethereum_parser/packages/websocket"
+)
+
+var (
+	MethodSubscribe    = "eth_subscribe"
+	MethodSubscription = "eth_subscription"
+	SubscriptionHeads  = "newHeads"
+
+	ErrAlreadySubscribed = errors.New("already subscribed")
+)
+
+const (
+	// the default interval to reconnect after the socket is dropped
+	defaultReconnectInterval = 3 * time.Second
+	// the default duration without any message, after which the socket is treated as dropped
+	defaultIdleTimeout = time.Minute
+	// the buffer size of the head channel. The heads are dropped if the consumer can't catch up
+	headChannelSize = 16
+)
+
+// HeadSubscriber is implemented by the accessers which can push the new heads of chain
+type HeadSubscriber interface {
+	// SubscribeNewHeads subscribe the new heads. The subscription is kept until `ctx` is done, and then the channel is closed.
+	SubscribeNewHeads(ctx context.Context) (<-chan *Block, error)
+	// Connected report if the subscription is alive. The caller should fall back to polling if it's not.
+	Connected() bool
+}
+
+// wsSubscriptionParams is the params of `eth_subscription` notification
+type wsSubscriptionParams struct {
+	Subscription string          `json:"subscription"`
+	Result       json.RawMessage `json:"result"`
+}
+
+// wsMessage is a JSON RPC message received from the socket, which is either a response or a notification
+type wsMessage struct {
+	ID     interface{}     `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// EthWebSocketClient implements interface `HeadSubscriber`
+// It is based on WebSocket and JsonRPC 2.0. The socket is reconnected and the subscription is renewed after it's dropped.
+type EthWebSocketClient struct {
+	entryPoint        string
+	logger            logging.Logger
+	reconnectInterval time.Duration
+	idleTimeout       time.Duration
+
+	// 1 if the subscription is alive
+	connected  int32
+	subscribed bool
+	lock       sync.Mutex
+}
+
+func NewEthWebSocketClient(entryPoint string, logger logging.Logger) HeadSubscriber {
+	return &EthWebSocketClient{
+		entryPoint:        entryPoint,
+		logger:            logger,
+		reconnectInterval: defaultReconnectInterval,
+		idleTimeout:       defaultIdleTimeout,
+	}
+}
+
+// SubscribeNewHeads start the subscription in background. It can be called only once.
+func (this *EthWebSocketClient) SubscribeNewHeads(ctx context.Context) (<-chan *Block, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.subscribed {
+		return nil, ErrAlreadySubscribed
+	}
+	this.subscribed = true
+
+	heads := make(chan *Block, headChannelSize)
+	go this.run(ctx, heads)
+	return heads, nil
+}
+
+func (this *EthWebSocketClient) Connected() bool {
+	return atomic.LoadInt32(&this.connected) == 1
+}
+
+// run keep the subscription alive until `ctx` is done
+func (this *EthWebSocketClient) run(ctx context.Context, heads chan<- *Block) {
+	defer close(heads)
+
+	for {
+		err := this.subscribe(ctx, heads)
+		atomic.StoreInt32(&this.connected, 0)
+		if ctx.Err() != nil {
+			this.logger.Infof("head subscription existing | entry point: %s", this.entryPoint)
+			return
+		}
+		this.logger.Errorf("head subscription dropped | entry point: %s, err: %s", this.entryPoint, err.Error())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(this.reconnectInterval):
+		}
+	}
+}
+
+// subscribe connect to the entry point, subscribe the new heads, and push them to `heads` until the socket is dropped
+func (this *EthWebSocketClient) subscribe(ctx context.Context, heads chan<- *Block) error {
+
+	conn, err := websocket.Dial(ctx, this.entryPoint, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// unblock the reading when ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	requestId := fmt.Sprintf("%s-%d", MethodSubscribe, time.Now().UnixNano())
+	params, err := json.Marshal([]string{SubscriptionHeads})
+	if err != nil {
+		return err
+	}
+	rawReq, err := json.Marshal(RPCRequest{
+		Jsonrpc: JsonRpcVersion,
+		Method:  MethodSubscribe,
+		Params:  params,
+		ID:      requestId,
+	})
+	if err != nil {
+		return err
+	}
+	if err := conn.WriteMessage(rawReq); err != nil {
+		return err
+	}
+
+	var subscriptionId string
+	for {
+		conn.SetReadDeadline(time.Now().Add(this.idleTimeout))
+		_, rawData, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		msg := wsMessage{}
+		if err := json.Unmarshal(rawData, &msg); err != nil {
+			this.logger.Errorf("unmarshal message fail | entry point: %s, err: %s", this.entryPoint, err.Error())
+			continue
+		}
+
+		switch {
+		case msg.Method == MethodSubscription:
+			params := wsSubscriptionParams{}
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				this.logger.Errorf("unmarshal notification fail | entry point: %s, err: %s", this.entryPoint, err.Error())
+				continue
+			}
+			if subscriptionId == "" || params.Subscription != subscriptionId {
+				continue
+			}
+			head := &Block{}
+			if err := json.Unmarshal(params.Result, head); err != nil {
+				this.logger.Errorf("unmarshal head fail | entry point: %s, err: %s", this.entryPoint, err.Error())
+				continue
+			}
+			select {
+			case heads <- head:
+			default: // the consumer is busy, the later heads cover this one
+				this.logger.Infof("head channel full, drop head | number: %s", head.Number)
+			}
+		case msg.ID == requestId:
+			if msg.Error != nil {
+				return fmt.Errorf("subscribe fail | code: %d, message: %s", msg.Error.Code, msg.Error.Message)
+			}
+			if err := json.Unmarshal(msg.Result, &subscriptionId); err != nil {
+				return err
+			}
+			atomic.StoreInt32(&this.connected, 1)
+			this.logger.Infof("head subscription started | entry point: %s, subscription: %s", this.entryPoint, subscriptionId)
+		}
+	}
+}
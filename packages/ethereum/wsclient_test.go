@@ -0,0 +1,93 @@
+// The test cases in this file are more like integration test.
+// Need to start the testserver to run the cases in this file
+// go run cmd/testserver/main.go
+
+package ethereum
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+var testWsEntryPoint = "ws://localhost:8080/ws"
+
+func TestEthWebSocketClient_SubscribeNewHeads(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		entryPoint string
+		// number of heads to receive
+		heads int
+	}{
+		{
+			name:       "normal case",
+			entryPoint: testWsEntryPoint,
+			heads:      2,
+		},
+		{
+			name:       "resubscribe after the socket dropped",
+			entryPoint: testWsEntryPoint + "?drop_after=1",
+			heads:      3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &EthWebSocketClient{
+				entryPoint:        tt.entryPoint,
+				logger:            logging.NewDefaultLogger(logging.LevelDebug),
+				reconnectInterval: 10 * time.Millisecond,
+				idleTimeout:       defaultIdleTimeout,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			heads, err := client.SubscribeNewHeads(ctx)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = client.SubscribeNewHeads(ctx)
+			assert.Equal(t, ErrAlreadySubscribed, err)
+
+			for i := 0; i < tt.heads; i++ {
+				head, ok := <-heads
+				if !assert.True(t, ok) {
+					return
+				}
+				assert.NotEmpty(t, head.Number)
+				assert.NotEmpty(t, head.Hash)
+			}
+
+			// the channel is closed after ctx is done
+			cancel()
+			for range heads {
+			}
+			assert.False(t, client.Connected())
+		})
+	}
+}
+
+func TestEthWebSocketClient_SubscribeNewHeads_Unreachable(t *testing.T) {
+
+	client := &EthWebSocketClient{
+		entryPoint:        "ws://localhost:1/ws",
+		logger:            logging.NewDefaultLogger(logging.LevelDebug),
+		reconnectInterval: 10 * time.Millisecond,
+		idleTimeout:       defaultIdleTimeout,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	heads, err := client.SubscribeNewHeads(ctx)
+	assert.NoError(t, err)
+	assert.False(t, client.Connected())
+
+	// no head, and the channel is closed after ctx is done
+	_, ok := <-heads
+	assert.False(t, ok)
+}
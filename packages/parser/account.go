@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"context"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+)
+
+// AccountState is the balance and nonce of an address at a block
+type AccountState struct {
+	Address ethereum.Address `json:"address"`
+	// in wei
+	Balance ethereum.BigInt   `json:"balance"`
+	Nonce   ethereum.Quantity `json:"nonce"`
+	// the block the state is queried at
+	BlockNumber int `json:"block_number"`
+}
+
+// GetAccountState get the balance and nonce of an address, refreshed on each processed block.
+// `false` is returned if it's not subscribed, or not refreshed yet.
+func (this *serviceParser) GetAccountState(addr ethereum.Address) (AccountState, bool) {
+	address := addr.Hex()
+
+	if _, ok := this.store.GetCursor(address); !ok { // unsubscribed, or retired by LRU
+		this.removeAccountState(address)
+		return AccountState{}, false
+	}
+
+	this.accountLock.RLock()
+	defer this.accountLock.RUnlock()
+	state, ok := this.accounts[address]
+	if !ok {
+		return AccountState{}, false
+	}
+	state.Address = addr
+	return state, true
+}
+
+// updateAccountStates refresh the balances and nonces of the addresses at the block.
+// The old state is kept if the refresh fails, and refreshed again in next round.
+func (this *serviceParser) updateAccountStates(ctx context.Context, addresses []string, blockNum int) {
+	for _, addr := range addresses {
+		if _, ok := this.store.GetCursor(addr); !ok { // unsubscribed, or retired by LRU
+			continue
+		}
+		state, err := this.getAccountState(ctx, addr, blockNum)
+		if err != nil {
+			this.logger.Errorf("get account state fail | address: %s, block: %d, retryable: %t, error: %s", addr, blockNum, ethereum.IsRetryable(err), err.Error())
+			continue
+		}
+
+		this.accountLock.Lock()
+		if old, ok := this.accounts[addr]; !ok || old.BlockNumber <= blockNum {
+			this.accounts[addr] = state
+		}
+		this.accountLock.Unlock()
+		this.logger.Debugf("update account state success | address: %s, block: %d, balance: %s, nonce: %d", addr, blockNum, state.Balance, state.Nonce)
+	}
+}
+
+// getAccountState query the balance and nonce of an address at the block
+func (this *serviceParser) getAccountState(ctx context.Context, addr string, blockNum int) (AccountState, error) {
+
+	ctx, cancelFunc := context.WithDeadline(ctx, time.Now().Add(this.getTransactionsQueryTimeout))
+	defer cancelFunc()
+
+	blockTag := convertDecimalToHex(blockNum)
+	balance, err := this.chainAccesser.EthGetBalance(ctx, &ethereum.EthGetBalanceRequest{
+		Address:     addr,
+		BlockNumber: blockTag,
+		RequestId:   generateRequestId(),
+	})
+	if err != nil {
+		return AccountState{}, err
+	}
+	nonce, err := this.chainAccesser.EthGetTransactionCount(ctx, &ethereum.EthGetTransactionCountRequest{
+		Address:     addr,
+		BlockNumber: blockTag,
+		RequestId:   generateRequestId(),
+	})
+	if err != nil {
+		return AccountState{}, err
+	}
+	return AccountState{Balance: balance, Nonce: nonce, BlockNumber: blockNum}, nil
+}
+
+func (this *serviceParser) removeAccountState(addr string) {
+	this.accountLock.Lock()
+	defer this.accountLock.Unlock()
+	delete(this.accounts, addr)
+}
@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum/mocks"
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_serviceParser_updateAccountStates(t *testing.T) {
+	oneEther := ethereum.NewBigInt(big.NewInt(1e18))
+	twoEther := ethereum.NewBigInt(big.NewInt(2e18))
+
+	tests := []struct {
+		name    string
+		old     map[string]AccountState
+		balance ethereum.BigInt
+		nonce   ethereum.Quantity
+		err     error
+		// the state of `testAddress1` after refresh
+		want   AccountState
+		wantOk bool
+	}{
+		{
+			name:    "normal case 1 - first refresh",
+			balance: oneEther,
+			nonce:   3,
+			want:    AccountState{Address: ethereum.MustParseAddress(testAddress1), Balance: oneEther, Nonce: 3, BlockNumber: 200},
+			wantOk:  true,
+		},
+		{
+			name:    "normal case 2 - refreshed",
+			old:     map[string]AccountState{testAddress1: {Balance: twoEther, Nonce: 2, BlockNumber: 199}},
+			balance: oneEther,
+			nonce:   3,
+			want:    AccountState{Address: ethereum.MustParseAddress(testAddress1), Balance: oneEther, Nonce: 3, BlockNumber: 200},
+			wantOk:  true,
+		},
+		{
+			name:    "normal case 3 - newer state kept",
+			old:     map[string]AccountState{testAddress1: {Balance: twoEther, Nonce: 4, BlockNumber: 201}},
+			balance: oneEther,
+			nonce:   3,
+			want:    AccountState{Address: ethereum.MustParseAddress(testAddress1), Balance: twoEther, Nonce: 4, BlockNumber: 201},
+			wantOk:  true,
+		},
+		{
+			name:   "failure case 1 - old state kept",
+			old:    map[string]AccountState{testAddress1: {Balance: twoEther, Nonce: 2, BlockNumber: 199}},
+			err:    errors.New("connection reset by peer"),
+			want:   AccountState{Address: ethereum.MustParseAddress(testAddress1), Balance: twoEther, Nonce: 2, BlockNumber: 199},
+			wantOk: true,
+		},
+		{
+			name:   "failure case 2 - never refreshed",
+			err:    errors.New("connection reset by peer"),
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+			// `testAddress3` is not subscribed, so it's not queried
+			chainAccesser.EXPECT().EthGetBalance(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, req *ethereum.EthGetBalanceRequest) (ethereum.BigInt, error) {
+					assert.Equal(t, testAddress1, req.Address)
+					assert.Equal(t, "0xc8", req.BlockNumber)
+					return tt.balance, tt.err
+				})
+			if tt.err == nil {
+				chainAccesser.EXPECT().EthGetTransactionCount(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, req *ethereum.EthGetTransactionCountRequest) (ethereum.Quantity, error) {
+						assert.Equal(t, testAddress1, req.Address)
+						assert.Equal(t, "0xc8", req.BlockNumber)
+						return tt.nonce, nil
+					})
+			}
+
+			parser := &serviceParser{
+				logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+				chainAccesser:               chainAccesser,
+				store:                       NewMemoryTransactionStore(10, 10),
+				accounts:                    make(map[string]AccountState),
+				getTransactionsQueryTimeout: time.Second,
+			}
+			for addr, state := range tt.old {
+				parser.accounts[addr] = state
+			}
+			parser.store.PutAddress(testAddress1, 100)
+
+			parser.updateAccountStates(context.Background(), []string{testAddress1, testAddress3}, 200)
+
+			got, ok := parser.GetAccountState(ethereum.MustParseAddress(testAddress1))
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_serviceParser_GetAccountState(t *testing.T) {
+	parser := &serviceParser{
+		logger:   logging.NewDefaultLogger(logging.LevelDebug),
+		store:    NewMemoryTransactionStore(10, 10),
+		accounts: map[string]AccountState{testAddress1: {Nonce: 1, BlockNumber: 200}, testAddress2: {Nonce: 2, BlockNumber: 200}},
+	}
+	parser.store.PutAddress(testAddress1, 100)
+	parser.store.PutAddress(testAddress2, 100)
+
+	got, ok := parser.GetAccountState(ethereum.MustParseAddress(testAddress1))
+	assert.True(t, ok)
+	assert.Equal(t, ethereum.Quantity(1), got.Nonce)
+
+	// removed with the address
+	assert.True(t, parser.Unsubscribe(ethereum.MustParseAddress(testAddress1)))
+	_, ok = parser.GetAccountState(ethereum.MustParseAddress(testAddress1))
+	assert.False(t, ok)
+	assert.NotContains(t, parser.accounts, testAddress1)
+
+	// removed if the address is retired by LRU
+	parser.store.Evict(testAddress2)
+	_, ok = parser.GetAccountState(ethereum.MustParseAddress(testAddress2))
+	assert.False(t, ok)
+	assert.NotContains(t, parser.accounts, testAddress2)
+}
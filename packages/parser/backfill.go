@@ -0,0 +1,291 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+)
+
+const (
+	// the default number of blocks fetched in one backfill task
+	defaultBackfillChunkSize = 1000
+	// the default number of blocks fetched in one backfill task with `StrategyBlockScan`.
+	// The blocks are fetched one by one, so the chunk should be small enough to finish within the query timeout.
+	defaultBlockScanBackfillChunkSize = 10
+)
+
+// BackfillProgress is the progress of the historical backfill of an address
+type BackfillProgress struct {
+	FromBlock int `json:"from_block"`
+	// the last block to backfill, the live cursor starts from the block after it.
+	// it's -1 before the address is picked up by the task distributor.
+	ToBlock       int  `json:"to_block"`
+	FetchedBlocks int  `json:"fetched_blocks"`
+	TotalBlocks   int  `json:"total_blocks"`
+	Done          bool `json:"done"`
+	// the error blocking the job, e.g. a block is rejected by the node permanently.
+	// The block is retried until it's fetched. It's empty if the job goes well.
+	Error string `json:"error,omitempty"`
+}
+
+// backfillJob walks the history of an address backward in chunks, so the newer history is available first.
+type backfillJob struct {
+	address   string
+	fromBlock int
+	toBlock   int
+	// the highest block not fetched yet
+	nextBlock int
+	// `toBlock` is decided when the address is picked up by the task distributor
+	started bool
+	// there is a chunk of this job being fetched
+	inflight bool
+	// number of blocks fetched in one task. It's halved when a chunk times out or fails permanently, so the job always makes progress.
+	chunkSize int
+	// the error of the single block chunk failed permanently
+	err string
+}
+
+type backfillTask struct {
+	address   string
+	fromBlock int
+	toBlock   int
+}
+
+func (this *backfillJob) done() bool {
+	return this.started && this.nextBlock < this.fromBlock
+}
+
+func (this *backfillJob) progress() BackfillProgress {
+	if !this.started {
+		return BackfillProgress{FromBlock: this.fromBlock, ToBlock: -1}
+	}
+	total := maxInt(this.toBlock-this.fromBlock+1, 0)
+	return BackfillProgress{
+		FromBlock:     this.fromBlock,
+		ToBlock:       this.toBlock,
+		FetchedBlocks: minInt(this.toBlock-this.nextBlock, total),
+		TotalBlocks:   total,
+		Done:          this.done(),
+		Error:         this.err,
+	}
+}
+
+// SubscribeFrom subscribe an address, and queue a job to backfill its history from `fromBlock`.
+// `false` is returned if the address is already subscribed, since the history before its live cursor is unknown.
+func (this *serviceParser) SubscribeFrom(addr ethereum.Address, fromBlock int) bool {
+	address := addr.Hex()
+
+	if fromBlock < 0 {
+		return false
+	}
+	if _, ok := this.store.GetCursor(address); ok {
+		this.logger.Errorf("backfill an existing address is not supported | address: %s", address)
+		return false
+	}
+
+	this.backfillLock.Lock()
+	if _, ok := this.backfillJobs[address]; ok {
+		this.backfillLock.Unlock()
+		this.logger.Errorf("backfill job already exists | address: %s", address)
+		return false
+	}
+	this.backfillJobs[address] = &backfillJob{
+		address:   address,
+		fromBlock: fromBlock,
+	}
+	this.backfillLock.Unlock()
+
+	return this.Subscribe(addr)
+}
+
+// GetBackfillProgress get the progress of the backfill job of an address
+func (this *serviceParser) GetBackfillProgress(addr ethereum.Address) (BackfillProgress, bool) {
+	address := addr.Hex()
+
+	this.backfillLock.Lock()
+	defer this.backfillLock.Unlock()
+
+	job, ok := this.backfillJobs[address]
+	if !ok {
+		return BackfillProgress{}, false
+	}
+	return job.progress(), true
+}
+
+// activateBackfill decide the range of the backfill job, when the address is put into storage with the live cursor.
+func (this *serviceParser) activateBackfill(address string, liveCursor int) {
+	this.backfillLock.Lock()
+	defer this.backfillLock.Unlock()
+
+	job, ok := this.backfillJobs[address]
+	if !ok || job.started {
+		return
+	}
+	job.started = true
+	job.toBlock = liveCursor - 1
+	job.nextBlock = job.toBlock
+	job.chunkSize = this.backfillChunkSize
+	this.logger.Infof("backfill job started | address: %s, from block: %d, to block: %d", address, job.fromBlock, job.toBlock)
+	this.notifyBackfill()
+}
+
+// cancelBackfill drop the backfill job of an address
+func (this *serviceParser) cancelBackfill(address string) {
+	this.backfillLock.Lock()
+	defer this.backfillLock.Unlock()
+
+	delete(this.backfillJobs, address)
+}
+
+func (this *serviceParser) notifyBackfill() {
+	select {
+	case this.backfillNoti <- struct{}{}:
+	default: // there is already a pending notification
+	}
+}
+
+// startBackfillDistribution is the controller of distributing backfill tasks.
+// The tasks are sent to the same workers of live tasks, but the workers prefer the live ones.
+func (this *serviceParser) startBackfillDistribution(ctx context.Context) {
+
+	this.logger.Infof("backfill distributor started")
+	ticker := time.NewTicker(this.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			this.logger.Infof("backfill distributor existing")
+			return
+		case <-this.backfillNoti:
+		case <-ticker.C: // retry the failed tasks
+		}
+
+		for _, task := range this.nextBackfillTasks() {
+			select {
+			case this.backfillTasks <- task:
+			case <-ctx.Done():
+				this.logger.Infof("backfill distributor existing")
+				return
+			}
+		}
+	}
+}
+
+// nextBackfillTasks pick the next chunk of each started job, which has no chunk in flight.
+func (this *serviceParser) nextBackfillTasks() []backfillTask {
+	this.backfillLock.Lock()
+	defer this.backfillLock.Unlock()
+
+	var tasks []backfillTask
+	for _, job := range this.backfillJobs {
+		if !job.started || job.inflight || job.done() {
+			continue
+		}
+		job.inflight = true
+		tasks = append(tasks, backfillTask{
+			address:   job.address,
+			fromBlock: maxInt(job.nextBlock-job.chunkSize+1, job.fromBlock),
+			toBlock:   job.nextBlock,
+		})
+	}
+	return tasks
+}
+
+// executeBackfillTask fetch the history of one chunk, and move the job forward if it's successful.
+func (this *serviceParser) executeBackfillTask(ctx context.Context, task backfillTask) {
+
+	err := this.fetchHistory(ctx, task)
+
+	this.backfillLock.Lock()
+	defer this.backfillLock.Unlock()
+
+	job, ok := this.backfillJobs[task.address]
+	if !ok { // cancelled
+		return
+	}
+	job.inflight = false
+	if errors.Is(err, ErrAddressNotFound) { // the address is retired
+		delete(this.backfillJobs, task.address)
+		return
+	}
+	if err != nil && !isPermanentFailure(ctx, err) { // keep the job, the chunk would be retried
+		if isTimeout(ctx, err) && job.chunkSize > 1 { // the chunk is too large to fetch in time, retry with a smaller one
+			job.chunkSize /= 2
+			this.logger.Infof("backfill chunk timeout, shrink the chunk | address: %s, chunk size: %d", job.address, job.chunkSize)
+		}
+		return
+	}
+	if err != nil { // the chunk may be too large for the node, split it. The blocks are never skipped.
+		if task.fromBlock == task.toBlock { // the single block can't be fetched, keep the job blocked and expose the error
+			this.logger.Errorf("permanent failure of a single block, backfill blocked | address: %s, block: %d, error: %s",
+				task.address, task.toBlock, err.Error())
+			job.err = err.Error()
+			return
+		}
+		job.chunkSize = maxInt((task.toBlock-task.fromBlock+1)/2, 1)
+		this.logger.Infof("permanent failure, split the backfill chunk | address: %s, from block: %d, to block: %d, chunk size: %d, error: %s",
+			task.address, task.fromBlock, task.toBlock, job.chunkSize, err.Error())
+		this.notifyBackfill()
+		return
+	}
+	job.err = ""
+	job.nextBlock = task.fromBlock - 1
+	if job.done() {
+		this.logger.Infof("backfill job finished | address: %s, from block: %d, to block: %d", job.address, job.fromBlock, job.toBlock)
+	}
+	this.notifyBackfill()
+}
+
+// backfillChunkSizeOf get the number of blocks in one backfill task. The default one depends on the strategy.
+func backfillChunkSizeOf(config ServiceParserConfiguration) int {
+	if config.BackfillChunkSize > 0 {
+		return config.BackfillChunkSize
+	}
+	if config.Strategy == StrategyBlockScan {
+		return defaultBlockScanBackfillChunkSize
+	}
+	return defaultBackfillChunkSize
+}
+
+// isTimeout check if a call timed out by itself, instead of being canceled by the caller (e.g. the shutdown of parser)
+func isTimeout(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return errors.Is(err, ethereum.ErrTimeout) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (this *serviceParser) fetchHistory(ctx context.Context, task backfillTask) error {
+
+	req := &ethereum.EthGetCurrentTransactionsByAddressRequest{
+		FromBlock:   convertDecimalToHex(task.fromBlock),
+		ToBlock:     convertDecimalToHex(task.toBlock),
+		FromAddress: task.address,
+		ToAddress:   task.address,
+		RequestId:   generateRequestId(),
+	}
+
+	queryCtx, cancelFunc := context.WithDeadline(ctx, time.Now().Add(this.getTransactionsQueryTimeout))
+	defer cancelFunc()
+	resp, err := this.chainAccesser.EthGetCurrentTransactionsByAddress(queryCtx, req)
+	if err != nil {
+		this.logger.Errorf("call ethereum chain to backfill transactions fail | req: %v, error: %s", req, err.Error())
+		return err
+	}
+	if err := this.enrichTransactions(ctx, resp); err != nil {
+		this.logger.Errorf("enrich backfill transactions fail | req: %v, error: %s", req, err.Error())
+		return err
+	}
+
+	if err := this.store.AddHistoricalTransactions(task.address, resp); err != nil {
+		this.logger.Errorf("store historical transactions fail | address: %s, error: %s", task.address, err.Error())
+		return err
+	}
+
+	this.logger.Infof("backfill transactions success | address: %s, from block: %d, to block: %d, trx number: %d",
+		task.address, task.fromBlock, task.toBlock, len(resp))
+	return nil
+}
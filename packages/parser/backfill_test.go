@@ -0,0 +1,243 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum/mocks"
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_serviceParser_SubscribeFrom(t *testing.T) {
+	parser := &serviceParser{
+		logger:            logging.NewDefaultLogger(logging.LevelDebug),
+		store:             NewMemoryTransactionStore(10, 10),
+		backfillNoti:      make(chan struct{}, 1),
+		backfillJobs:      make(map[string]*backfillJob),
+		backfillChunkSize: 100,
+	}
+	parser.store.PutAddress(testAddress2, 100)
+
+	assert.Equal(t, true, parser.SubscribeFrom(ethereum.MustParseAddress(testAddress1), 10))
+	assert.Equal(t, false, parser.SubscribeFrom(ethereum.MustParseAddress(testAddress1), 10)) // duplicated
+	assert.Equal(t, false, parser.SubscribeFrom(ethereum.MustParseAddress(testAddress2), 10)) // existing
+	assert.Equal(t, false, parser.SubscribeFrom(ethereum.MustParseAddress(testAddress3), -1))
+	assert.Equal(t, []string{testAddress1}, parser.newAddresses)
+
+	progress, ok := parser.GetBackfillProgress(ethereum.MustParseAddress(testAddress1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, BackfillProgress{FromBlock: 10, ToBlock: -1}, progress)
+
+	// picked up with live cursor 260, the range is [10, 259]
+	parser.activateBackfill(testAddress1, 260)
+	tasks := parser.nextBackfillTasks()
+	assert.Equal(t, []backfillTask{{address: testAddress1, fromBlock: 160, toBlock: 259}}, tasks)
+	// no new task before the inflight one finished
+	assert.Equal(t, 0, len(parser.nextBackfillTasks()))
+
+	progress, _ = parser.GetBackfillProgress(ethereum.MustParseAddress(testAddress1))
+	assert.Equal(t, BackfillProgress{FromBlock: 10, ToBlock: 259, FetchedBlocks: 0, TotalBlocks: 250}, progress)
+}
+
+func Test_serviceParser_executeBackfillTask(t *testing.T) {
+	tests := []struct {
+		name         string
+		task         backfillTask
+		resp         []ethereum.Transaction
+		respErr      error
+		wantProgress BackfillProgress
+		wantTrx      []ethereum.Transaction
+	}{
+		{
+			name:         "normal case 1",
+			task:         backfillTask{address: testAddress1, fromBlock: 160, toBlock: 259},
+			resp:         []ethereum.Transaction{{BlockNumber: 200}},
+			wantProgress: BackfillProgress{FromBlock: 10, ToBlock: 259, FetchedBlocks: 100, TotalBlocks: 250},
+			wantTrx:      []ethereum.Transaction{{BlockNumber: 300}, {BlockNumber: 200}},
+		},
+		{
+			name:         "normal case 2 - last chunk",
+			task:         backfillTask{address: testAddress1, fromBlock: 10, toBlock: 259},
+			resp:         []ethereum.Transaction{{BlockNumber: 200}, {BlockNumber: 20}},
+			wantProgress: BackfillProgress{FromBlock: 10, ToBlock: 259, FetchedBlocks: 250, TotalBlocks: 250, Done: true},
+			wantTrx:      []ethereum.Transaction{{BlockNumber: 300}, {BlockNumber: 200}, {BlockNumber: 20}},
+		},
+		{
+			name:         "error case 1 - chain call fail, retried later",
+			task:         backfillTask{address: testAddress1, fromBlock: 160, toBlock: 259},
+			respErr:      errors.New("chain call fail"),
+			wantProgress: BackfillProgress{FromBlock: 10, ToBlock: 259, FetchedBlocks: 0, TotalBlocks: 250},
+			wantTrx:      []ethereum.Transaction{{BlockNumber: 300}},
+		},
+		{
+			name:         "error case 2 - permanent failure, chunk split",
+			task:         backfillTask{address: testAddress1, fromBlock: 160, toBlock: 259},
+			respErr:      &ethereum.RPCError{Code: -32602, Message: "invalid params"},
+			wantProgress: BackfillProgress{FromBlock: 10, ToBlock: 259, FetchedBlocks: 0, TotalBlocks: 250},
+			wantTrx:      []ethereum.Transaction{{BlockNumber: 300}},
+		},
+		{
+			name:    "error case 3 - permanent failure of a single block, blocked",
+			task:    backfillTask{address: testAddress1, fromBlock: 259, toBlock: 259},
+			respErr: &ethereum.RPCError{Code: -32602, Message: "invalid params"},
+			wantProgress: BackfillProgress{FromBlock: 10, ToBlock: 259, FetchedBlocks: 0, TotalBlocks: 250,
+				Error: "chain error | code: -32602, message: invalid params"},
+			wantTrx: []ethereum.Transaction{{BlockNumber: 300}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+			req := &ethereum.EthGetCurrentTransactionsByAddressRequest{
+				FromBlock:   convertDecimalToHex(tt.task.fromBlock),
+				ToBlock:     convertDecimalToHex(tt.task.toBlock),
+				FromAddress: tt.task.address,
+				ToAddress:   tt.task.address,
+			}
+			chainAccesser.EXPECT().EthGetCurrentTransactionsByAddress(gomock.Any(), gomock.AssignableToTypeOf(req)).DoAndReturn(
+				func(ctx context.Context, got *ethereum.EthGetCurrentTransactionsByAddressRequest) ([]ethereum.Transaction, error) {
+					req.RequestId = got.RequestId
+					assert.Equal(t, req, got)
+					return tt.resp, tt.respErr
+				})
+
+			parser := &serviceParser{
+				logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+				chainAccesser:               chainAccesser,
+				store:                       NewMemoryTransactionStore(10, 10),
+				backfillNoti:                make(chan struct{}, 1),
+				backfillJobs:                make(map[string]*backfillJob),
+				backfillChunkSize:           100,
+				getTransactionsQueryTimeout: time.Second,
+			}
+			parser.store.PutAddress(testAddress1, 260)
+			parser.store.AppendTransactions(testAddress1, []ethereum.Transaction{{BlockNumber: 300}}, 260, 301)
+			parser.backfillJobs[testAddress1] = &backfillJob{address: testAddress1, fromBlock: 10}
+			parser.activateBackfill(testAddress1, 260)
+			parser.nextBackfillTasks()
+
+			parser.executeBackfillTask(context.Background(), tt.task)
+
+			progress, _ := parser.GetBackfillProgress(ethereum.MustParseAddress(testAddress1))
+			assert.Equal(t, tt.wantProgress, progress)
+			assert.Equal(t, tt.wantTrx, parser.GetTransactions(ethereum.MustParseAddress(testAddress1)))
+			// the live cursor is not changed
+			cursor, _ := parser.store.GetCursor(testAddress1)
+			assert.Equal(t, 301, cursor)
+			assert.Equal(t, false, parser.backfillJobs[testAddress1].inflight)
+		})
+	}
+}
+
+func Test_serviceParser_executeBackfillTask_permanentFailure(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+	// the node rejects the ranges larger than 30 blocks, and the block 100 until it's fixed
+	badBlock := 100
+	chainAccesser.EXPECT().EthGetCurrentTransactionsByAddress(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, req *ethereum.EthGetCurrentTransactionsByAddressRequest) ([]ethereum.Transaction, error) {
+			from, _ := convertHexToDecimal(req.FromBlock)
+			to, _ := convertHexToDecimal(req.ToBlock)
+			if to-from+1 > 30 {
+				return nil, &ethereum.RPCError{Code: -32602, Message: "range too large"}
+			}
+			if from <= badBlock && badBlock <= to {
+				return nil, &ethereum.RPCError{Code: -32602, Message: "invalid block"}
+			}
+			return []ethereum.Transaction{{BlockNumber: to}}, nil
+		}).AnyTimes()
+
+	parser := &serviceParser{
+		logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+		chainAccesser:               chainAccesser,
+		store:                       NewMemoryTransactionStore(10, 100),
+		backfillNoti:                make(chan struct{}, 1),
+		backfillJobs:                make(map[string]*backfillJob),
+		backfillChunkSize:           100,
+		getTransactionsQueryTimeout: time.Second,
+	}
+	parser.store.PutAddress(testAddress1, 260)
+	parser.backfillJobs[testAddress1] = &backfillJob{address: testAddress1, fromBlock: 10}
+	parser.activateBackfill(testAddress1, 260)
+
+	runTasks := func() {
+		for i := 0; i < 100; i++ {
+			for _, task := range parser.nextBackfillTasks() {
+				parser.executeBackfillTask(context.Background(), task)
+			}
+		}
+	}
+
+	// the chunks are split until accepted, and the job is blocked at the bad block, instead of skipping it
+	runTasks()
+	progress, _ := parser.GetBackfillProgress(ethereum.MustParseAddress(testAddress1))
+	assert.Equal(t, BackfillProgress{FromBlock: 10, ToBlock: 259, FetchedBlocks: 159, TotalBlocks: 250,
+		Error: "chain error | code: -32602, message: invalid block"}, progress)
+
+	// the block is fetched once the node accepts it
+	badBlock = -1
+	runTasks()
+	progress, _ = parser.GetBackfillProgress(ethereum.MustParseAddress(testAddress1))
+	assert.Equal(t, BackfillProgress{FromBlock: 10, ToBlock: 259, FetchedBlocks: 250, TotalBlocks: 250, Done: true}, progress)
+}
+
+func Test_backfillChunkSizeOf(t *testing.T) {
+	assert.Equal(t, defaultBackfillChunkSize, backfillChunkSizeOf(ServiceParserConfiguration{}))
+	assert.Equal(t, defaultBlockScanBackfillChunkSize, backfillChunkSizeOf(ServiceParserConfiguration{Strategy: StrategyBlockScan}))
+	assert.Equal(t, 50, backfillChunkSizeOf(ServiceParserConfiguration{Strategy: StrategyBlockScan, BackfillChunkSize: 50}))
+}
+
+func Test_serviceParser_executeBackfillTask_blockScan(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+	// each block takes a while, a large chunk can't be scanned within the query timeout
+	chainAccesser.EXPECT().EthGetBlockWithTransactionsByNumber(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, req *ethereum.EthGetBlockByNumberRequest) (*ethereum.BlockWithTransactions, error) {
+			select {
+			case <-time.After(2 * time.Millisecond):
+				return &ethereum.BlockWithTransactions{Block: ethereum.Block{Number: req.BlockNumber}}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}).AnyTimes()
+
+	logger := logging.NewDefaultLogger(logging.LevelDebug)
+	config := ServiceParserConfiguration{Strategy: StrategyBlockScan}
+	accesser, err := applyStrategy(config.Strategy, chainAccesser, logger)
+	assert.Equal(t, nil, err)
+
+	for _, chunkSize := range []int{backfillChunkSizeOf(config), 1000} {
+		parser := &serviceParser{
+			logger:                      logger,
+			chainAccesser:               accesser,
+			store:                       NewMemoryTransactionStore(10, 10),
+			backfillNoti:                make(chan struct{}, 1),
+			backfillJobs:                make(map[string]*backfillJob),
+			backfillChunkSize:           chunkSize,
+			getTransactionsQueryTimeout: 100 * time.Millisecond,
+		}
+		parser.store.PutAddress(testAddress1, 1010)
+		parser.backfillJobs[testAddress1] = &backfillJob{address: testAddress1, fromBlock: 10}
+		parser.activateBackfill(testAddress1, 1010)
+
+		// the default chunk is small enough, and the large one is shrunk after it times out
+		for i := 0; i < 10; i++ {
+			for _, task := range parser.nextBackfillTasks() {
+				parser.executeBackfillTask(context.Background(), task)
+			}
+		}
+		progress, _ := parser.GetBackfillProgress(ethereum.MustParseAddress(testAddress1))
+		assert.Greater(t, progress.FetchedBlocks, 0, "chunk size: %d", chunkSize)
+	}
+}
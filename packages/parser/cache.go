@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a fixed size cache, the least recently used entry is evicted when it's full. It's safe for concurrent use.
+type lruCache struct {
+	lock     sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	// the most recently used one is in the front
+	order *list.List
+}
+
+type lruCacheEntry struct {
+	key   string
+	value interface{}
+}
+
+func newLRUCache(capacity int) *lruCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &lruCache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (this *lruCache) get(key string) (interface{}, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	element, ok := this.items[key]
+	if !ok {
+		return nil, false
+	}
+	this.order.MoveToFront(element)
+	return element.Value.(*lruCacheEntry).value, true
+}
+
+func (this *lruCache) put(key string, value interface{}) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if element, ok := this.items[key]; ok {
+		element.Value.(*lruCacheEntry).value = value
+		this.order.MoveToFront(element)
+		return
+	}
+	if this.order.Len() >= this.capacity {
+		oldest := this.order.Back()
+		this.order.Remove(oldest)
+		delete(this.items, oldest.Value.(*lruCacheEntry).key)
+	}
+	this.items[key] = this.order.PushFront(&lruCacheEntry{key: key, value: value})
+}
+
+func (this *lruCache) remove(key string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if element, ok := this.items[key]; ok {
+		this.order.Remove(element)
+		delete(this.items, key)
+	}
+}
+
+func (this *lruCache) len() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.order.Len()
+}
@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_lruCache(t *testing.T) {
+	cache := newLRUCache(2)
+
+	cache.put("a", 1)
+	cache.put("b", 2)
+	value, ok := cache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	// `b` is the least recently used one
+	cache.put("c", 3)
+	_, ok = cache.get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.len())
+
+	// updated in place
+	cache.put("a", 10)
+	value, _ = cache.get("a")
+	assert.Equal(t, 10, value)
+	assert.Equal(t, 2, cache.len())
+
+	cache.remove("a")
+	_, ok = cache.get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, cache.len())
+
+	// at least one entry is kept
+	cache = newLRUCache(0)
+	cache.put("a", 1)
+	cache.put("b", 2)
+	_, ok = cache.get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 1, cache.len())
+}
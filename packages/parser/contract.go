@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/brofu/simple_ethereum_parser/packages/abi"
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+)
+
+// abiRegistry is the ABIs registered by users, keyed by the contract address.
+// The transactions are decoded when they're queried, so the ones stored before registration are decoded too.
+type abiRegistry struct {
+	lock sync.RWMutex
+	abis map[string]*abi.ABI
+}
+
+func newABIRegistry() *abiRegistry {
+	return &abiRegistry{
+		abis: make(map[string]*abi.ABI),
+	}
+}
+
+// register set the ABI of a contract. The old one is replaced, and it's removed if `contractABI` is nil.
+func (this *abiRegistry) register(contract string, contractABI *abi.ABI) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if contractABI == nil {
+		delete(this.abis, contract)
+		return
+	}
+	this.abis[contract] = contractABI
+}
+
+func (this *abiRegistry) get(contract string) (*abi.ABI, bool) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	contractABI, ok := this.abis[contract]
+	return contractABI, ok
+}
+
+// decode attach the decoded calls to the transactions in place.
+// The ones not to the registered contracts, or not matching the ABI (e.g. plain transfers or unknown functions) are kept as is.
+func (this *abiRegistry) decode(transactions []ethereum.Transaction) {
+	for i := range transactions {
+		trx := &transactions[i]
+		contractABI, ok := this.get(strings.ToLower(trx.Action.To))
+		if !ok {
+			continue
+		}
+		method, ok := contractABI.MethodBySelector(trx.Action.Input)
+		if !ok {
+			continue
+		}
+		inputs, err := method.DecodeInput(trx.Action.Input)
+		if err != nil {
+			continue
+		}
+		decoded := &abi.DecodedCall{
+			Method:    method.Name,
+			Signature: method.Signature,
+			Inputs:    inputs,
+		}
+		// the output of failed calls is the revert reason
+		if trx.Error == "" && trx.Result.Output != "" && trx.Result.Output != "0x" {
+			decoded.Outputs, _ = method.DecodeOutput(trx.Result.Output)
+		}
+		trx.Decoded = decoded
+	}
+}
@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/brofu/simple_ethereum_parser/packages/abi"
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testTokenABI = `[{"type": "function", "name": "transfer", "inputs": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}], "outputs": [{"name": "", "type": "bool"}]}]`
+	// transfer(0x...02, 1000)
+	testTransferInput = "0xa9059cbb" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"00000000000000000000000000000000000000000000000000000000000003e8"
+	testTrueOutput = "0x0000000000000000000000000000000000000000000000000000000000000001"
+)
+
+func Test_abiRegistry_decode(t *testing.T) {
+	tokenABI, err := abi.Parse([]byte(testTokenABI))
+	if !assert.NoError(t, err) {
+		return
+	}
+	inputs := []abi.Param{
+		{Name: "to", Type: "address", Value: "0x0000000000000000000000000000000000000002"},
+		{Name: "amount", Type: "uint256", Value: "1000"},
+	}
+
+	tests := []struct {
+		name string
+		trx  ethereum.Transaction
+		want *abi.DecodedCall
+	}{
+		{
+			name: "normal case 1 - with output",
+			trx: ethereum.Transaction{
+				Action: ethereum.Action{To: "0x00000000000000000000000000000000000000AA", Input: testTransferInput},
+				Result: ethereum.Result{Output: testTrueOutput},
+			},
+			want: &abi.DecodedCall{
+				Method:    "transfer",
+				Signature: "transfer(address,uint256)",
+				Inputs:    inputs,
+				Outputs:   []abi.Param{{Name: "", Type: "bool", Value: true}},
+			},
+		},
+		{
+			name: "normal case 2 - failed call",
+			trx: ethereum.Transaction{
+				Action: ethereum.Action{To: "0x00000000000000000000000000000000000000aa", Input: testTransferInput},
+				Result: ethereum.Result{Output: "0x08c379a0"},
+				Error:  "Reverted",
+			},
+			want: &abi.DecodedCall{Method: "transfer", Signature: "transfer(address,uint256)", Inputs: inputs},
+		},
+		{
+			name: "normal case 3 - invalid output ignored",
+			trx: ethereum.Transaction{
+				Action: ethereum.Action{To: "0x00000000000000000000000000000000000000aa", Input: testTransferInput},
+				Result: ethereum.Result{Output: "0x01"},
+			},
+			want: &abi.DecodedCall{Method: "transfer", Signature: "transfer(address,uint256)", Inputs: inputs},
+		},
+		{
+			name: "normal case 4 - not registered",
+			trx: ethereum.Transaction{
+				Action: ethereum.Action{To: "0x00000000000000000000000000000000000000bb", Input: testTransferInput},
+			},
+		},
+		{
+			name: "normal case 5 - plain transfer",
+			trx: ethereum.Transaction{
+				Action: ethereum.Action{To: "0x00000000000000000000000000000000000000aa", Input: "0x"},
+			},
+		},
+		{
+			name: "normal case 6 - unknown function",
+			trx: ethereum.Transaction{
+				Action: ethereum.Action{To: "0x00000000000000000000000000000000000000aa", Input: "0x095ea7b3"},
+			},
+		},
+		{
+			name: "failure case 1 - truncated input",
+			trx: ethereum.Transaction{
+				Action: ethereum.Action{To: "0x00000000000000000000000000000000000000aa", Input: testTransferInput[:74]},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newABIRegistry()
+			registry.register("0x00000000000000000000000000000000000000aa", tokenABI)
+
+			transactions := []ethereum.Transaction{tt.trx}
+			registry.decode(transactions)
+			assert.Equal(t, tt.want, transactions[0].Decoded)
+		})
+	}
+}
+
+func Test_serviceParser_RegisterABI(t *testing.T) {
+	tokenABI, err := abi.Parse([]byte(testTokenABI))
+	if !assert.NoError(t, err) {
+		return
+	}
+	parser := &serviceParser{
+		logger: logging.NewDefaultLogger(logging.LevelDebug),
+		store:  NewMemoryTransactionStore(10, 10),
+		abis:   newABIRegistry(),
+	}
+	parser.store.PutAddress(testAddress1, 100)
+	parser.store.AppendTransactions(testAddress1, []ethereum.Transaction{
+		{BlockNumber: 100, Action: ethereum.Action{From: testAddress1, To: testAddress3, Input: testTransferInput}},
+	}, 100, 101)
+
+	// the ones stored before registration are decoded too
+	parser.RegisterABI(ethereum.MustParseAddress(testAddress3), tokenABI)
+	transactions := parser.GetTransactions(ethereum.MustParseAddress(testAddress1))
+	if assert.Len(t, transactions, 1) && assert.NotNil(t, transactions[0].Decoded) {
+		assert.Equal(t, "transfer", transactions[0].Decoded.Method)
+	}
+	// not stored
+	stored, _ := parser.store.GetTransactions(testAddress1)
+	assert.Nil(t, stored[0].Decoded)
+
+	parser.RegisterABI(ethereum.MustParseAddress(testAddress3), nil)
+	transactions = parser.GetTransactions(ethereum.MustParseAddress(testAddress1))
+	if assert.Len(t, transactions, 1) {
+		assert.Nil(t, transactions[0].Decoded)
+	}
+}
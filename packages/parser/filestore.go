@@ -0,0 +1,383 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+)
+
+const (
+	recordOpPut      = "put"
+	recordOpAppend   = "append"
+	recordOpHistory  = "history"
+	recordOpRollback = "rollback"
+	recordOpEvict    = "evict"
+	recordOpToken    = "token"
+
+	// the log is compacted when the number of records exceeds this
+	fileStoreCompactThreshold = 10000
+)
+
+// storeRecord is one line of the log file of `fileTransactionStore`
+type storeRecord struct {
+	Op           string                 `json:"op"`
+	Address      string                 `json:"address"`
+	Cursor       int                    `json:"cursor"`
+	FromCursor   int                    `json:"from_cursor,omitempty"`
+	Transactions []ethereum.Transaction `json:"transactions,omitempty"`
+	// the token transfers of `token` records, and the cursors are the token sync cursor
+	TokenTransfers []ethereum.TokenTransfer `json:"token_transfers,omitempty"`
+}
+
+// fileTransactionStore implements the `TransactionStore` interface, based on an append-only log file.
+// All the updates are appended to the log, and the in-memory index is rebuilt from the log when the store is opened,
+// so the data survives restarts.
+type fileTransactionStore struct {
+	// serialize the updates, so the records are logged in the same order as they're applied to the index
+	lock    sync.Mutex
+	path    string
+	file    *os.File
+	records int
+	// the size of the log file, to drop the partial record if a write fails
+	size  int64
+	index *memoryTransactionStore
+}
+
+// NewFileTransactionStore open (or create) the log file in `path`, and construct a file-backed `TransactionStore`.
+// The limitations are the same as `NewMemoryTransactionStore`.
+func NewFileTransactionStore(path string, maxAddressNumber, maxTransactionNumber int) (TransactionStore, error) {
+
+	store := &fileTransactionStore{
+		path:  path,
+		index: newMemoryTransactionStore(maxAddressNumber, maxTransactionNumber),
+	}
+
+	if err := store.replay(); err != nil {
+		return nil, err
+	}
+
+	// rewrite the log with the current data, this also drops the broken record at the tail (if any).
+	if err := store.compact(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// PutAddress log the `put` record, then apply it to the index.
+// The address retired by LRU (if any) is logged and evicted before, so the replay gets the same result.
+func (this *fileTransactionStore) PutAddress(address string, cursor int) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if _, ok := this.index.GetCursor(address); ok {
+		return nil
+	}
+	if retired, ok := this.index.retiring(); ok {
+		if err := this.writeRecord(storeRecord{Op: recordOpEvict, Address: retired}); err != nil {
+			return err
+		}
+		this.index.Evict(retired)
+	}
+	if err := this.writeRecord(storeRecord{Op: recordOpPut, Address: address, Cursor: cursor}); err != nil {
+		return err
+	}
+	if err := this.index.PutAddress(address, cursor); err != nil {
+		return err
+	}
+	return this.maybeCompact()
+}
+
+// AppendTransactions check the cursor and log the record, then apply it to the index.
+// So the index is never ahead of the log, if the record fails to be written.
+func (this *fileTransactionStore) AppendTransactions(address string, transactions []ethereum.Transaction, fromCursor, toCursor int) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	cursor, ok := this.index.GetCursor(address)
+	if !ok {
+		return ErrAddressNotFound
+	}
+	if cursor != fromCursor {
+		return ErrCursorMismatch
+	}
+	err := this.writeRecord(storeRecord{
+		Op:           recordOpAppend,
+		Address:      address,
+		Cursor:       toCursor,
+		FromCursor:   fromCursor,
+		Transactions: transactions,
+	})
+	if err != nil {
+		return err
+	}
+	if err := this.index.AppendTransactions(address, transactions, fromCursor, toCursor); err != nil {
+		return err
+	}
+	return this.maybeCompact()
+}
+
+func (this *fileTransactionStore) AddHistoricalTransactions(address string, transactions []ethereum.Transaction) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if _, ok := this.index.GetCursor(address); !ok {
+		return ErrAddressNotFound
+	}
+	if err := this.writeRecord(storeRecord{Op: recordOpHistory, Address: address, Transactions: transactions}); err != nil {
+		return err
+	}
+	if err := this.index.AddHistoricalTransactions(address, transactions); err != nil {
+		return err
+	}
+	return this.maybeCompact()
+}
+
+func (this *fileTransactionStore) GetTransactions(address string) ([]ethereum.Transaction, bool) {
+	return this.index.GetTransactions(address)
+}
+
+func (this *fileTransactionStore) GetCursor(address string) (int, bool) {
+	return this.index.GetCursor(address)
+}
+
+func (this *fileTransactionStore) AppendTokenTransfers(address string, transfers []ethereum.TokenTransfer, fromCursor, toCursor int) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	cursor, ok := this.index.GetTokenCursor(address)
+	if !ok {
+		return ErrAddressNotFound
+	}
+	if cursor != fromCursor {
+		return ErrCursorMismatch
+	}
+	err := this.writeRecord(storeRecord{
+		Op:             recordOpToken,
+		Address:        address,
+		Cursor:         toCursor,
+		FromCursor:     fromCursor,
+		TokenTransfers: transfers,
+	})
+	if err != nil {
+		return err
+	}
+	if err := this.index.AppendTokenTransfers(address, transfers, fromCursor, toCursor); err != nil {
+		return err
+	}
+	return this.maybeCompact()
+}
+
+func (this *fileTransactionStore) GetTokenTransfers(address string) ([]ethereum.TokenTransfer, bool) {
+	return this.index.GetTokenTransfers(address)
+}
+
+func (this *fileTransactionStore) GetTokenCursor(address string) (int, bool) {
+	return this.index.GetTokenCursor(address)
+}
+
+func (this *fileTransactionStore) Rollback(address string, forkBlock int) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if _, ok := this.index.GetCursor(address); !ok {
+		return ErrAddressNotFound
+	}
+	if err := this.writeRecord(storeRecord{Op: recordOpRollback, Address: address, Cursor: forkBlock}); err != nil {
+		return err
+	}
+	if err := this.index.Rollback(address, forkBlock); err != nil {
+		return err
+	}
+	return this.maybeCompact()
+}
+
+// Evict log the `evict` record, then remove the address from the index.
+// The address is kept if the record fails to be written, and `false` is returned.
+func (this *fileTransactionStore) Evict(address string) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if _, ok := this.index.GetCursor(address); !ok {
+		return false
+	}
+	if err := this.writeRecord(storeRecord{Op: recordOpEvict, Address: address}); err != nil {
+		return false
+	}
+	this.index.Evict(address)
+	// the eviction is done, the log would be compacted again by the coming updates if it fails
+	this.maybeCompact()
+	return true
+}
+
+func (this *fileTransactionStore) Addresses() []string {
+	return this.index.Addresses()
+}
+
+func (this *fileTransactionStore) Size() int {
+	return this.index.Size()
+}
+
+func (this *fileTransactionStore) Close() error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.file.Close()
+}
+
+// replay rebuild the index from the log file.
+// The broken record at the tail, which is usually caused by a crash during writing, is dropped.
+// A broken record followed by others means the log is corrupted, the error is returned so the valid records are not dropped by compaction.
+func (this *fileTransactionStore) replay() error {
+
+	file, err := os.Open(this.path)
+	if errors.Is(err, os.ErrNotExist) { // new store
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var brokenErr error
+	for line := 1; ; line++ {
+		raw, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		if len(bytes.TrimSpace(raw)) != 0 {
+			if brokenErr != nil {
+				return brokenErr
+			}
+			var record storeRecord
+			if err := json.Unmarshal(raw, &record); err != nil {
+				brokenErr = fmt.Errorf("broken record in store log | line: %d, err: %w", line, err)
+			} else {
+				this.applyRecord(record)
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+func (this *fileTransactionStore) applyRecord(record storeRecord) {
+	switch record.Op {
+	case recordOpPut:
+		this.index.PutAddress(record.Address, record.Cursor)
+	case recordOpAppend:
+		this.index.AppendTransactions(record.Address, record.Transactions, record.FromCursor, record.Cursor)
+	case recordOpHistory:
+		this.index.AddHistoricalTransactions(record.Address, record.Transactions)
+	case recordOpRollback:
+		this.index.Rollback(record.Address, record.Cursor)
+	case recordOpEvict:
+		this.index.Evict(record.Address)
+	case recordOpToken:
+		this.index.AppendTokenTransfers(record.Address, record.TokenTransfers, record.FromCursor, record.Cursor)
+	}
+}
+
+// compact rewrite the log file with the current data of index.
+func (this *fileTransactionStore) compact() error {
+
+	tmpPath := this.path + ".tmp"
+	tmpFile, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(tmpFile)
+	records := 0
+	data := this.index.dump()
+	// the least recently used first, so the order of LRU is kept after replay
+	for i := len(data) - 1; i >= 0; i-- {
+		records += 3
+		if err := encoder.Encode(storeRecord{Op: recordOpPut, Address: data[i].address, Cursor: data[i].blockNum}); err != nil {
+			tmpFile.Close()
+			return err
+		}
+		err := encoder.Encode(storeRecord{
+			Op:           recordOpAppend,
+			Address:      data[i].address,
+			Cursor:       data[i].blockNum,
+			FromCursor:   data[i].blockNum,
+			Transactions: data[i].transactions,
+		})
+		if err != nil {
+			tmpFile.Close()
+			return err
+		}
+		// the token sync cursor is initialized to the sync cursor by `put`
+		err = encoder.Encode(storeRecord{
+			Op:             recordOpToken,
+			Address:        data[i].address,
+			Cursor:         data[i].tokenCursor,
+			FromCursor:     data[i].blockNum,
+			TokenTransfers: data[i].tokenTransfers,
+		})
+		if err != nil {
+			tmpFile.Close()
+			return err
+		}
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, this.path); err != nil {
+		return err
+	}
+
+	if this.file != nil {
+		this.file.Close()
+	}
+	this.file, err = os.OpenFile(this.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := this.file.Stat()
+	if err != nil {
+		return err
+	}
+	this.records = records
+	this.size = info.Size()
+	return nil
+}
+
+// writeRecord append a record to the log file.
+func (this *fileTransactionStore) writeRecord(record storeRecord) error {
+
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := this.file.Write(append(raw, '\n')); err != nil {
+		// drop the partial record, so the following ones are not appended to it
+		this.file.Truncate(this.size)
+		return err
+	}
+	this.records += 1
+	this.size += int64(len(raw)) + 1
+	return nil
+}
+
+// maybeCompact compact the log if there are too many records
+func (this *fileTransactionStore) maybeCompact() error {
+	if this.records > fileStoreCompactThreshold+3*this.index.Size() {
+		return this.compact()
+	}
+	return nil
+}
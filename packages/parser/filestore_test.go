@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_fileTransactionStore_reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+
+	this, err := NewFileTransactionStore(path, 2, 10)
+	assert.Equal(t, nil, err)
+
+	this.PutAddress("0x0001", 100)
+	this.PutAddress("0x0002", 100)
+	this.AppendTransactions("0x0001", []ethereum.Transaction{{BlockNumber: 102}, {BlockNumber: 101}}, 100, 103)
+	this.AppendTransactions("0x0002", []ethereum.Transaction{{BlockNumber: 100}}, 100, 103)
+	this.AppendTokenTransfers("0x0001", []ethereum.TokenTransfer{{BlockNumber: 102}, {BlockNumber: 100}}, 100, 103)
+	this.Rollback("0x0001", 101)
+	this.GetTransactions("0x0001") // 0x0002 is the least recently used now
+	this.PutAddress("0x0003", 103) // retire 0x0002
+	assert.Equal(t, nil, this.Close())
+
+	reopened, err := NewFileTransactionStore(path, 2, 10)
+	assert.Equal(t, nil, err)
+	defer reopened.Close()
+
+	assert.Equal(t, []string{"0x0003", "0x0001"}, reopened.Addresses())
+	got, _ := reopened.GetTransactions("0x0001")
+	assert.Equal(t, []ethereum.Transaction{{BlockNumber: 101}}, got)
+	cursor, _ := reopened.GetCursor("0x0001")
+	assert.Equal(t, 102, cursor)
+	transfers, _ := reopened.GetTokenTransfers("0x0001")
+	assert.Equal(t, []ethereum.TokenTransfer{{BlockNumber: 100}}, transfers)
+	cursor, _ = reopened.GetTokenCursor("0x0001")
+	assert.Equal(t, 102, cursor)
+	cursor, _ = reopened.GetCursor("0x0003")
+	assert.Equal(t, 103, cursor)
+	_, ok := reopened.GetCursor("0x0002")
+	assert.Equal(t, false, ok)
+}
+
+func Test_fileTransactionStore_brokenTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+
+	this, err := NewFileTransactionStore(path, 2, 10)
+	assert.Equal(t, nil, err)
+	this.PutAddress("0x0001", 100)
+	this.Close()
+
+	// simulate a crash during writing
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"op":"put","addre`)
+	f.Close()
+
+	reopened, err := NewFileTransactionStore(path, 2, 10)
+	assert.Equal(t, nil, err)
+	defer reopened.Close()
+	assert.Equal(t, []string{"0x0001"}, reopened.Addresses())
+
+	// the store is still writable after the broken record is dropped
+	assert.Equal(t, nil, reopened.PutAddress("0x0002", 100))
+}
+
+func Test_fileTransactionStore_brokenRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+
+	this, err := NewFileTransactionStore(path, 2, 10)
+	assert.Equal(t, nil, err)
+	this.PutAddress("0x0001", 100)
+	this.Close()
+
+	// a broken record followed by a valid one
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("{\"op\":\"put\",\"addre\n{\"op\":\"put\",\"address\":\"0x0002\",\"cursor\":100}\n")
+	f.Close()
+	before, _ := os.ReadFile(path)
+
+	_, err = NewFileTransactionStore(path, 2, 10)
+	assert.NotEqual(t, nil, err)
+
+	// the log is not compacted, so the valid records are kept
+	after, _ := os.ReadFile(path)
+	assert.Equal(t, before, after)
+}
+
+func Test_fileTransactionStore_writeFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.log")
+
+	this, err := NewFileTransactionStore(path, 2, 10)
+	assert.Equal(t, nil, err)
+	this.PutAddress("0x0001", 100)
+
+	// the records can't be written any more
+	this.(*fileTransactionStore).file.Close()
+
+	assert.NotEqual(t, nil, this.AppendTransactions("0x0001", []ethereum.Transaction{{BlockNumber: 101}}, 100, 102))
+	cursor, _ := this.GetCursor("0x0001")
+	assert.Equal(t, 100, cursor)
+	got, _ := this.GetTransactions("0x0001")
+	assert.Equal(t, []ethereum.Transaction{}, got)
+
+	assert.NotEqual(t, nil, this.AppendTokenTransfers("0x0001", []ethereum.TokenTransfer{{BlockNumber: 101}}, 100, 102))
+	cursor, _ = this.GetTokenCursor("0x0001")
+	assert.Equal(t, 100, cursor)
+
+	assert.NotEqual(t, nil, this.PutAddress("0x0002", 100))
+	assert.Equal(t, []string{"0x0001"}, this.Addresses())
+
+	assert.Equal(t, false, this.Evict("0x0001"))
+	assert.Equal(t, []string{"0x0001"}, this.Addresses())
+}
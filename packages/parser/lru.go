@@ -108,3 +108,18 @@ func (this *addressTransactionLRU) allAddresses() []string {
 	}
 	return addresses
 }
+
+// removeAddress remove an address from the LRU. `false` is returned if it doesn't exist.
+func (this *addressTransactionLRU) removeAddress(addr string) bool {
+	node, ok := this.dataMap[addr]
+	if !ok {
+		return false
+	}
+
+	delete(this.dataMap, addr) // remove from the map
+
+	// remove from the list
+	node.previous.next = node.next
+	node.next.previous = node.previous
+	return true
+}
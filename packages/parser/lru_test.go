@@ -339,3 +339,67 @@ func Test_addressTransactionLRU_allAddresses(t *testing.T) {
 		})
 	}
 }
+
+func Test_addressTransactionLRU_removeAddress(t *testing.T) {
+	type fields struct {
+		capability int
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		data   []addressTransaction
+		remove string
+		want   bool
+		left   []string
+	}{
+		{
+			name: "normal case 1",
+			fields: fields{
+				capability: 3,
+			},
+			data: []addressTransaction{
+				{
+					address:  "1",
+					blockNum: 1,
+				},
+				{
+					address:  "2",
+					blockNum: 2,
+				},
+				{
+					address:  "3",
+					blockNum: 3,
+				},
+			},
+			remove: "2",
+			want:   true,
+			left:   []string{"3", "1"},
+		},
+		{
+			name: "normal case 2 - not existing",
+			fields: fields{
+				capability: 3,
+			},
+			data: []addressTransaction{
+				{
+					address:  "1",
+					blockNum: 1,
+				},
+			},
+			remove: "2",
+			want:   false,
+			left:   []string{"1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			this := newAddressTransactionLRU(tt.fields.capability)
+			for _, d := range tt.data {
+				this.putAddress(d)
+			}
+			assert.Equal(t, tt.want, this.removeAddress(tt.remove))
+			assert.Equal(t, len(tt.left), this.size())
+			assert.Equal(t, tt.left, this.allAddresses())
+		})
+	}
+}
@@ -1,9 +1,32 @@
 package parser
 
-import "github.com/brofu/simple_ethereum_parser/packages/ethereum"
+import (
+	"github.com/brofu/simple_ethereum_parser/packages/abi"
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+)
 
+// Parser is the interface to access the transactions of addresses.
+// The addresses are validated by `ethereum.ParseAddress`, so different cases of the same address are treated as one.
 type Parser interface {
 	GetCurrentBlock() int
-	Subscribe(string) bool
-	GetTransactions(address string) []ethereum.Transaction
+	Subscribe(ethereum.Address) bool
+	// SubscribeFrom subscribe an address, and backfill its history from the block
+	SubscribeFrom(address ethereum.Address, fromBlock int) bool
+	// GetBackfillProgress get the progress of the history backfill of an address
+	GetBackfillProgress(address ethereum.Address) (BackfillProgress, bool)
+	// GetSyncStatus get the sync cursors of an address, and the errors blocking them. `false` is returned if it's not available.
+	GetSyncStatus(address ethereum.Address) (SyncStatus, bool)
+	// Unsubscribe stop watching an address, and remove its data. `false` is returned if it's not subscribed.
+	Unsubscribe(ethereum.Address) bool
+	GetTransactions(address ethereum.Address) []ethereum.Transaction
+	// GetFinalizedTransactions only returns the transactions with enough confirmations
+	GetFinalizedTransactions(address ethereum.Address) []ethereum.Transaction
+	// GetTokenTransfers get the ERC-20 and ERC-721 transfers sent from or to an address
+	GetTokenTransfers(address ethereum.Address) []ethereum.TokenTransfer
+	// GetAccountState get the balance and nonce of an address. `false` is returned if it's not available.
+	GetAccountState(address ethereum.Address) (AccountState, bool)
+	// GetPendingTransactions get the transactions of an address seen in the mempool, and their latest status
+	GetPendingTransactions(address ethereum.Address) []PendingTransaction
+	// RegisterABI set the ABI of a contract, so the calls to it are decoded in the transactions. It's removed if the ABI is nil.
+	RegisterABI(contract ethereum.Address, contractABI *abi.ABI)
 }
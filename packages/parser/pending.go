@@ -0,0 +1,214 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+)
+
+const (
+	// the default duration a pending transaction is kept after it's not found on chain, before it's taken as dropped
+	defaultPendingDropTimeout = 5 * time.Minute
+	// the duration a confirmed or dropped transaction is kept, so the users can see the result
+	pendingRetention = 10 * time.Minute
+)
+
+// PendingStatus is the status of a transaction seen in the mempool
+type PendingStatus string
+
+const (
+	PendingStatusPending   PendingStatus = "pending"
+	PendingStatusConfirmed PendingStatus = "confirmed"
+	// evicted from the mempool, or replaced by another transaction with the same nonce
+	PendingStatusDropped PendingStatus = "dropped"
+)
+
+// PendingTransaction is a transaction of an address seen in the mempool
+type PendingTransaction struct {
+	Hash string `json:"hash"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// in wei
+	Value  ethereum.BigInt `json:"value"`
+	Status PendingStatus   `json:"status"`
+	// the block it's mined in. 0 if it's not confirmed.
+	BlockNumber int       `json:"block_number"`
+	FirstSeen   time.Time `json:"first_seen"`
+	// the last time it's found on chain
+	LastSeen time.Time `json:"last_seen"`
+	// the time it's confirmed or dropped
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+// GetPendingTransactions get the transactions of an address seen in the mempool, in the order they're seen.
+// The confirmed and dropped ones are kept for a while after they're resolved.
+func (this *serviceParser) GetPendingTransactions(addr ethereum.Address) []PendingTransaction {
+	address := addr.Hex()
+
+	if _, ok := this.store.GetCursor(address); !ok { // unsubscribed, or retired by LRU
+		this.removePendingTransactions(address)
+		return []PendingTransaction{}
+	}
+
+	this.pendingLock.Lock()
+	defer this.pendingLock.Unlock()
+	trxs := make([]PendingTransaction, 0, len(this.pendings[address]))
+	for _, trx := range this.pendings[address] {
+		trxs = append(trxs, *trx)
+	}
+	sort.Slice(trxs, func(i, j int) bool {
+		if trxs[i].FirstSeen.Equal(trxs[j].FirstSeen) {
+			return trxs[i].Hash < trxs[j].Hash
+		}
+		return trxs[i].FirstSeen.Before(trxs[j].FirstSeen)
+	})
+	return trxs
+}
+
+// startPendingTracking track the pending transactions of the addresses from the watcher,
+// and check if they're mined or dropped every `interval`
+func (this *serviceParser) startPendingTracking(ctx context.Context) {
+
+	this.logger.Infof("pending transaction tracker started")
+	trxs, err := this.pendingWatcher.WatchPendingTransactions(ctx)
+	if err != nil { // not fatal, the mined transactions are still indexed
+		this.logger.Errorf("watch pending transactions fail | error: %s", err.Error())
+		return
+	}
+	ticker := time.NewTicker(this.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			this.logger.Infof("pending transaction tracker exiting")
+			return
+		case trx, ok := <-trxs:
+			if !ok {
+				trxs = nil
+				continue
+			}
+			this.trackPendingTransaction(trx, time.Now())
+		case <-ticker.C:
+			this.resolvePendingTransactions(ctx, time.Now())
+		}
+	}
+}
+
+// trackPendingTransaction record a transaction for the subscribed addresses it's sent from or to
+func (this *serviceParser) trackPendingTransaction(trx *ethereum.BlockTransaction, now time.Time) {
+
+	this.pendingLock.Lock()
+	defer this.pendingLock.Unlock()
+
+	for _, addr := range []string{strings.ToLower(trx.From), strings.ToLower(trx.To)} {
+		if addr == "" {
+			continue
+		}
+		if _, ok := this.store.GetCursor(addr); !ok {
+			continue
+		}
+		if _, ok := this.pendings[addr][trx.Hash]; ok {
+			continue
+		}
+		if this.pendings[addr] == nil {
+			this.pendings[addr] = make(map[string]*PendingTransaction)
+		}
+		this.pendings[addr][trx.Hash] = &PendingTransaction{
+			Hash:      trx.Hash,
+			From:      trx.From,
+			To:        trx.To,
+			Value:     trx.Value,
+			Status:    PendingStatusPending,
+			FirstSeen: now,
+			LastSeen:  now,
+		}
+		this.logger.Infof("new pending transaction | address: %s, hash: %s, from: %s, to: %s, value: %s ETH", addr, trx.Hash, trx.From, trx.To, trx.Value.Ether())
+	}
+}
+
+// resolvePendingTransactions check the pending transactions on chain. They're moved to confirmed once they're mined,
+// or dropped if they're not found for `pendingDropTimeout`. The resolved ones are removed after `pendingRetention`.
+func (this *serviceParser) resolvePendingTransactions(ctx context.Context, now time.Time) {
+
+	this.pendingLock.Lock()
+	hashes := map[string]struct{}{}
+	for addr, trxs := range this.pendings {
+		if _, ok := this.store.GetCursor(addr); !ok {
+			delete(this.pendings, addr)
+			continue
+		}
+		for hash, trx := range trxs {
+			if trx.Status == PendingStatusPending {
+				hashes[hash] = struct{}{}
+			} else if now.Sub(trx.ResolvedAt) > pendingRetention {
+				delete(trxs, hash)
+			}
+		}
+		if len(trxs) == 0 {
+			delete(this.pendings, addr)
+		}
+	}
+	this.pendingLock.Unlock()
+
+	// query without lock, so the new ones are not blocked
+	for hash := range hashes {
+		trx, err := this.getTransactionByHash(ctx, hash)
+		if err != nil && !errors.Is(err, ethereum.ErrTransactionNotFound) { // checked again in next round
+			this.logger.Errorf("get pending transaction fail | hash: %s, retryable: %t, error: %s", hash, ethereum.IsRetryable(err), err.Error())
+			continue
+		}
+		blockNum := 0
+		if trx != nil && trx.BlockNumber != "" {
+			if blockNum, err = convertHexToDecimal(trx.BlockNumber); err != nil {
+				this.logger.Errorf("convert block number fail | hash: %s, block number: %s, error: %s", hash, trx.BlockNumber, err.Error())
+				continue
+			}
+		}
+		this.updatePendingTransaction(hash, trx != nil, blockNum, now)
+	}
+}
+
+// updatePendingTransaction update the status of a transaction for all the addresses
+func (this *serviceParser) updatePendingTransaction(hash string, found bool, blockNum int, now time.Time) {
+	this.pendingLock.Lock()
+	defer this.pendingLock.Unlock()
+
+	for addr, trxs := range this.pendings {
+		trx, ok := trxs[hash]
+		if !ok || trx.Status != PendingStatusPending {
+			continue
+		}
+		switch {
+		case blockNum > 0:
+			trx.Status, trx.BlockNumber, trx.LastSeen, trx.ResolvedAt = PendingStatusConfirmed, blockNum, now, now
+			this.logger.Infof("pending transaction confirmed | address: %s, hash: %s, block: %d", addr, hash, blockNum)
+		case found:
+			trx.LastSeen = now
+		case now.Sub(trx.LastSeen) > this.pendingDropTimeout:
+			trx.Status, trx.ResolvedAt = PendingStatusDropped, now
+			this.logger.Infof("pending transaction dropped | address: %s, hash: %s, last seen: %s", addr, hash, trx.LastSeen)
+		}
+	}
+}
+
+func (this *serviceParser) getTransactionByHash(ctx context.Context, hash string) (*ethereum.BlockTransaction, error) {
+
+	ctx, cancelFunc := context.WithDeadline(ctx, time.Now().Add(this.getTransactionsQueryTimeout))
+	defer cancelFunc()
+
+	return this.chainAccesser.EthGetTransactionByHash(ctx, &ethereum.EthGetTransactionByHashRequest{
+		TransactionHash: hash,
+		RequestId:       generateRequestId(),
+	})
+}
+
+func (this *serviceParser) removePendingTransactions(addr string) {
+	this.pendingLock.Lock()
+	defer this.pendingLock.Unlock()
+	delete(this.pendings, addr)
+}
@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum/mocks"
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_serviceParser_resolvePendingTransactions(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	seen := now.Add(-time.Minute)
+
+	tests := []struct {
+		name string
+		old  PendingTransaction
+		// the result of `eth_getTransactionByHash`. It's not called if both are nil.
+		trx *ethereum.BlockTransaction
+		err error
+		// nil if the transaction is removed
+		want *PendingTransaction
+	}{
+		{
+			name: "normal case 1 - confirmed",
+			old:  PendingTransaction{Hash: "0x01", Status: PendingStatusPending, FirstSeen: seen, LastSeen: seen},
+			trx:  &ethereum.BlockTransaction{Hash: "0x01", BlockNumber: "0xc8"},
+			want: &PendingTransaction{Hash: "0x01", Status: PendingStatusConfirmed, BlockNumber: 200, FirstSeen: seen, LastSeen: now, ResolvedAt: now},
+		},
+		{
+			name: "normal case 2 - still pending",
+			old:  PendingTransaction{Hash: "0x01", Status: PendingStatusPending, FirstSeen: seen, LastSeen: seen},
+			trx:  &ethereum.BlockTransaction{Hash: "0x01"},
+			want: &PendingTransaction{Hash: "0x01", Status: PendingStatusPending, FirstSeen: seen, LastSeen: now},
+		},
+		{
+			name: "normal case 3 - not found, kept",
+			old:  PendingTransaction{Hash: "0x01", Status: PendingStatusPending, FirstSeen: seen, LastSeen: seen},
+			err:  ethereum.ErrTransactionNotFound,
+			want: &PendingTransaction{Hash: "0x01", Status: PendingStatusPending, FirstSeen: seen, LastSeen: seen},
+		},
+		{
+			name: "normal case 4 - dropped",
+			old:  PendingTransaction{Hash: "0x01", Status: PendingStatusPending, FirstSeen: seen.Add(-time.Hour), LastSeen: seen.Add(-time.Hour)},
+			err:  ethereum.ErrTransactionNotFound,
+			want: &PendingTransaction{Hash: "0x01", Status: PendingStatusDropped, FirstSeen: seen.Add(-time.Hour), LastSeen: seen.Add(-time.Hour), ResolvedAt: now},
+		},
+		{
+			name: "normal case 5 - resolved kept",
+			old:  PendingTransaction{Hash: "0x01", Status: PendingStatusConfirmed, BlockNumber: 200, ResolvedAt: seen},
+			want: &PendingTransaction{Hash: "0x01", Status: PendingStatusConfirmed, BlockNumber: 200, ResolvedAt: seen},
+		},
+		{
+			name: "normal case 6 - resolved removed",
+			old:  PendingTransaction{Hash: "0x01", Status: PendingStatusDropped, ResolvedAt: now.Add(-pendingRetention - time.Second)},
+			want: nil,
+		},
+		{
+			name: "failure case 1 - unchanged",
+			old:  PendingTransaction{Hash: "0x01", Status: PendingStatusPending, FirstSeen: seen.Add(-time.Hour), LastSeen: seen.Add(-time.Hour)},
+			err:  errors.New("connection reset by peer"),
+			want: &PendingTransaction{Hash: "0x01", Status: PendingStatusPending, FirstSeen: seen.Add(-time.Hour), LastSeen: seen.Add(-time.Hour)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+			if tt.trx != nil || tt.err != nil {
+				// queried once for both of the addresses
+				chainAccesser.EXPECT().EthGetTransactionByHash(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, req *ethereum.EthGetTransactionByHashRequest) (*ethereum.BlockTransaction, error) {
+						assert.Equal(t, tt.old.Hash, req.TransactionHash)
+						return tt.trx, tt.err
+					})
+			}
+
+			parser := &serviceParser{
+				logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+				chainAccesser:               chainAccesser,
+				store:                       NewMemoryTransactionStore(10, 10),
+				pendingDropTimeout:          defaultPendingDropTimeout,
+				getTransactionsQueryTimeout: time.Second,
+				pendings:                    make(map[string]map[string]*PendingTransaction),
+			}
+			for _, addr := range []string{testAddress1, testAddress2} {
+				parser.store.PutAddress(addr, 100)
+				old := tt.old
+				parser.pendings[addr] = map[string]*PendingTransaction{old.Hash: &old}
+			}
+			// `testAddress3` is unsubscribed
+			parser.pendings[testAddress3] = map[string]*PendingTransaction{"0x02": {Hash: "0x02", Status: PendingStatusPending}}
+
+			parser.resolvePendingTransactions(context.Background(), now)
+
+			assert.NotContains(t, parser.pendings, testAddress3)
+			for _, addr := range []string{testAddress1, testAddress2} {
+				got := parser.GetPendingTransactions(ethereum.MustParseAddress(addr))
+				if tt.want == nil {
+					assert.Empty(t, got)
+					assert.NotContains(t, parser.pendings, addr)
+					continue
+				}
+				assert.Equal(t, []PendingTransaction{*tt.want}, got)
+			}
+		})
+	}
+}
+
+func Test_serviceParser_trackPendingTransaction(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	parser := &serviceParser{
+		logger:   logging.NewDefaultLogger(logging.LevelDebug),
+		store:    NewMemoryTransactionStore(10, 10),
+		pendings: make(map[string]map[string]*PendingTransaction),
+	}
+	parser.store.PutAddress(testAddress1, 100)
+	parser.store.PutAddress(testAddress2, 100)
+
+	parser.trackPendingTransaction(&ethereum.BlockTransaction{Hash: "0x02", From: testAddress1, To: testAddress2}, now.Add(time.Second))
+	parser.trackPendingTransaction(&ethereum.BlockTransaction{Hash: "0x01", From: testAddress3, To: testAddress1}, now)
+	// seen again
+	parser.trackPendingTransaction(&ethereum.BlockTransaction{Hash: "0x01", From: testAddress3, To: testAddress1}, now.Add(time.Minute))
+	// not subscribed, and contract creation
+	parser.trackPendingTransaction(&ethereum.BlockTransaction{Hash: "0x03", From: testAddress3}, now)
+
+	got := parser.GetPendingTransactions(ethereum.MustParseAddress(testAddress1))
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, "0x01", got[0].Hash)
+		assert.Equal(t, now, got[0].FirstSeen)
+		assert.Equal(t, PendingStatusPending, got[0].Status)
+		assert.Equal(t, "0x02", got[1].Hash)
+	}
+	got = parser.GetPendingTransactions(ethereum.MustParseAddress(testAddress2))
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, "0x02", got[0].Hash)
+	}
+	assert.Empty(t, parser.GetPendingTransactions(ethereum.MustParseAddress(testAddress3)))
+
+	// removed with the address
+	assert.True(t, parser.Unsubscribe(ethereum.MustParseAddress(testAddress1)))
+	assert.Empty(t, parser.GetPendingTransactions(ethereum.MustParseAddress(testAddress1)))
+	assert.NotContains(t, parser.pendings, testAddress1)
+}
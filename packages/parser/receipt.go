@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+)
+
+const (
+	// the default number of receipts cached
+	defaultReceiptCacheSize = 10000
+	// the max number of receipts queried in one batch call
+	receiptBatchSize = 100
+)
+
+// errReceiptBlockMismatch is returned if the receipt is of another block than the transaction, e.g. during chain reorganization
+var errReceiptBlockMismatch = errors.New("receipt block mismatch")
+
+// cachedReceipt is the receipt summary of a transaction, with the block it's mined in
+type cachedReceipt struct {
+	blockHash string
+	summary   *ethereum.ReceiptSummary
+}
+
+// attachReceipts attach the receipt summaries to the transactions in place. The receipts are got in batch, and cached by hash.
+// The error is returned if the transactions should be fetched again in next round, so they're not stored without receipts.
+// If the receipts can never be got (e.g. the method is not supported), the transactions are kept without them.
+func (this *serviceParser) attachReceipts(ctx context.Context, transactions []ethereum.Transaction) error {
+
+	if !this.receiptEnrichment {
+		return nil
+	}
+
+	// the internal calls don't carry the receipt of their transaction
+	var missing []string
+	seen := make(map[string]struct{}, len(transactions))
+	for _, trx := range transactions {
+		if !trx.IsTopLevel() {
+			continue
+		}
+		if trx.Receipt != nil { // enriched by the strategy, e.g. block scan
+			continue
+		}
+		if _, ok := this.getCachedReceipt(trx.TransactionHash, trx.BlockHash); ok {
+			continue
+		}
+		if _, ok := seen[trx.TransactionHash]; ok {
+			continue
+		}
+		seen[trx.TransactionHash] = struct{}{}
+		missing = append(missing, trx.TransactionHash)
+	}
+
+	permanent := false
+	for start := 0; start < len(missing); start += receiptBatchSize {
+		err := this.fetchReceipts(ctx, missing[start:minInt(start+receiptBatchSize, len(missing))])
+		if err == nil {
+			continue
+		}
+		if !isPermanentFailure(ctx, err) {
+			return err
+		}
+		// retrying would fail forever, keep the transactions without receipts
+		this.logger.Errorf("permanent failure, skip receipt enrichment | transactions: %d, error: %s", len(missing), err.Error())
+		permanent = true
+		break
+	}
+
+	for i := range transactions {
+		trx := &transactions[i]
+		if !trx.IsTopLevel() || trx.Receipt != nil {
+			continue
+		}
+		summary, ok := this.getCachedReceipt(trx.TransactionHash, trx.BlockHash)
+		if ok {
+			trx.Receipt = summary
+			continue
+		}
+		if !permanent {
+			return fmt.Errorf("%w | transaction: %s, block: %s", errReceiptBlockMismatch, trx.TransactionHash, trx.BlockHash)
+		}
+	}
+	return nil
+}
+
+// fetchReceipts get the receipts of the transactions in one batch call, and cache them.
+// The error of the whole call, or the error of the single receipts is returned. The retryable one is preferred.
+func (this *serviceParser) fetchReceipts(ctx context.Context, hashes []string) error {
+
+	batchId := generateRequestId()
+	reqs := make([]*ethereum.EthGetTransactionReceiptRequest, 0, len(hashes))
+	for i, hash := range hashes {
+		reqs = append(reqs, &ethereum.EthGetTransactionReceiptRequest{
+			TransactionHash: hash,
+			// the IDs should be unique in a batch
+			RequestId: fmt.Sprintf("%s-%d", batchId, i),
+		})
+	}
+
+	ctx, cancelFunc := context.WithDeadline(ctx, time.Now().Add(this.getTransactionsQueryTimeout))
+	defer cancelFunc()
+	results, err := this.chainAccesser.EthGetTransactionReceiptBatch(ctx, reqs)
+	if err != nil {
+		this.logger.Errorf("call ethereum chain to get receipts in batch fail | batch: %s, size: %d, retryable: %t, error: %s", batchId, len(reqs), ethereum.IsRetryable(err), err.Error())
+		return err
+	}
+
+	var lastErr error
+	for i, req := range reqs {
+		if i >= len(results) {
+			this.logger.Errorf("result missing in batch | req: %v", req)
+			lastErr = errors.New("result missing in batch")
+			continue
+		}
+		if results[i].Err != nil {
+			this.logger.Errorf("get receipt fail | transaction: %s, error: %s", req.TransactionHash, results[i].Err.Error())
+			if lastErr == nil || ethereum.IsRetryable(results[i].Err) {
+				lastErr = results[i].Err
+			}
+			continue
+		}
+		receipt := results[i].Receipt
+		this.receiptCache.put(req.TransactionHash, &cachedReceipt{
+			blockHash: receipt.BlockHash,
+			summary:   ethereum.NewReceiptSummary(receipt),
+		})
+	}
+	this.logger.Debugf("get receipts success | batch: %s, size: %d, cached: %d", batchId, len(reqs), this.receiptCache.len())
+	return lastErr
+}
+
+// getCachedReceipt get the receipt summary of a transaction, if it's cached for the same block
+func (this *serviceParser) getCachedReceipt(hash, blockHash string) (*ethereum.ReceiptSummary, bool) {
+	value, ok := this.receiptCache.get(hash)
+	if !ok {
+		return nil, false
+	}
+	cached := value.(*cachedReceipt)
+	if blockHash != "" && cached.blockHash != "" && cached.blockHash != blockHash {
+		return nil, false
+	}
+	return cached.summary, true
+}
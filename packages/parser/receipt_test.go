@@ -0,0 +1,266 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum/mocks"
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_serviceParser_attachReceipts(t *testing.T) {
+	gasPrice := ethereum.NewBigInt(big.NewInt(2e9))
+	receipt1 := &ethereum.Receipt{TransactionHash: "0x01", BlockHash: "0xb1", GasUsed: 21000, EffectiveGasPrice: gasPrice, Status: "0x1"}
+	receipt2 := &ethereum.Receipt{TransactionHash: "0x02", BlockHash: "0xb1", GasUsed: 50000, EffectiveGasPrice: gasPrice, Status: "0x0", Logs: []ethereum.Log{{}, {}}}
+	summary1 := ethereum.NewReceiptSummary(receipt1)
+	summary2 := ethereum.NewReceiptSummary(receipt2)
+
+	tests := []struct {
+		name         string
+		transactions []ethereum.Transaction
+		cached       map[string]*cachedReceipt
+		// the hashes queried in batch. It's not called if it's nil.
+		wantQueried []string
+		results     []ethereum.EthGetTransactionReceiptResult
+		err         error
+		want        []*ethereum.ReceiptSummary
+		wantErr     bool
+		// the error matched, if it's set
+		wantErrIs error
+	}{
+		{
+			name: "normal case 1 - only the top level call carries the receipt",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockHash: "0xb1", TraceAddress: []string{}},
+				{TransactionHash: "0x01", BlockHash: "0xb1", TraceAddress: []string{"0"}},
+				{TransactionHash: "0x01", BlockHash: "0xb1", TraceAddress: []string{"0", "1"}},
+				{TransactionHash: "0x02", BlockHash: "0xb1"},
+			},
+			wantQueried: []string{"0x01", "0x02"},
+			results:     []ethereum.EthGetTransactionReceiptResult{{Receipt: receipt1}, {Receipt: receipt2}},
+			want:        []*ethereum.ReceiptSummary{summary1, nil, nil, summary2},
+		},
+		{
+			name: "normal case 2 - cached",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockHash: "0xb1"},
+				{TransactionHash: "0x02", BlockHash: "0xb1"},
+			},
+			cached:      map[string]*cachedReceipt{"0x01": {blockHash: "0xb1", summary: summary1}},
+			wantQueried: []string{"0x02"},
+			results:     []ethereum.EthGetTransactionReceiptResult{{Receipt: receipt2}},
+			want:        []*ethereum.ReceiptSummary{summary1, summary2},
+		},
+		{
+			name: "normal case 3 - enriched by strategy",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockHash: "0xb1", Receipt: summary2},
+			},
+			want: []*ethereum.ReceiptSummary{summary2},
+		},
+		{
+			name: "normal case 4 - permanent failure, kept without receipts",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockHash: "0xb1"},
+			},
+			wantQueried: []string{"0x01"},
+			err:         &ethereum.RPCError{Code: -32601, Message: "Method not found"},
+			want:        []*ethereum.ReceiptSummary{nil},
+		},
+		{
+			name: "normal case 5 - internal calls only, not queried",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockHash: "0xb1", TraceAddress: []string{"0"}},
+				{TransactionHash: "0x02", BlockHash: "0xb1", TraceAddress: []string{"1"}},
+			},
+			want: []*ethereum.ReceiptSummary{nil, nil},
+		},
+		{
+			name: "failure case 1 - receipt not found",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockHash: "0xb1"},
+				{TransactionHash: "0x02", BlockHash: "0xb1"},
+			},
+			wantQueried: []string{"0x01", "0x02"},
+			results:     []ethereum.EthGetTransactionReceiptResult{{Receipt: receipt1}, {Err: ethereum.ErrReceiptNotFound}},
+			wantErr:     true,
+		},
+		{
+			name: "failure case 2 - batch fail",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockHash: "0xb1"},
+			},
+			wantQueried: []string{"0x01"},
+			err:         &ethereum.StatusError{StatusCode: 503},
+			wantErr:     true,
+		},
+		{
+			name: "failure case 3 - receipt of another block",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockHash: "0xb2"},
+			},
+			cached:      map[string]*cachedReceipt{"0x01": {blockHash: "0xb1", summary: summary1}},
+			wantQueried: []string{"0x01"},
+			results:     []ethereum.EthGetTransactionReceiptResult{{Receipt: receipt1}},
+			wantErr:     true,
+			wantErrIs:   errReceiptBlockMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+			if tt.wantQueried != nil {
+				chainAccesser.EXPECT().EthGetTransactionReceiptBatch(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, reqs []*ethereum.EthGetTransactionReceiptRequest) ([]ethereum.EthGetTransactionReceiptResult, error) {
+						var queried []string
+						for _, req := range reqs {
+							queried = append(queried, req.TransactionHash)
+						}
+						assert.Equal(t, tt.wantQueried, queried)
+						return tt.results, tt.err
+					})
+			}
+
+			parser := &serviceParser{
+				logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+				chainAccesser:               chainAccesser,
+				receiptEnrichment:           true,
+				receiptCache:                newLRUCache(10),
+				getTransactionsQueryTimeout: time.Second,
+			}
+			for hash, cached := range tt.cached {
+				parser.receiptCache.put(hash, cached)
+			}
+
+			err := parser.attachReceipts(context.Background(), tt.transactions)
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.wantErrIs != nil {
+					assert.True(t, errors.Is(err, tt.wantErrIs), err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			var got []*ethereum.ReceiptSummary
+			for _, trx := range tt.transactions {
+				got = append(got, trx.Receipt)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_serviceParser_attachReceipts_disabled(t *testing.T) {
+	parser := &serviceParser{
+		logger: logging.NewDefaultLogger(logging.LevelDebug),
+	}
+	transactions := []ethereum.Transaction{{TransactionHash: "0x01"}}
+	assert.NoError(t, parser.attachReceipts(context.Background(), transactions))
+	assert.Nil(t, transactions[0].Receipt)
+}
+
+func Test_serviceParser_doUpdateTransactions_receipts(t *testing.T) {
+	receipt := &ethereum.Receipt{TransactionHash: "0x01", GasUsed: 21000, EffectiveGasPrice: ethereum.NewBigInt(big.NewInt(1e9)), Status: "0x1"}
+
+	tests := []struct {
+		name       string
+		receiptErr error
+		wantCursor int
+		// the fee of the stored transaction, empty if it's not stored
+		wantFee string
+	}{
+		{
+			name:       "normal case 1 - stored with receipt",
+			wantCursor: 201,
+			wantFee:    "0.000021",
+		},
+		{
+			name:       "failure case 1 - cursor kept",
+			receiptErr: errors.New("connection reset by peer"),
+			wantCursor: 100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+			chainAccesser.EXPECT().EthGetCurrentTransactionsByAddress(gomock.Any(), gomock.Any()).Return(
+				[]ethereum.Transaction{{TransactionHash: "0x01", BlockNumber: 150}}, nil)
+			chainAccesser.EXPECT().EthGetTransactionReceiptBatch(gomock.Any(), gomock.Any()).Return(
+				[]ethereum.EthGetTransactionReceiptResult{{Receipt: receipt}}, tt.receiptErr)
+
+			parser := &serviceParser{
+				logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+				chainAccesser:               chainAccesser,
+				store:                       NewMemoryTransactionStore(10, 10),
+				receiptEnrichment:           true,
+				receiptCache:                newLRUCache(10),
+				getTransactionsQueryTimeout: time.Second,
+			}
+			parser.store.PutAddress(testAddress1, 100)
+
+			parser.doUpdateTransactions(context.Background(), parser.constructGetTransactionRequest(testAddress1, 200))
+
+			cursor, _ := parser.store.GetCursor(testAddress1)
+			assert.Equal(t, tt.wantCursor, cursor)
+			transactions := parser.GetTransactions(ethereum.MustParseAddress(testAddress1))
+			if tt.wantFee == "" {
+				assert.Empty(t, transactions)
+				return
+			}
+			if assert.Len(t, transactions, 1) {
+				assert.Equal(t, tt.wantFee, transactions[0].Receipt.Fee.Ether())
+				assert.Equal(t, ethereum.TransactionStatusSuccess, transactions[0].Receipt.Status)
+			}
+		})
+	}
+}
+
+func Test_serviceParser_doUpdateTransactions_internalCalls(t *testing.T) {
+	receipt := &ethereum.Receipt{TransactionHash: "0x01", GasUsed: 21000, EffectiveGasPrice: ethereum.NewBigInt(big.NewInt(1e9)), Status: "0x1"}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+	// the transaction calls a contract, which makes 2 internal calls
+	chainAccesser.EXPECT().EthGetCurrentTransactionsByAddress(gomock.Any(), gomock.Any()).Return(
+		[]ethereum.Transaction{
+			{TransactionHash: "0x01", BlockNumber: 150, TraceAddress: []string{}, Subtraces: 2},
+			{TransactionHash: "0x01", BlockNumber: 150, TraceAddress: []string{"0"}},
+			{TransactionHash: "0x01", BlockNumber: 150, TraceAddress: []string{"1"}},
+		}, nil)
+	chainAccesser.EXPECT().EthGetTransactionReceiptBatch(gomock.Any(), gomock.Len(1)).Return(
+		[]ethereum.EthGetTransactionReceiptResult{{Receipt: receipt}}, nil)
+
+	parser := &serviceParser{
+		logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+		chainAccesser:               chainAccesser,
+		store:                       NewMemoryTransactionStore(10, 10),
+		receiptEnrichment:           true,
+		receiptCache:                newLRUCache(10),
+		getTransactionsQueryTimeout: time.Second,
+	}
+	parser.store.PutAddress(testAddress1, 100)
+
+	parser.doUpdateTransactions(context.Background(), parser.constructGetTransactionRequest(testAddress1, 200))
+
+	transactions := parser.GetTransactions(ethereum.MustParseAddress(testAddress1))
+	assert.Len(t, transactions, 3)
+	// the fee is counted once, when the transactions are summed up
+	total := new(big.Int)
+	for _, trx := range transactions {
+		if trx.Receipt != nil {
+			total.Add(total, trx.Receipt.Fee.Int())
+		}
+	}
+	assert.Equal(t, "0.000021", ethereum.NewBigInt(total).Ether())
+}
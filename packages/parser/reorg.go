@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"context"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+)
+
+// blockHashWindow records the hashes of the recent processed blocks.
+// It's used to detect the chain reorganization, by comparing the parent hash of the new block with the recorded one.
+type blockHashWindow struct {
+	size    int
+	highest int
+	hashes  map[int]string
+}
+
+func newBlockHashWindow(size int) *blockHashWindow {
+	return &blockHashWindow{
+		size:    size,
+		highest: -1,
+		hashes:  make(map[int]string, size),
+	}
+}
+
+// put record the hash of a block. The blocks out of the window are removed.
+func (this *blockHashWindow) put(blockNum int, hash string) {
+	this.hashes[blockNum] = hash
+	if blockNum <= this.highest {
+		return
+	}
+	this.highest = blockNum
+	for num := range this.hashes {
+		if num <= this.highest-this.size {
+			delete(this.hashes, num)
+		}
+	}
+}
+
+func (this *blockHashWindow) get(blockNum int) (string, bool) {
+	hash, ok := this.hashes[blockNum]
+	return hash, ok
+}
+
+// truncate remove the hashes after `blockNum`, e.g. the orphaned blocks of a shorter canonical chain.
+func (this *blockHashWindow) truncate(blockNum int) {
+	if blockNum >= this.highest {
+		return
+	}
+	for num := range this.hashes {
+		if num > blockNum {
+			delete(this.hashes, num)
+		}
+	}
+	this.highest = blockNum
+}
+
+// lowest return the lowest block number in the window
+func (this *blockHashWindow) lowest() int {
+	return maxInt(this.highest-this.size+1, 0)
+}
+
+// detectReorg fetch the new blocks till `head`, and verify them with the recorded hashes.
+// If `head` is not after the processed block, the recorded hash of `head` is verified instead,
+// so the reorganization at the same height, or to a shorter chain, is detected.
+// If `head` is too far to fetch the blocks after the processed block in the window, the processed block is verified first.
+// If there is chain reorganization, the fork block (the latest block still on the canonical chain) is returned,
+// otherwise -1 is returned.
+func (this *serviceParser) detectReorg(ctx context.Context, head int) (int, error) {
+
+	processedBlock := this.getProcessedBlock()
+	if head <= processedBlock {
+		return this.verifyBlock(ctx, head)
+	}
+
+	forkBlock := -1
+	from := maxInt(processedBlock+1, head-this.blockHashes.size+1)
+	if from > processedBlock+1 { // the head jumps beyond the window, the parent of the processed block is never checked by the new blocks
+		fork, err := this.verifyBlock(ctx, processedBlock)
+		if err != nil {
+			return -1, err
+		}
+		forkBlock = fork
+	}
+	for num := from; num <= head; num++ {
+		block, err := this.getBlock(ctx, num)
+		if err != nil {
+			return -1, err
+		}
+
+		parentHash, ok := this.blockHashes.get(num - 1)
+		if ok && parentHash != block.ParentHash {
+			this.logger.Warnf("chain reorganization detected | block: %d, recorded parent hash: %s, parent hash: %s", num, parentHash, block.ParentHash)
+			fork, err := this.findForkBlock(ctx, num-1)
+			if err != nil {
+				return -1, err
+			}
+			if forkBlock < 0 || fork < forkBlock {
+				forkBlock = fork
+			}
+		}
+		this.blockHashes.put(num, block.Hash)
+		this.recordBlockTimestamp(num, block)
+	}
+	return forkBlock, nil
+}
+
+// verifyBlock compare the recorded hash of a processed block with the canonical chain.
+// The fork block is returned if they're different, otherwise -1 is returned. The block not recorded can't be verified.
+func (this *serviceParser) verifyBlock(ctx context.Context, blockNum int) (int, error) {
+
+	hash, ok := this.blockHashes.get(blockNum)
+	if !ok {
+		return -1, nil
+	}
+	block, err := this.getBlock(ctx, blockNum)
+	if err != nil {
+		return -1, err
+	}
+	if block.Hash == hash {
+		return -1, nil
+	}
+	this.logger.Warnf("chain reorganization detected | block: %d, recorded hash: %s, hash: %s", blockNum, hash, block.Hash)
+	return this.findForkBlock(ctx, blockNum)
+}
+
+// findForkBlock walk back from `blockNum`, until the recorded hash matches the canonical chain.
+// The orphaned hashes are replaced with the canonical ones during the walk.
+// If the reorganization is deeper than the recorded hashes, the block before them is treated as the fork block.
+func (this *serviceParser) findForkBlock(ctx context.Context, blockNum int) (int, error) {
+
+	num := blockNum
+	for ; num >= this.blockHashes.lowest(); num-- {
+		hash, ok := this.blockHashes.get(num)
+		if !ok {
+			break
+		}
+		block, err := this.getBlock(ctx, num)
+		if err != nil {
+			return -1, err
+		}
+		if block.Hash == hash {
+			return num, nil
+		}
+		this.blockHashes.put(num, block.Hash)
+		this.recordBlockTimestamp(num, block)
+	}
+
+	this.logger.Errorf("chain reorganization deeper than the window | from block: %d, window size: %d", blockNum, this.blockHashes.size)
+	return num, nil
+}
+
+// rollback remove the transactions of the orphaned blocks (after `forkBlock`),
+// and move back the sync cursors, so the canonical range would be re-fetched in the coming round.
+func (this *serviceParser) rollback(forkBlock int) {
+
+	for _, addr := range this.store.Addresses() {
+		if err := this.store.Rollback(addr, forkBlock); err != nil {
+			this.logger.Errorf("rollback address fail | address: %s, fork block: %d, error: %s", addr, forkBlock, err.Error())
+			continue
+		}
+		this.logger.Infof("rollback address | address: %s, fork block: %d", addr, forkBlock)
+	}
+}
+
+func (this *serviceParser) getBlock(ctx context.Context, blockNum int) (*ethereum.Block, error) {
+	ctx, cancelFunc := context.WithDeadline(ctx, time.Now().Add(this.getBlockNumTimeOut))
+	defer cancelFunc()
+	req := &ethereum.EthGetBlockByNumberRequest{
+		BlockNumber: convertDecimalToHex(blockNum),
+		RequestId:   generateRequestId(),
+	}
+	return this.chainAccesser.EthGetBlockByNumber(ctx, req)
+}
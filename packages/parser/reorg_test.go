@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum/mocks"
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_blockHashWindow_put(t *testing.T) {
+	this := newBlockHashWindow(3)
+	for i := 1; i <= 5; i++ {
+		this.put(i, fmt.Sprintf("0x%d", i))
+	}
+
+	assert.Equal(t, 3, this.lowest())
+	assert.Equal(t, 3, len(this.hashes))
+	_, ok := this.get(2)
+	assert.Equal(t, false, ok)
+	hash, ok := this.get(5)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "0x5", hash)
+
+	// replace an existing one would not move the window
+	this.put(4, "0x44")
+	hash, _ = this.get(4)
+	assert.Equal(t, "0x44", hash)
+	assert.Equal(t, 3, this.lowest())
+
+	// the hashes after the block are removed
+	this.truncate(4)
+	_, ok = this.get(5)
+	assert.Equal(t, false, ok)
+	this.put(5, "0x55")
+	assert.Equal(t, 3, this.lowest())
+}
+
+func Test_serviceParser_detectReorg(t *testing.T) {
+
+	block := func(num int, fork string) *ethereum.Block {
+		return &ethereum.Block{
+			Number:     convertDecimalToHex(num),
+			Hash:       fmt.Sprintf("0x%s%d", fork, num),
+			ParentHash: fmt.Sprintf("0x%s%d", fork, num-1),
+		}
+	}
+
+	tests := []struct {
+		name           string
+		processedBlock int
+		head           int
+		// the size of hash window, 10 if it's not set
+		windowSize int
+		recorded   map[int]string
+		chain      map[int]*ethereum.Block
+		want       int
+	}{
+		{
+			name:           "normal case 1 - no reorganization",
+			processedBlock: 102,
+			head:           104,
+			recorded:       map[int]string{101: "0xa101", 102: "0xa102"},
+			chain:          map[int]*ethereum.Block{103: block(103, "a"), 104: block(104, "a")},
+			want:           -1,
+		},
+		{
+			name:           "normal case 2 - reorganization from block 102",
+			processedBlock: 102,
+			head:           104,
+			recorded:       map[int]string{100: "0xa100", 101: "0xa101", 102: "0xa102"},
+			chain: map[int]*ethereum.Block{
+				101: block(101, "a"),
+				102: {Hash: "0xb102", ParentHash: "0xa101"},
+				103: {Hash: "0xb103", ParentHash: "0xb102"},
+				104: {Hash: "0xb104", ParentHash: "0xb103"},
+			},
+			want: 101,
+		},
+		{
+			name:           "normal case 3 - reorganization at the same height",
+			processedBlock: 102,
+			head:           102,
+			recorded:       map[int]string{100: "0xa100", 101: "0xa101", 102: "0xa102"},
+			chain: map[int]*ethereum.Block{
+				101: block(101, "a"),
+				102: {Hash: "0xb102", ParentHash: "0xa101"},
+			},
+			want: 101,
+		},
+		{
+			name:           "normal case 4 - reorganization to a shorter chain",
+			processedBlock: 103,
+			head:           102,
+			recorded:       map[int]string{100: "0xa100", 101: "0xa101", 102: "0xa102", 103: "0xa103"},
+			chain: map[int]*ethereum.Block{
+				101: block(101, "a"),
+				102: {Hash: "0xb102", ParentHash: "0xa101"},
+			},
+			want: 101,
+		},
+		{
+			name:           "normal case 5 - head behind without reorganization",
+			processedBlock: 103,
+			head:           102,
+			recorded:       map[int]string{101: "0xa101", 102: "0xa102", 103: "0xa103"},
+			chain:          map[int]*ethereum.Block{102: block(102, "a")},
+			want:           -1,
+		},
+		{
+			name:           "normal case 6 - head jumps beyond the window, reorganization from block 102",
+			processedBlock: 102,
+			head:           110,
+			windowSize:     3,
+			recorded:       map[int]string{100: "0xa100", 101: "0xa101", 102: "0xa102"},
+			chain: map[int]*ethereum.Block{
+				101: block(101, "a"),
+				102: {Hash: "0xb102", ParentHash: "0xa101"},
+				108: block(108, "b"),
+				109: block(109, "b"),
+				110: block(110, "b"),
+			},
+			want: 101,
+		},
+		{
+			name:           "normal case 7 - head jumps beyond the window without reorganization",
+			processedBlock: 102,
+			head:           110,
+			windowSize:     3,
+			recorded:       map[int]string{100: "0xa100", 101: "0xa101", 102: "0xa102"},
+			chain: map[int]*ethereum.Block{
+				102: block(102, "a"),
+				108: block(108, "a"),
+				109: block(109, "a"),
+				110: block(110, "a"),
+			},
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+			chainAccesser.EXPECT().EthGetBlockByNumber(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, req *ethereum.EthGetBlockByNumberRequest) (*ethereum.Block, error) {
+					num, _ := convertHexToDecimal(req.BlockNumber)
+					return tt.chain[num], nil
+				}).AnyTimes()
+
+			parser := &serviceParser{
+				processedBlock:       tt.processedBlock,
+				maxTransactionNumber: 10,
+				chainAccesser:        chainAccesser,
+				logger:               logging.NewDefaultLogger(logging.LevelDebug),
+				store:                newMemoryTransactionStore(10, 10),
+				blockHashes:          newBlockHashWindow(10),
+				getBlockNumTimeOut:   time.Second,
+			}
+			if tt.windowSize > 0 {
+				parser.blockHashes = newBlockHashWindow(tt.windowSize)
+			}
+			for num, hash := range tt.recorded {
+				parser.blockHashes.put(num, hash)
+			}
+			lruOf(parser).putAddress(addressTransaction{
+				address:  "0xffff",
+				blockNum: tt.processedBlock + 1,
+				transactions: []ethereum.Transaction{
+					{BlockNumber: 102, BlockHash: "0xa102"},
+					{BlockNumber: 101, BlockHash: "0xa101"},
+				},
+			})
+
+			got, err := parser.detectReorg(context.Background(), tt.head)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tt.want, got)
+
+			// the recorded hashes should follow the canonical chain
+			for num := maxInt(minInt(tt.processedBlock+1, tt.head), tt.head-parser.blockHashes.size+1); num <= tt.head; num++ {
+				hash, _ := parser.blockHashes.get(num)
+				assert.Equal(t, tt.chain[num].Hash, hash)
+			}
+
+			if got < 0 {
+				return
+			}
+			parser.rollback(got)
+			data := lruOf(parser).getAddressIn("0xffff")
+			assert.Equal(t, got+1, data.blockNum)
+			assert.Equal(t, []ethereum.Transaction{{BlockNumber: 101, BlockHash: "0xa101"}}, data.transactions)
+		})
+	}
+}
+
+func Test_serviceParser_distributeRound_lowerHead(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+	chainAccesser.EXPECT().EthGetBlockByNumber(gomock.Any(), gomock.Any()).Return(&ethereum.Block{Hash: "0xa102"}, nil)
+
+	parser := &serviceParser{
+		processedBlock:     103,
+		chainAccesser:      chainAccesser,
+		logger:             logging.NewDefaultLogger(logging.LevelDebug),
+		store:              newMemoryTransactionStore(10, 10),
+		blockHashes:        newBlockHashWindow(10),
+		getBlockNumTimeOut: time.Second,
+	}
+	parser.blockHashes.put(102, "0xa102")
+	parser.blockHashes.put(103, "0xa103")
+
+	// the processed block never moves back without reorganization
+	parser.distributeRound(context.Background(), 102)
+	assert.Equal(t, 103, parser.GetCurrentBlock())
+}
@@ -2,23 +2,37 @@ package parser
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/brofu/simple_ethereum_parser/packages/abi"
 	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
 	"github.com/brofu/simple_ethereum_parser/packages/logging"
 )
 
 type addressTransaction struct {
-	address      string
+	address string
+	// blockNum is the sync cursor of the address, the first block NOT fetched yet.
+	// It's advanced only when a block range is fully fetched, so a failed range would be retried.
 	blockNum     int
 	transactions []ethereum.Transaction
+	// tokenCursor is the sync cursor of token transfers, the first block NOT fetched yet.
+	tokenCursor    int
+	tokenTransfers []ethereum.TokenTransfer
 }
 
+// transactionTask is the task to get the transactions of new blocks.
+// The addresses are queried in one batch call if there are more than one,
+// or in one `trace_filter` call if `multiAddress` is set.
 type transactionTask struct {
-	blockNum int
-	address  string
+	blockNum     int
+	addresses    []string
+	multiAddress bool
 }
 
 type ServiceParserConfiguration struct {
@@ -28,6 +42,50 @@ type ServiceParserConfiguration struct {
 	Interval                    time.Duration
 	GetBlockNumberQueryTimeout  time.Duration
 	GetTransactionsQueryTimeout time.Duration
+	// number of recent block hashes tracked to detect chain reorganization. 0 means disabled.
+	ReorgWindow int
+	// number of confirmations for a transaction to be final.
+	// transactions newer than `head - Confirmations` are pending.
+	Confirmations int
+	// storage of addresses and transactions. An in-memory LRU storage is used if it's nil.
+	Store TransactionStore
+	// number of blocks fetched in one task of history backfill. If it's 0, `defaultBackfillChunkSize` is used,
+	// or `defaultBlockScanBackfillChunkSize` with `StrategyBlockScan`. The chunk is halved when it times out.
+	BackfillChunkSize int
+	// number of addresses queried in one batch call in a round. 0 or 1 means one call per address.
+	BatchSize int
+	// number of addresses queried in one `trace_filter` call in a round. 0 or 1 means one address per call.
+	// The addresses with the same sync cursor are grouped together. `BatchSize` is ignored if it's set.
+	AddressChunkSize int
+	// the way to find the transactions on chain. `StrategyTraceFilter` is used if it's empty.
+	Strategy IndexingStrategy
+	// index the ERC-20 and ERC-721 transfers of the addresses via `eth_getLogs`.
+	// The history before subscription is not backfilled.
+	TokenTransfers bool
+	// refresh the balances and nonces of the addresses on each processed block.
+	// They're kept in memory only, and refreshed again after restart.
+	AccountStates bool
+	// attach the status, fee paid and logs count from the receipts to the new transactions.
+	// The receipts are got in batch, and cached by the transaction hash.
+	ReceiptEnrichment bool
+	// number of receipts cached. `defaultReceiptCacheSize` is used if it's 0.
+	ReceiptCacheSize int
+	// attach the timestamps of their blocks to the new transactions.
+	// The timestamps are cached by block number, so each block is got only once.
+	BlockTimestamps bool
+	// number of block timestamps cached. `defaultBlockTimestampCacheSize` is used if it's 0.
+	BlockTimestampCacheSize int
+	// the source of new heads. If it's set, the rounds are driven by the head notifications,
+	// and `Interval` polling is only used when the subscription is dropped.
+	HeadSubscriber ethereum.HeadSubscriber
+	// the source of pending transactions. If it's set, the pending transactions of the addresses are tracked,
+	// until they're mined or dropped from the mempool.
+	PendingTransactionWatcher ethereum.PendingTransactionWatcher
+	// the duration a pending transaction is kept after it's not found on chain, before it's taken as dropped.
+	// `defaultPendingDropTimeout` is used if it's 0.
+	PendingDropTimeout time.Duration
+	// the snapshot written by `Snapshot`, to restore the state from. It's loaded before the parser is started.
+	Snapshot io.Reader
 }
 
 // serviceParser implements the `Parser` interface
@@ -35,11 +93,21 @@ type serviceParser struct {
 	// mark if there is going on transactions task
 	processing bool
 
+	// Lock for the processed and finalized block, which are updated by the task distributor and read by the queries
+	blockLock sync.RWMutex
+	// the task distributor and workers are started, the state can't be restored any more. Guarded by `blockLock`.
+	started bool
 	// processed block, when the instance is new started, this mean the started block number
 	processedBlock int
+	// the final cursor. blocks not after it have enough confirmations
+	finalizedBlock int
+	// number of confirmations for a transaction to be final
+	confirmations int
 
 	// Interval to check if there is new block
 	interval time.Duration
+	// the source of new heads, nil if the new blocks are polled only
+	headSubscriber ethereum.HeadSubscriber
 	// max number of concurrent worker to get transactions
 	maxConcurrentThreads int
 	// max number of transactions of an address would be stored in storage
@@ -49,12 +117,57 @@ type serviceParser struct {
 
 	// Lock for new subscribed address list update
 	newAddrLock sync.Mutex
-	// Lock used for address list update
-	addrLock sync.RWMutex
 
-	addresses *addressTransactionLRU
+	// storage of addresses and transactions
+	store TransactionStore
+
+	// hashes of the recent processed blocks, nil if reorganization detection is disabled
+	blockHashes *blockHashWindow
 
 	transactionTasks chan transactionTask
+	// Used to send the history backfill tasks. They're in lower priority than `transactionTasks`
+	backfillTasks chan backfillTask
+	// Used to notify there is backfill job updated
+	backfillNoti chan struct{}
+
+	// Lock for backfill jobs
+	backfillLock sync.Mutex
+	// backfill jobs of addresses
+	backfillJobs map[string]*backfillJob
+	// number of blocks fetched in one backfill task
+	backfillChunkSize int
+	// number of addresses in one transaction task
+	batchSize int
+	// number of addresses in one `trace_filter` call. It's not used if it's less than 2.
+	addressChunkSize int
+	// index the token transfers of the addresses
+	tokenTransfers bool
+	// refresh the balances and nonces of the addresses
+	accountStates bool
+	// Lock for account states
+	accountLock sync.RWMutex
+	// the latest balances and nonces of the addresses
+	accounts map[string]AccountState
+	// attach the receipts to the new transactions
+	receiptEnrichment bool
+	// the receipt summaries of the recent transactions, keyed by hash
+	receiptCache *lruCache
+	// attach the block timestamps to the new transactions, nil if it's disabled
+	timestamps *blockTimestampResolver
+	// the ABIs to decode the calls to contracts
+	abis *abiRegistry
+	// the source of pending transactions, nil if they're not tracked
+	pendingWatcher ethereum.PendingTransactionWatcher
+	// the duration a pending transaction is not found on chain, before it's taken as dropped
+	pendingDropTimeout time.Duration
+	// Lock for pending transactions
+	pendingLock sync.Mutex
+	// the pending transactions of the addresses, keyed by address and hash
+	pendings map[string]map[string]*PendingTransaction
+	// Lock for sync errors
+	syncLock sync.Mutex
+	// the errors blocking the sync of the addresses, keyed by address
+	syncErrors map[string]*syncError
 	// Used to notify there is new task of `get of transactions`. Sent from `task distributor` to `task executor`
 	newTaskNoti chan int
 	// Used to notify there is ONE task finished. Sent from `task executor workers` to `task executor`
@@ -78,19 +191,59 @@ type serviceParser struct {
 // NewServiceParser construct an instance of `serviceParser`
 func NewServiceParser(ctx context.Context, logger logging.Logger, chainAccesser ethereum.EthereumChainAccesser, config ServiceParserConfiguration) Parser {
 
+	chainAccesser, err := applyStrategy(config.Strategy, chainAccesser, logger)
+	if err != nil { // good practice to fast fail.
+		logger.Errorf("apply indexing strategy fail | error: %s", err.Error())
+		panic(err)
+	}
+
+	store := config.Store
+	if store == nil {
+		store = NewMemoryTransactionStore(config.MaxAddressNumber, config.MaxTransactionNumber)
+	}
+	backfillChunkSize := backfillChunkSizeOf(config)
+	batchSize := config.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	receiptCacheSize := config.ReceiptCacheSize
+	if receiptCacheSize <= 0 {
+		receiptCacheSize = defaultReceiptCacheSize
+	}
+	pendingDropTimeout := config.PendingDropTimeout
+	if pendingDropTimeout <= 0 {
+		pendingDropTimeout = defaultPendingDropTimeout
+	}
+
 	parser := &serviceParser{
 		newAddrLock:                 sync.Mutex{},
-		addrLock:                    sync.RWMutex{},
 		transactionTasks:            make(chan transactionTask),
+		backfillTasks:               make(chan backfillTask),
+		backfillNoti:                make(chan struct{}, 1),
+		backfillJobs:                make(map[string]*backfillJob),
+		backfillChunkSize:           backfillChunkSize,
+		batchSize:                   batchSize,
+		addressChunkSize:            config.AddressChunkSize,
+		tokenTransfers:              config.TokenTransfers,
+		accountStates:               config.AccountStates,
+		accounts:                    make(map[string]AccountState),
+		receiptEnrichment:           config.ReceiptEnrichment,
+		receiptCache:                newLRUCache(receiptCacheSize),
+		abis:                        newABIRegistry(),
+		pendingWatcher:              config.PendingTransactionWatcher,
+		pendingDropTimeout:          pendingDropTimeout,
+		pendings:                    make(map[string]map[string]*PendingTransaction),
 		newTaskNoti:                 make(chan int),
 		finishedTasks:               make(chan struct{}),
 		interval:                    config.Interval,
+		headSubscriber:              config.HeadSubscriber,
 		maxConcurrentThreads:        config.MaxConcurrentThreads,
 		maxTransactionNumber:        config.MaxTransactionNumber,
 		maxAddressNumber:            config.MaxAddressNumber,
+		confirmations:               config.Confirmations,
 		chainAccesser:               chainAccesser,
 		logger:                      logger,
-		addresses:                   newAddressTransactionLRU(config.MaxAddressNumber),
+		store:                       store,
 		getBlockNumTimeOut:          config.GetBlockNumberQueryTimeout,
 		getTransactionsQueryTimeout: config.GetTransactionsQueryTimeout,
 	}
@@ -104,83 +257,245 @@ func NewServiceParser(ctx context.Context, logger logging.Logger, chainAccesser
 		parser.logger.Errorf("get init block number fail | error: %s", err.Error())
 		panic(err)
 	}
-	parser.processedBlock = blockNum
+	parser.setBlocks(blockNum, blockNum-config.Confirmations)
+
+	if config.Snapshot != nil {
+		// not fatal, the addresses can be subscribed again
+		if err := parser.Restore(config.Snapshot); err != nil {
+			parser.logger.Errorf("restore snapshot fail | error: %s", err.Error())
+		}
+		blockNum = parser.getProcessedBlock()
+	}
+
+	if config.BlockTimestamps {
+		parser.timestamps = newBlockTimestampResolver(logger, chainAccesser, config.BlockTimestampCacheSize, config.GetBlockNumberQueryTimeout)
+	}
+
+	if config.ReorgWindow > 0 {
+		parser.blockHashes = newBlockHashWindow(config.ReorgWindow)
+		block, err := parser.getBlock(ctx, blockNum)
+		if err != nil { // not fatal, the hash of following blocks would be recorded.
+			parser.logger.Errorf("get init block fail | block number: %d, error: %s", blockNum, err.Error())
+		} else {
+			parser.blockHashes.put(blockNum, block.Hash)
+			parser.recordBlockTimestamp(blockNum, block)
+		}
+	}
+
+	parser.blockLock.Lock()
+	parser.started = true
+	parser.blockLock.Unlock()
+
 	go parser.start(ctx)
 	return parser
 }
 
 func (this *serviceParser) GetCurrentBlock() int {
-	return this.processedBlock
+	return this.getProcessedBlock()
 }
 
-func (this *serviceParser) Subscribe(address string) bool {
+func (this *serviceParser) Subscribe(addr ethereum.Address) bool {
 	this.newAddrLock.Lock()
 	defer this.newAddrLock.Unlock()
-	this.newAddresses = append(this.newAddresses, address)
+	// the lower case hex is used as the key of storage
+	this.newAddresses = append(this.newAddresses, addr.Hex())
 	return true
 }
 
-func (this *serviceParser) GetTransactions(address string) []ethereum.Transaction {
+// Unsubscribe drop the address from the pending list, and remove its data from the storage.
+func (this *serviceParser) Unsubscribe(addr ethereum.Address) bool {
+	address := addr.Hex()
+
+	found := false
+
+	this.newAddrLock.Lock()
+	kept := this.newAddresses[:0]
+	for _, addr := range this.newAddresses {
+		if addr == address {
+			found = true
+			continue
+		}
+		kept = append(kept, addr)
+	}
+	this.newAddresses = kept
+	this.newAddrLock.Unlock()
+
+	if this.store.Evict(address) {
+		found = true
+	}
+	this.cancelBackfill(address)
+	this.removeAccountState(address)
+	this.removePendingTransactions(address)
+	this.removeSyncStatus(address)
+
+	this.logger.Infof("unsubscribe address | address: %s, found: %t", address, found)
+	return found
+}
+
+func (this *serviceParser) GetTransactions(addr ethereum.Address) []ethereum.Transaction {
+	address := addr.Hex()
+
+	transactions, ok := this.store.GetTransactions(address)
+	if !ok {
+		return []ethereum.Transaction{}
+	}
+	if this.abis != nil {
+		this.abis.decode(transactions)
+	}
+	return transactions
+}
 
-	this.addrLock.RLock()
-	data := this.addresses.getAddress(address)
-	this.addrLock.RUnlock()
+// GetFinalizedTransactions get the transactions with enough confirmations.
+// The blocks not fetched for the address yet (e.g. the range failed) are not final, even if they have enough confirmations,
+// so only the transactions before both the final cursor and the sync cursor of the address are returned.
+func (this *serviceParser) GetFinalizedTransactions(addr ethereum.Address) []ethereum.Transaction {
 
-	if data == nil {
+	cursor, ok := this.store.GetCursor(addr.Hex())
+	if !ok {
 		return []ethereum.Transaction{}
-	} else {
-		return data.transactions
 	}
+	finalizedBlock := minInt(this.getFinalizedBlock(), cursor-1)
+	transactions := this.GetTransactions(addr)
+
+	finalized := make([]ethereum.Transaction, 0, len(transactions))
+	for _, trx := range transactions {
+		if trx.BlockNumber <= finalizedBlock {
+			finalized = append(finalized, trx)
+		}
+	}
+	return finalized
+}
+
+func (this *serviceParser) RegisterABI(contract ethereum.Address, contractABI *abi.ABI) {
+	this.abis.register(contract.Hex(), contractABI)
+	this.logger.Infof("register abi | contract: %s, removed: %t", contract.Hex(), contractABI == nil)
+}
+
+func (this *serviceParser) GetTokenTransfers(addr ethereum.Address) []ethereum.TokenTransfer {
+	address := addr.Hex()
+
+	transfers, ok := this.store.GetTokenTransfers(address)
+	if !ok {
+		return []ethereum.TokenTransfer{}
+	}
+	return transfers
 }
 
 func (this *serviceParser) start(ctx context.Context) {
-	go this.startTaskDistribution(ctx) // start task distribution
-	go this.startTaskExecution(ctx)    // start task execution
+	go this.startTaskDistribution(ctx)     // start task distribution
+	go this.startTaskExecution(ctx)        // start task execution
+	go this.startBackfillDistribution(ctx) // start history backfill distribution
+	if this.pendingWatcher != nil {
+		go this.startPendingTracking(ctx) // start pending transaction tracking
+	}
 }
 
 //startTaskDistribution is the controller of distributing tasks (to get transaction from new block)
+// The rounds are driven by the new heads if there is a head subscriber, and by the timer when the subscription is not alive.
 func (this *serviceParser) startTaskDistribution(ctx context.Context) {
 
 	this.logger.Infof("task distributor started")
 	ticker := time.Tick(this.interval)
 
+	var heads <-chan *ethereum.Block
+	if this.headSubscriber != nil {
+		var err error
+		heads, err = this.headSubscriber.SubscribeNewHeads(ctx)
+		if err != nil { // not fatal, fall back to polling
+			this.logger.Errorf("subscribe new heads fail | error: %s", err.Error())
+		}
+	}
+	// the latest head notified. It may be skipped if there is ongoing tasks, and then handled by the timer.
+	latestHead := 0
+
 	for {
 		select {
 		case <-ctx.Done():
 			this.logger.Infof("task distributor existing")
 			return
+		case head, ok := <-heads:
+			if !ok { // the subscription is ended
+				heads = nil
+				continue
+			}
+			blockNum, err := convertHexToDecimal(head.Number)
+			if err != nil {
+				this.logger.Errorf("convert head number fail | number: %s, error: %s", head.Number, err.Error())
+				continue
+			}
+			latestHead = blockNum
+			this.distributeRound(ctx, blockNum)
 		case <-ticker:
+			if heads != nil && this.headSubscriber.Connected() && latestHead <= this.getProcessedBlock() {
+				// driven by the heads, and no head skipped
+				continue
+			}
+
 			req := &ethereum.EthGetCurrentBlockNumberRequest{
 				RequestId: generateRequestId(),
 			}
 
 			blockNum, err := this.getBlockNum(ctx, req)
-			if err != nil { //if there is err, just skip this round.
-				this.logger.Errorf("get init block number with timer fail | error: %s", err.Error())
+			if err != nil { // the retries are done by chain accesser, try again in next tick.
+				this.logger.Errorf("get block number with timer fail | retryable: %t, error: %s", ethereum.IsRetryable(err), err.Error())
 				continue
 			}
+			this.distributeRound(ctx, blockNum)
+		}
+	}
+}
 
-			if blockNum == this.processedBlock { // NO new block
-				this.logger.Infof("no new block | processedBlock: %d, new blockNum: %d", this.processedBlock, blockNum)
-				continue
-			}
+// distributeRound kick off a round of tasks to get the transactions up to the new block, if there is no ongoing round.
+func (this *serviceParser) distributeRound(ctx context.Context, blockNum int) {
 
-			if this.processing { // there is ongoing tasks, wait it finished, and do nothing for now.
-				this.logger.Infof("processing, skip this round | processedBlock: %d, new blockNum: %d", this.processedBlock, blockNum)
-				continue
-			}
+	processedBlock := this.getProcessedBlock()
+	// the head at the same height or lower may be a reorganization, it can be verified only with the recorded hashes.
+	if blockNum <= processedBlock && this.blockHashes == nil { // NO new block
+		this.logger.Infof("no new block | processedBlock: %d, new blockNum: %d", processedBlock, blockNum)
+		return
+	}
 
-			// No ongoing tasks, kick off the work.
-			this.logger.Infof("kick up a new round of task | processedBlock: %d, new blockNum: %d", this.processedBlock, blockNum)
-			this.processedBlock = blockNum
-			this.updateAddress(ctx)
-			if this.addresses.size() == 0 { // edged case: the timer is trigger before there is any address
-				continue
+	if this.processing { // there is ongoing tasks, wait it finished, and do nothing for now.
+		this.logger.Infof("processing, skip this round | processedBlock: %d, new blockNum: %d", processedBlock, blockNum)
+		return
+	}
+
+	// verify the new blocks, and roll back the data of orphaned blocks before kicking off the work.
+	if this.blockHashes != nil {
+		forkBlock, err := this.detectReorg(ctx, blockNum)
+		if err != nil { // the new blocks can't be verified, skip this round.
+			this.logger.Errorf("detect chain reorganization fail | error: %s", err.Error())
+			return
+		}
+		if forkBlock < 0 && blockNum <= processedBlock { // NO new block, e.g. the node is behind. The processed block never moves back without rollback.
+			this.logger.Infof("no new block | processedBlock: %d, new blockNum: %d", processedBlock, blockNum)
+			return
+		}
+		if forkBlock >= 0 {
+			if finalizedBlock := this.getFinalizedBlock(); forkBlock < finalizedBlock { // the finalized data is changed, the confirmations setting is not enough
+				this.logger.Errorf("chain reorganization before finalized block | fork block: %d, finalized block: %d", forkBlock, finalizedBlock)
 			}
-			this.newTaskNoti <- this.addresses.size()
-			this.distributeTasks(blockNum)
+			this.rollback(forkBlock)
+			// the blocks after the new head are orphaned, if the canonical chain is shorter
+			this.blockHashes.truncate(blockNum)
 		}
 	}
+
+	// No ongoing tasks, kick off the work.
+	this.logger.Infof("kick up a new round of task | processedBlock: %d, new blockNum: %d", processedBlock, blockNum)
+	// only move the final cursor past the blocks with enough confirmations
+	this.setBlocks(blockNum, maxInt(this.getFinalizedBlock(), blockNum-this.confirmations))
+	this.updateAddress(ctx)
+	addresses := this.store.Addresses()
+	if len(addresses) == 0 { // edged case: the timer is trigger before there is any address
+		return
+	}
+	tasks := this.buildTasks(blockNum, addresses)
+	if len(tasks) == 0 { // edged case: all the addresses are retired or unsubscribed since they're listed
+		return
+	}
+	this.newTaskNoti <- len(tasks)
+	this.distributeTasks(tasks)
 }
 
 // startTaskExecution is the controller of task execution
@@ -216,7 +531,7 @@ func (this *serviceParser) startTaskExecution(ctx context.Context) {
 	}
 }
 
-// updateAddress pick up the new subscribed addressed and insert them into the storage (`this.addresses`)
+// updateAddress pick up the new subscribed addressed and insert them into the storage (`this.store`)
 func (this *serviceParser) updateAddress(ctx context.Context) {
 
 	// get new addresses
@@ -231,15 +546,18 @@ func (this *serviceParser) updateAddress(ctx context.Context) {
 
 	this.logger.Infof("%d addresses new added: %s", len(newAddresses), newAddresses)
 
-	this.addrLock.Lock()
-	defer this.addrLock.Unlock()
-	// add the new addresses, this would stop all the API queries
+	processedBlock := this.getProcessedBlock()
 	for _, addr := range newAddresses {
-		this.addresses.putAddress(addressTransaction{
-			address:      addr,
-			blockNum:     this.processedBlock,
-			transactions: make([]ethereum.Transaction, 0, this.maxTransactionNumber),
-		})
+		_, existing := this.store.GetCursor(addr)
+		if err := this.store.PutAddress(addr, processedBlock); err != nil {
+			this.logger.Errorf("put address into storage fail | address: %s, error: %s", addr, err.Error())
+			continue
+		}
+		if existing { // the history before its live cursor is unknown
+			this.cancelBackfill(addr)
+		} else {
+			this.activateBackfill(addr, processedBlock)
+		}
 	}
 }
 
@@ -249,11 +567,20 @@ func (this *serviceParser) executeTasks(ctx context.Context, workerNum int) {
 
 	this.logger.Infof("worker started | worker number: %d", workerNum)
 	for {
+		// the live tasks first
+		select {
+		case task := <-this.transactionTasks:
+			this.executeTransactionTask(ctx, workerNum, task)
+			continue
+		default:
+		}
+
 		select {
 		case task := <-this.transactionTasks:
-			this.updateTransactions(ctx, task.address, task.blockNum)
-			this.logger.Infof("finished task | worker: %d, address: %s", workerNum, task.address)
-			this.finishedTasks <- struct{}{}
+			this.executeTransactionTask(ctx, workerNum, task)
+		case task := <-this.backfillTasks:
+			this.executeBackfillTask(ctx, task)
+			this.logger.Infof("finished backfill task | worker: %d, address: %s", workerNum, task.address)
 		case <-ctx.Done():
 			this.logger.Infof("worker existing | worker number: %d", workerNum)
 			return
@@ -261,13 +588,70 @@ func (this *serviceParser) executeTasks(ctx context.Context, workerNum int) {
 	}
 }
 
-// distributeTasks distribute the task (to get transactions of new block) to the queue.
-func (this *serviceParser) distributeTasks(newBlockNum int) {
-	addresses := this.addresses.allAddresses()
-	this.logger.Debugf("existing addresses %v", addresses)
+func (this *serviceParser) executeTransactionTask(ctx context.Context, workerNum int, task transactionTask) {
+	if task.multiAddress {
+		this.updateTransactionsByAddresses(ctx, task.addresses, task.blockNum)
+	} else if len(task.addresses) == 1 {
+		this.updateTransactions(ctx, task.addresses[0], task.blockNum)
+	} else {
+		this.updateTransactionsBatch(ctx, task.addresses, task.blockNum)
+	}
+	if this.tokenTransfers {
+		this.updateTokenTransfers(ctx, task.addresses, task.blockNum)
+	}
+	if this.accountStates {
+		this.updateAccountStates(ctx, task.addresses, task.blockNum)
+	}
+	this.logger.Infof("finished task | worker: %d, addresses: %v", workerNum, task.addresses)
+	this.finishedTasks <- struct{}{}
+}
+
+// buildTasks group the addresses into tasks, `batchSize` addresses at most in one task.
+func (this *serviceParser) buildTasks(newBlockNum int, addresses []string) []transactionTask {
+	if this.addressChunkSize > 1 {
+		return this.buildMultiAddressTasks(newBlockNum, addresses)
+	}
+
+	tasks := make([]transactionTask, 0, (len(addresses)+this.batchSize-1)/this.batchSize)
+	for i := 0; i < len(addresses); i += this.batchSize {
+		end := minInt(i+this.batchSize, len(addresses))
+		tasks = append(tasks, transactionTask{newBlockNum, addresses[i:end], false})
+	}
+	return tasks
+}
+
+// buildMultiAddressTasks group the addresses with the same sync cursor into tasks, `addressChunkSize` addresses at most in one task.
+// So the addresses in one task can be queried with the same block range.
+func (this *serviceParser) buildMultiAddressTasks(newBlockNum int, addresses []string) []transactionTask {
+	var cursors []int
+	groups := make(map[int][]string)
 	for _, addr := range addresses {
-		this.logger.Infof("distribute task | address: %s", addr)
-		this.transactionTasks <- transactionTask{newBlockNum, addr}
+		cursor, ok := this.store.GetCursor(addr)
+		if !ok { // the address may be retired by LRU
+			continue
+		}
+		if _, ok := groups[cursor]; !ok {
+			cursors = append(cursors, cursor)
+		}
+		groups[cursor] = append(groups[cursor], addr)
+	}
+
+	var tasks []transactionTask
+	for _, cursor := range cursors {
+		group := groups[cursor]
+		for i := 0; i < len(group); i += this.addressChunkSize {
+			end := minInt(i+this.addressChunkSize, len(group))
+			tasks = append(tasks, transactionTask{newBlockNum, group[i:end], true})
+		}
+	}
+	return tasks
+}
+
+// distributeTasks distribute the task (to get transactions of new block) to the queue.
+func (this *serviceParser) distributeTasks(tasks []transactionTask) {
+	for _, task := range tasks {
+		this.logger.Infof("distribute task | addresses: %v", task.addresses)
+		this.transactionTasks <- task
 	}
 }
 
@@ -275,67 +659,354 @@ func (this *serviceParser) distributeTasks(newBlockNum int) {
 func (this *serviceParser) updateTransactions(ctx context.Context, addr string, blockNum int) {
 
 	req := this.constructGetTransactionRequest(addr, blockNum)
-	if req == nil {
-		this.logger.Errorf("construct get transaction request fail | address: %s", addr)
+	if req == nil { // the address is removed, or there is no new block for it
+		return
 	}
 
 	this.doUpdateTransactions(ctx, req)
 }
 
-// constructGetTransactionRequest construct the request of get transaction
+// updateTransactionsBatch update the transactions of several addresses with one batch call
+func (this *serviceParser) updateTransactionsBatch(ctx context.Context, addresses []string, blockNum int) {
+
+	batchId := generateRequestId()
+	reqs := make([]*ethereum.EthGetCurrentTransactionsByAddressRequest, 0, len(addresses))
+	for i, addr := range addresses {
+		req := this.constructGetTransactionRequest(addr, blockNum)
+		if req == nil {
+			continue
+		}
+		// the IDs should be unique in a batch
+		req.RequestId = fmt.Sprintf("%s-%d", batchId, i)
+		reqs = append(reqs, req)
+	}
+
+	switch len(reqs) {
+	case 0:
+		return
+	case 1:
+		this.doUpdateTransactions(ctx, reqs[0])
+		return
+	}
+
+	queryCtx, cancelFunc := context.WithDeadline(ctx, time.Now().Add(this.getTransactionsQueryTimeout))
+	defer cancelFunc()
+	results, err := this.chainAccesser.EthGetCurrentTransactionsByAddressBatch(queryCtx, reqs)
+	if err != nil {
+		this.logger.Errorf("call ethereum chain to get Transactions in batch fail | batch: %s, size: %d, error: %s", batchId, len(reqs), err.Error())
+		for _, req := range reqs {
+			this.handleTransactionsFailure(ctx, req, err)
+		}
+		return
+	}
+
+	for i, req := range reqs {
+		if i >= len(results) {
+			this.logger.Errorf("result missing in batch | req: %v", req)
+			continue
+		}
+		if results[i].Err != nil {
+			this.logger.Errorf("call ethereum chain to get Transactions fail | req: %v, error: %s", req, results[i].Err.Error())
+			this.handleTransactionsFailure(ctx, req, results[i].Err)
+			continue
+		}
+		// the cursor is kept, so they're fetched again in next round
+		if err := this.enrichTransactions(ctx, results[i].Transactions); err != nil {
+			this.logger.Errorf("enrich transactions fail | req: %v, error: %s", req, err.Error())
+			continue
+		}
+		this.storeTransactions(req, results[i].Transactions)
+	}
+}
+
+// updateTransactionsByAddresses update the transactions of several addresses with one `trace_filter` call.
+// The range starts from the lowest sync cursor of the addresses, and the result is fanned out by the `from` and `to` of transactions.
+func (this *serviceParser) updateTransactionsByAddresses(ctx context.Context, addresses []string, blockNum int) {
+
+	// the cursors may be moved since the task is built (e.g. rollback), so they're checked again.
+	cursors := make(map[string]int, len(addresses))
+	fromBlock := blockNum + 1
+	for _, addr := range addresses {
+		cursor, ok := this.store.GetCursor(addr)
+		if !ok { // the address may be retired by LRU
+			this.logger.Errorf("get address from storage fail | address: %s", addr)
+			continue
+		}
+		if cursor > blockNum { // already synced
+			continue
+		}
+		cursors[addr] = cursor
+		fromBlock = minInt(fromBlock, cursor)
+	}
+	if len(cursors) == 0 {
+		return
+	}
+
+	req := &ethereum.EthGetCurrentTransactionsByAddressesRequest{
+		FromBlock: convertDecimalToHex(fromBlock),
+		ToBlock:   convertDecimalToHex(blockNum),
+		RequestId: generateRequestId(),
+	}
+	for _, addr := range addresses {
+		if _, ok := cursors[addr]; ok {
+			req.Addresses = append(req.Addresses, addr)
+		}
+	}
+
+	queryCtx, cancelFunc := context.WithDeadline(ctx, time.Now().Add(this.getTransactionsQueryTimeout))
+	defer cancelFunc()
+	resp, err := this.chainAccesser.EthGetCurrentTransactionsByAddresses(queryCtx, req)
+	if err != nil {
+		this.logger.Errorf("call ethereum chain to get Transactions fail | req: %v, error: %s", req, err.Error())
+		if isPermanentFailure(ctx, err) { // e.g. too many addresses or blocks for the node, fetch the addresses one by one
+			for _, addr := range req.Addresses {
+				this.updateTransactions(ctx, addr, blockNum)
+			}
+		}
+		return
+	}
+	// the cursors are kept, so they're fetched again in next round
+	if err := this.enrichTransactions(ctx, resp); err != nil {
+		this.logger.Errorf("enrich transactions fail | req: %v, error: %s", req, err.Error())
+		return
+	}
+
+	// fan out the transactions, a transaction between 2 of the addresses belongs to both.
+	// A contract creation belongs to the created contract too.
+	fanout := make(map[string][]ethereum.Transaction, len(cursors))
+	for _, trx := range resp {
+		matched := make(map[string]bool, 3)
+		for _, addr := range []string{trx.Action.From, trx.Action.To, trx.Result.Address} {
+			addr = strings.ToLower(addr)
+			cursor, ok := cursors[addr]
+			if !ok || trx.BlockNumber < cursor || matched[addr] { // not subscribed, or fetched already
+				continue
+			}
+			matched[addr] = true
+			fanout[addr] = append(fanout[addr], trx)
+		}
+	}
+
+	for _, addr := range req.Addresses {
+		// the cursor may be moved by others during the query (e.g. rollback), drop the result in this case to avoid duplicates
+		err := this.store.AppendTransactions(addr, fanout[addr], cursors[addr], blockNum+1)
+		if err != nil {
+			this.logger.Errorf("store transactions fail | address: %s, from block: %d, error: %s", addr, cursors[addr], err.Error())
+			continue
+		}
+		this.setSyncError(addr, nil)
+		this.logger.Infof("update transactions success | address: %s, new trx number: %d, cursor: %d", addr, len(fanout[addr]), blockNum+1)
+	}
+}
+
+// updateTokenTransfers update the token transfers of the addresses, from the lowest token sync cursor of them.
+// The transfers are fanned out by `from` and `to`, the same as `updateTransactionsByAddresses`.
+// For the permanent failures (e.g. the range is too large for the node), the range is split into halves and fetched again.
+// The token sync cursors are never moved past the blocks not fetched.
+func (this *serviceParser) updateTokenTransfers(ctx context.Context, addresses []string, blockNum int) {
+
+	cursors := make(map[string]int, len(addresses))
+	var watched []string
+	fromBlock := blockNum + 1
+	for _, addr := range addresses {
+		cursor, ok := this.store.GetTokenCursor(addr)
+		if !ok || cursor > blockNum { // retired, or already synced
+			continue
+		}
+		cursors[addr] = cursor
+		watched = append(watched, addr)
+		fromBlock = minInt(fromBlock, cursor)
+	}
+	if len(watched) == 0 {
+		return
+	}
+
+	queryCtx, cancelFunc := context.WithDeadline(ctx, time.Now().Add(this.getTransactionsQueryTimeout))
+	defer cancelFunc()
+	transfers, err := ethereum.GetTokenTransfers(queryCtx, this.chainAccesser, convertDecimalToHex(fromBlock), convertDecimalToHex(blockNum), watched, generateRequestId())
+	if err != nil {
+		this.logger.Errorf("call ethereum chain to get token transfers fail | addresses: %v, from block: %d, error: %s", watched, fromBlock, err.Error())
+		if !isPermanentFailure(ctx, err) { // the cursors are kept, retried in next round
+			return
+		}
+		if fromBlock == blockNum { // the single block can't be fetched, keep the cursors and expose the error
+			this.logger.Errorf("permanent failure of a single block, token transfers blocked | addresses: %v, block: %d, error: %s", watched, blockNum, err.Error())
+			for _, addr := range watched {
+				this.setTokenSyncError(addr, err)
+			}
+			return
+		}
+		mid := fromBlock + (blockNum-fromBlock)/2
+		this.logger.Infof("permanent failure, split the range of token transfers | addresses: %v, from block: %d, middle block: %d, to block: %d", watched, fromBlock, mid, blockNum)
+		this.updateTokenTransfers(ctx, watched, mid)
+		for _, addr := range watched {
+			if cursor, ok := this.store.GetTokenCursor(addr); ok && cursor <= mid { // the first half is not fetched, retried in next round
+				return
+			}
+		}
+		this.updateTokenTransfers(ctx, watched, blockNum)
+		return
+	}
+
+	fanout := make(map[string][]ethereum.TokenTransfer, len(watched))
+	for _, transfer := range transfers {
+		for _, addr := range []string{transfer.From, transfer.To} {
+			cursor, ok := cursors[addr]
+			if !ok || transfer.BlockNumber < cursor { // not watched, or fetched already
+				continue
+			}
+			fanout[addr] = append(fanout[addr], transfer)
+			if transfer.From == transfer.To {
+				break
+			}
+		}
+	}
+
+	for _, addr := range watched {
+		err := this.store.AppendTokenTransfers(addr, fanout[addr], cursors[addr], blockNum+1)
+		if err != nil {
+			this.logger.Errorf("store token transfers fail | address: %s, from block: %d, error: %s", addr, cursors[addr], err.Error())
+			continue
+		}
+		this.setTokenSyncError(addr, nil)
+		this.logger.Infof("update token transfers success | address: %s, new transfer number: %d, cursor: %d", addr, len(fanout[addr]), blockNum+1)
+	}
+}
+
+// constructGetTransactionRequest construct the request of get transaction.
+// The range starts from the sync cursor of the address. `nil` is returned if there is nothing to fetch.
 func (this *serviceParser) constructGetTransactionRequest(addr string, blockNum int) *ethereum.EthGetCurrentTransactionsByAddressRequest {
-	addrData := this.addresses.getAddressIn(addr)
-	if addrData == nil { // this should not happen
+	cursor, ok := this.store.GetCursor(addr)
+	if !ok { // the address may be retired by LRU
+		this.logger.Errorf("get address from storage fail | address: %s", addr)
+		return nil
+	}
+
+	if cursor > blockNum { // already synced
+		this.logger.Debugf("address already synced | address: %s, cursor: %d, blockNum: %d", addr, cursor, blockNum)
 		return nil
 	}
 
-	// update
-	startBn := convertDecimalToHex(addrData.blockNum)
-	endBn := convertDecimalToHex(blockNum)
-	req := &ethereum.EthGetCurrentTransactionsByAddressRequest{
-		FromBlock:   startBn,
-		ToBlock:     endBn,
+	return newTransactionsRequest(addr, cursor, blockNum)
+}
+
+// newTransactionsRequest construct the request to get the transactions of an address in the block range
+func newTransactionsRequest(addr string, fromBlock, toBlock int) *ethereum.EthGetCurrentTransactionsByAddressRequest {
+	return &ethereum.EthGetCurrentTransactionsByAddressRequest{
+		FromBlock:   convertDecimalToHex(fromBlock),
+		ToBlock:     convertDecimalToHex(toBlock),
 		FromAddress: addr,
 		ToAddress:   addr,
-		RequestId:   fmt.Sprintf("%d", time.Now().UnixNano()),
+		RequestId:   generateRequestId(),
 	}
-	return req
 }
 
+// doUpdateTransactions fetch the transactions of the block range in `req`, and store them.
 func (this *serviceParser) doUpdateTransactions(ctx context.Context, req *ethereum.EthGetCurrentTransactionsByAddressRequest) {
 
-	addrData := this.addresses.getAddressIn(req.FromAddress)
-	if addrData == nil { // this should not happen
-		this.logger.Errorf("get address from storage fail | address: %s", req.FromAddress)
-		return
-	}
-
-	ctx, cancelFunc := context.WithDeadline(ctx, time.Now().Add(this.getTransactionsQueryTimeout))
+	queryCtx, cancelFunc := context.WithDeadline(ctx, time.Now().Add(this.getTransactionsQueryTimeout))
 	defer cancelFunc()
-	resp, err := this.chainAccesser.EthGetCurrentTransactionsByAddress(ctx, req)
+	resp, err := this.chainAccesser.EthGetCurrentTransactionsByAddress(queryCtx, req)
 	if err != nil {
 		this.logger.Errorf("call ethereum chain to get Transactions fail | req: %v, error: %s", req, err.Error())
+		this.handleTransactionsFailure(ctx, req, err)
+		return
+	}
+	// the cursor is kept, so they're fetched again in next round
+	if err := this.enrichTransactions(ctx, resp); err != nil {
+		this.logger.Errorf("enrich transactions fail | req: %v, error: %s", req, err.Error())
 		return
 	}
 
-	var newTrx []ethereum.Transaction
-	newTrxNum := len(resp)
+	this.storeTransactions(req, resp)
+}
 
-	if newTrxNum >= this.maxTransactionNumber {
-		newTrx = resp[:this.maxTransactionNumber]
-	} else {
-		newTrx = resp
-		space := this.maxTransactionNumber - len(newTrx)
-		if len(addrData.transactions) <= space {
-			newTrx = append(newTrx, addrData.transactions...)
-		} else {
-			newTrx = append(newTrx, addrData.transactions[:space]...)
-		}
+// handleTransactionsFailure handle the failure of fetching the range in `req`. The cursor is never moved past the blocks not fetched.
+// The retryable failures are retried in next round. For the permanent ones (e.g. the range is too large for the node),
+// the range is split into halves and fetched again. If a single block still fails, the error is exposed by the sync status of the address.
+func (this *serviceParser) handleTransactionsFailure(ctx context.Context, req *ethereum.EthGetCurrentTransactionsByAddressRequest, err error) {
+	if !isPermanentFailure(ctx, err) {
+		return
+	}
+	fromBlock, fromErr := convertHexToDecimal(req.FromBlock)
+	toBlock, toErr := convertHexToDecimal(req.ToBlock)
+	if fromErr != nil || toErr != nil {
+		this.logger.Errorf("invalid block range | req: %v", req)
+		return
+	}
+
+	if fromBlock >= toBlock { // the single block can't be fetched, keep the cursor and expose the error
+		this.logger.Errorf("permanent failure of a single block, address blocked | address: %s, block: %d, error: %s", req.FromAddress, fromBlock, err.Error())
+		this.setSyncError(req.FromAddress, err)
+		return
+	}
+
+	mid := fromBlock + (toBlock-fromBlock)/2
+	this.logger.Infof("permanent failure, split the range | address: %s, from block: %d, middle block: %d, to block: %d", req.FromAddress, fromBlock, mid, toBlock)
+	this.doUpdateTransactions(ctx, newTransactionsRequest(req.FromAddress, fromBlock, mid))
+	if cursor, ok := this.store.GetCursor(req.FromAddress); !ok || cursor != mid+1 { // the first half is not fetched, retried in next round
+		return
 	}
-	addrData.transactions = newTrx
+	this.doUpdateTransactions(ctx, newTransactionsRequest(req.FromAddress, mid+1, toBlock))
+}
 
-	this.logger.Infof("update transactions success | address: %s, new trx number: %d, total: %d",
-		req.FromAddress, minInt(newTrxNum, this.maxTransactionNumber), len(addrData.transactions))
+// enrichTransactions attach the receipts and block timestamps to the transactions in place, before they're stored.
+// The error is returned if the transactions should be fetched again in next round.
+func (this *serviceParser) enrichTransactions(ctx context.Context, transactions []ethereum.Transaction) error {
+	if err := this.attachReceipts(ctx, transactions); err != nil {
+		return err
+	}
+	if this.timestamps == nil {
+		return nil
+	}
+	return this.timestamps.attach(ctx, transactions)
+}
+
+// storeTransactions store the transactions fetched with `req`.
+// The sync cursor of the address is moved to the block after `req.ToBlock` only if the whole range is fetched.
+func (this *serviceParser) storeTransactions(req *ethereum.EthGetCurrentTransactionsByAddressRequest, transactions []ethereum.Transaction) {
+
+	fromBlock, err := convertHexToDecimal(req.FromBlock)
+	if err != nil {
+		this.logger.Errorf("invalid from block | req: %v, error: %s", req, err.Error())
+		return
+	}
+	toBlock, err := convertHexToDecimal(req.ToBlock)
+	if err != nil {
+		this.logger.Errorf("invalid to block | req: %v, error: %s", req, err.Error())
+		return
+	}
+
+	// the cursor may be moved by others during the query (e.g. rollback), drop the result in this case to avoid duplicates
+	err = this.store.AppendTransactions(req.FromAddress, transactions, fromBlock, toBlock+1)
+	if err != nil {
+		this.logger.Errorf("store transactions fail | address: %s, from block: %d, error: %s", req.FromAddress, fromBlock, err.Error())
+		return
+	}
+	this.setSyncError(req.FromAddress, nil)
+
+	this.logger.Infof("update transactions success | address: %s, new trx number: %d, cursor: %d",
+		req.FromAddress, len(transactions), toBlock+1)
+}
+
+func (this *serviceParser) getProcessedBlock() int {
+	this.blockLock.RLock()
+	defer this.blockLock.RUnlock()
+	return this.processedBlock
+}
+
+func (this *serviceParser) getFinalizedBlock() int {
+	this.blockLock.RLock()
+	defer this.blockLock.RUnlock()
+	return this.finalizedBlock
+}
+
+// setBlocks update the processed and finalized block together, so the queries never see one without the other.
+func (this *serviceParser) setBlocks(processedBlock, finalizedBlock int) {
+	this.blockLock.Lock()
+	defer this.blockLock.Unlock()
+	this.processedBlock = processedBlock
+	this.finalizedBlock = finalizedBlock
 }
 
 func (this *serviceParser) getBlockNum(ctx context.Context, req *ethereum.EthGetCurrentBlockNumberRequest) (int, error) {
@@ -348,16 +1019,40 @@ func (this *serviceParser) getBlockNum(ctx context.Context, req *ethereum.EthGet
 	return bn, nil
 }
 
+// isPermanentFailure check if a call would never succeed by retrying, e.g. it's rejected as an invalid request.
+// The failures caused by cancellation (e.g. the shutdown of parser) are not permanent.
+func isPermanentFailure(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	return !ethereum.IsRetryable(err)
+}
+
 func convertDecimalToHex(num int) string {
 	return fmt.Sprintf("0x%s", fmt.Sprintf("%x", num))
 }
 
+func convertHexToDecimal(hex string) (int, error) {
+	num, err := strconv.ParseInt(hex, 0, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(num), nil
+}
+
 //generateRequestId generate a new request ID
 // TODO: there are better solutions for this. but need more efforts
 func generateRequestId() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
 
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 func minInt(a, b int) int {
 	if a < b {
 		return a
@@ -2,7 +2,10 @@ package parser
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -13,6 +16,15 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// the addresses for test purpose, in the lower case form which is used as the key of storage
+const (
+	testAddress1 = "0x0000000000000000000000000000000000000001"
+	testAddress2 = "0x0000000000000000000000000000000000000002"
+	testAddress3 = "0x0000000000000000000000000000000000000003"
+	testAddress4 = "0x000000000000000000000000000000000000ffff"
+	testAddress5 = "0x000000000000000000000000000000000000fffe"
+)
+
 func Test_serviceParser_getBlockNum(t *testing.T) {
 	type fields struct {
 		processing           bool
@@ -20,8 +32,6 @@ func Test_serviceParser_getBlockNum(t *testing.T) {
 		interval             time.Duration
 		MaxConcurrentThreads int
 		MaxTransactionNumber int
-		newAddrLock          sync.Mutex
-		addrLock             sync.RWMutex
 		addresses            *addressTransactionLRU
 		transactionTasks     chan transactionTask
 		newTaskNoti          chan int
@@ -60,7 +70,7 @@ func Test_serviceParser_getBlockNum(t *testing.T) {
 
 			req := &ethereum.EthGetCurrentBlockNumberRequest{RequestId: "1"}
 			resp := tt.want
-			chanAccesser.EXPECT().EthGetCurrentBlockNumber(tt.args.context, req).Return(resp, tt.wantError)
+			chanAccesser.EXPECT().EthGetCurrentBlockNumber(gomock.Any(), req).Return(resp, tt.wantError)
 
 			parser := serviceParser{
 				chainAccesser: chanAccesser,
@@ -116,19 +126,18 @@ func Test_serviceParser_constructGetTransactionRequest(t *testing.T) {
 			chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
 			req := &ethereum.EthGetCurrentBlockNumberRequest{RequestId: tt.requestId}
 			resp := tt.initialBlockNum
-			chainAccesser.EXPECT().EthGetCurrentBlockNumber(tt.context, req).Return(resp, nil)
+			chainAccesser.EXPECT().EthGetCurrentBlockNumber(gomock.Any(), req).Return(resp, nil)
 
 			// Init Parser
 			parser := &serviceParser{
 				newAddrLock:          sync.Mutex{},
-				addrLock:             sync.RWMutex{},
 				transactionTasks:     make(chan transactionTask, tt.config.MaxConcurrentThreads),
 				interval:             tt.config.Interval,
 				maxConcurrentThreads: tt.config.MaxConcurrentThreads,
 				maxTransactionNumber: tt.config.MaxTransactionNumber,
 				chainAccesser:        chainAccesser,
 				logger:               logger,
-				addresses:            newAddressTransactionLRU(tt.config.MaxAddressNumber),
+				store:                newMemoryTransactionStore(tt.config.MaxAddressNumber, tt.config.MaxTransactionNumber),
 			}
 
 			blockNum, err := parser.getBlockNum(tt.context, req)
@@ -145,12 +154,16 @@ func Test_serviceParser_constructGetTransactionRequest(t *testing.T) {
 				blockNum: tt.args.oldBlockNum,
 			}
 
-			parser.addresses.putAddress(oldData)
+			lruOf(parser).putAddress(oldData)
 			got := parser.constructGetTransactionRequest(tt.args.addr, tt.args.blockNum)
 			assert.Equal(t, tt.args.addr, got.FromAddress)
 			assert.Equal(t, tt.args.addr, got.ToAddress)
 			assert.Equal(t, convertDecimalToHex(tt.args.blockNum), got.ToBlock)
 			assert.Equal(t, convertDecimalToHex(tt.args.oldBlockNum), got.FromBlock)
+
+			// already synced to `blockNum`, nothing to fetch
+			lruOf(parser).getAddressIn(tt.args.addr).blockNum = tt.args.blockNum + 1
+			assert.Nil(t, parser.constructGetTransactionRequest(tt.args.addr, tt.args.blockNum))
 		})
 	}
 }
@@ -271,6 +284,30 @@ func Test_serviceParser_doUpdateTransactions(t *testing.T) {
 			oldBlockNum: 100,
 			oldTrxNum:   2,
 		},
+		{
+			name:    "error case 1 - chain call fail, cursor kept",
+			context: context.Background(),
+			config: ServiceParserConfiguration{
+				MaxAddressNumber:     10,
+				MaxConcurrentThreads: 10,
+				MaxTransactionNumber: 3,
+				Interval:             time.Minute * 100, // for test purpose
+			},
+			requestId:       generateRequestId(),
+			initialBlockNum: 0,
+			args: args{
+				req: &ethereum.EthGetCurrentTransactionsByAddressRequest{
+					FromBlock:   "0x64",
+					ToBlock:     "0xc8",
+					FromAddress: "0xffff",
+					ToAddress:   "0xffff",
+				},
+				respErr: errors.New("chain call fail"),
+			},
+			addr:        "0xffff",
+			oldBlockNum: 100,
+			oldTrxNum:   2,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -284,22 +321,21 @@ func Test_serviceParser_doUpdateTransactions(t *testing.T) {
 			// mock getblocknum
 			req := &ethereum.EthGetCurrentBlockNumberRequest{RequestId: tt.requestId}
 			resp := tt.initialBlockNum
-			chainAccesser.EXPECT().EthGetCurrentBlockNumber(tt.context, req).Return(resp, nil)
+			chainAccesser.EXPECT().EthGetCurrentBlockNumber(gomock.Any(), req).Return(resp, nil)
 
 			// mock get transactions
-			chainAccesser.EXPECT().EthGetCurrentTransactionsByAddress(tt.context, tt.args.req).Return(tt.args.resp, tt.args.respErr)
+			chainAccesser.EXPECT().EthGetCurrentTransactionsByAddress(gomock.Any(), tt.args.req).Return(tt.args.resp, tt.args.respErr)
 
 			// Init Parser
 			parser := &serviceParser{
 				newAddrLock:          sync.Mutex{},
-				addrLock:             sync.RWMutex{},
 				transactionTasks:     make(chan transactionTask, tt.config.MaxConcurrentThreads),
 				interval:             tt.config.Interval,
 				maxConcurrentThreads: tt.config.MaxConcurrentThreads,
 				maxTransactionNumber: tt.config.MaxTransactionNumber,
 				chainAccesser:        chainAccesser,
 				logger:               logger,
-				addresses:            newAddressTransactionLRU(tt.config.MaxAddressNumber),
+				store:                newMemoryTransactionStore(tt.config.MaxAddressNumber, tt.config.MaxTransactionNumber),
 			}
 
 			blockNum, err := parser.getBlockNum(tt.context, req)
@@ -321,14 +357,20 @@ func Test_serviceParser_doUpdateTransactions(t *testing.T) {
 				blockNum:     tt.oldBlockNum,
 				transactions: oldtrx,
 			}
-			parser.addresses.putAddress(oldData)
+			lruOf(parser).putAddress(oldData)
 
 			parser.doUpdateTransactions(tt.context, tt.args.req)
 
-			got, ok := parser.addresses.dataMap[tt.addr]
+			got, ok := lruOf(parser).dataMap[tt.addr]
 			assert.Equal(t, true, ok)
 			assert.Equal(t, tt.addr, got.addressTransaction.address)
-			assert.Equal(t, tt.args.req.FromBlock, convertDecimalToHex(got.addressTransaction.blockNum))
+			if tt.args.respErr != nil {
+				assert.Equal(t, tt.args.req.FromBlock, convertDecimalToHex(got.addressTransaction.blockNum))
+				assert.Equal(t, tt.oldTrxNum, len(got.addressTransaction.transactions))
+				return
+			}
+			toBlock, _ := convertHexToDecimal(tt.args.req.ToBlock)
+			assert.Equal(t, toBlock+1, got.addressTransaction.blockNum)
 			assert.Equal(t, minInt(parser.maxTransactionNumber, len(tt.args.resp)+tt.oldTrxNum), len(got.addressTransaction.transactions))
 		})
 	}
@@ -392,15 +434,15 @@ func Test_serviceParser_updateAddress(t *testing.T) {
 			newAddress:      []string{"0x1111", "0x1110"},
 			oldAddress: []addressTransaction{
 				{
-					address:      "0x0001",
+					address:      testAddress1,
 					transactions: []ethereum.Transaction{},
 				},
 				{
-					address:      "0x0002",
+					address:      testAddress2,
 					transactions: []ethereum.Transaction{},
 				},
 				{
-					address:      "0x0003",
+					address:      testAddress3,
 					transactions: []ethereum.Transaction{},
 				},
 			},
@@ -419,12 +461,11 @@ func Test_serviceParser_updateAddress(t *testing.T) {
 			// mock getblocknum
 			req := &ethereum.EthGetCurrentBlockNumberRequest{RequestId: tt.requestId}
 			resp := tt.initialBlockNum
-			chainAccesser.EXPECT().EthGetCurrentBlockNumber(tt.context, req).Return(resp, nil)
+			chainAccesser.EXPECT().EthGetCurrentBlockNumber(gomock.Any(), req).Return(resp, nil)
 
 			// Init Parser
 			parser := &serviceParser{
 				newAddrLock:          sync.Mutex{},
-				addrLock:             sync.RWMutex{},
 				transactionTasks:     make(chan transactionTask, tt.config.MaxConcurrentThreads),
 				interval:             tt.config.Interval,
 				maxConcurrentThreads: tt.config.MaxConcurrentThreads,
@@ -432,7 +473,7 @@ func Test_serviceParser_updateAddress(t *testing.T) {
 				maxAddressNumber:     tt.config.MaxAddressNumber,
 				chainAccesser:        chainAccesser,
 				logger:               logger,
-				addresses:            newAddressTransactionLRU(tt.config.MaxAddressNumber),
+				store:                newMemoryTransactionStore(tt.config.MaxAddressNumber, tt.config.MaxTransactionNumber),
 			}
 
 			blockNum, _ := parser.getBlockNum(tt.context, req)
@@ -442,24 +483,24 @@ func Test_serviceParser_updateAddress(t *testing.T) {
 			go parser.start(tt.context)
 
 			for _, d := range tt.oldAddress {
-				parser.addresses.putAddress(d)
+				lruOf(parser).putAddress(d)
 			}
 			parser.newAddresses = tt.newAddress
 			parser.updateAddress(tt.args.context)
 
-			assert.Equal(t, minInt(len(tt.newAddress)+parser.maxAddressNumber, parser.maxAddressNumber), parser.addresses.size())
+			assert.Equal(t, minInt(len(tt.newAddress)+parser.maxAddressNumber, parser.maxAddressNumber), lruOf(parser).size())
 
 			for i := len(tt.newAddress) - 1; i >= 0 && i < parser.maxAddressNumber; i-- {
-				got := parser.addresses.getAddress(tt.newAddress[i])
+				got := lruOf(parser).getAddress(tt.newAddress[i])
 				assert.Equal(t, tt.newAddress[i], got.address)
 			}
 
 			if tt.oldAddressLogic { // specific for case 3
-				got := parser.addresses.getAddress(tt.oldAddress[0].address)
+				got := lruOf(parser).getAddress(tt.oldAddress[0].address)
 				assert.Equal(t, (*addressTransaction)(nil), got)
-				got = parser.addresses.getAddress(tt.oldAddress[1].address)
+				got = lruOf(parser).getAddress(tt.oldAddress[1].address)
 				assert.Equal(t, (*addressTransaction)(nil), got)
-				got = parser.addresses.getAddress(tt.oldAddress[2].address)
+				got = lruOf(parser).getAddress(tt.oldAddress[2].address)
 				assert.Equal(t, tt.oldAddress[2].address, got.address)
 				assert.Equal(t, tt.oldAddress[2].address, got.address)
 			}
@@ -496,15 +537,15 @@ func Test_serviceParser_GetTransactions(t *testing.T) {
 			args:            args{},
 			oldData: []addressTransaction{
 				{
-					address:      "0x0001",
+					address:      testAddress1,
 					transactions: []ethereum.Transaction{},
 				},
 				{
-					address:      "0x0002",
+					address:      testAddress2,
 					transactions: []ethereum.Transaction{},
 				},
 				{
-					address:      "0x0003",
+					address:      testAddress3,
 					transactions: []ethereum.Transaction{},
 				},
 			},
@@ -522,12 +563,11 @@ func Test_serviceParser_GetTransactions(t *testing.T) {
 			// mock getblocknum
 			req := &ethereum.EthGetCurrentBlockNumberRequest{RequestId: tt.requestId}
 			resp := tt.initialBlockNum
-			chainAccesser.EXPECT().EthGetCurrentBlockNumber(tt.context, req).Return(resp, nil)
+			chainAccesser.EXPECT().EthGetCurrentBlockNumber(gomock.Any(), req).Return(resp, nil)
 
 			// Init Parser
 			parser := &serviceParser{
 				newAddrLock:          sync.Mutex{},
-				addrLock:             sync.RWMutex{},
 				transactionTasks:     make(chan transactionTask, tt.config.MaxConcurrentThreads),
 				interval:             tt.config.Interval,
 				maxConcurrentThreads: tt.config.MaxConcurrentThreads,
@@ -535,7 +575,7 @@ func Test_serviceParser_GetTransactions(t *testing.T) {
 				maxAddressNumber:     tt.config.MaxAddressNumber,
 				chainAccesser:        chainAccesser,
 				logger:               logger,
-				addresses:            newAddressTransactionLRU(tt.config.MaxAddressNumber),
+				store:                newMemoryTransactionStore(tt.config.MaxAddressNumber, tt.config.MaxTransactionNumber),
 			}
 
 			blockNum, _ := parser.getBlockNum(tt.context, req)
@@ -545,16 +585,653 @@ func Test_serviceParser_GetTransactions(t *testing.T) {
 			go parser.start(tt.context)
 
 			for _, d := range tt.oldData {
-				parser.addresses.putAddress(d)
+				lruOf(parser).putAddress(d)
 			}
 
 			for _, d := range tt.oldData {
-				got := parser.GetTransactions(d.address)
+				got := parser.GetTransactions(ethereum.MustParseAddress(d.address))
 				assert.Equal(t, d.transactions, got)
 			}
 
-			got := parser.GetTransactions("0xfffnotexisti")
+			got := parser.GetTransactions(ethereum.MustParseAddress(testAddress5))
 			assert.Equal(t, []ethereum.Transaction{}, got)
 		})
 	}
 }
+
+func Test_serviceParser_GetFinalizedTransactions(t *testing.T) {
+	tests := []struct {
+		name           string
+		finalizedBlock int
+		cursor         int
+		transactions   []ethereum.Transaction
+		want           []ethereum.Transaction
+	}{
+		{
+			name:           "normal case 1 - pending transactions are filtered",
+			finalizedBlock: 100,
+			cursor:         103,
+			transactions: []ethereum.Transaction{
+				{BlockNumber: 102},
+				{BlockNumber: 101},
+				{BlockNumber: 100},
+				{BlockNumber: 99},
+			},
+			want: []ethereum.Transaction{
+				{BlockNumber: 100},
+				{BlockNumber: 99},
+			},
+		},
+		{
+			name:           "normal case 2 - all pending",
+			finalizedBlock: 100,
+			cursor:         103,
+			transactions: []ethereum.Transaction{
+				{BlockNumber: 102},
+			},
+			want: []ethereum.Transaction{},
+		},
+		{
+			name:           "normal case 3 - sync cursor behind the final cursor",
+			finalizedBlock: 100,
+			cursor:         100,
+			transactions: []ethereum.Transaction{
+				{BlockNumber: 100},
+				{BlockNumber: 99},
+			},
+			want: []ethereum.Transaction{
+				{BlockNumber: 99},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := &serviceParser{
+				finalizedBlock: tt.finalizedBlock,
+				store:          newMemoryTransactionStore(10, 10),
+			}
+			lruOf(parser).putAddress(addressTransaction{
+				address:      testAddress4,
+				blockNum:     tt.cursor,
+				transactions: tt.transactions,
+			})
+
+			assert.Equal(t, tt.want, parser.GetFinalizedTransactions(ethereum.MustParseAddress(testAddress4)))
+			assert.Equal(t, tt.transactions, parser.GetTransactions(ethereum.MustParseAddress(testAddress4)))
+		})
+	}
+}
+
+func Test_serviceParser_buildTasks(t *testing.T) {
+	addresses := []string{testAddress1, testAddress2, testAddress3}
+	tests := []struct {
+		name      string
+		batchSize int
+		want      []transactionTask
+	}{
+		{
+			name:      "normal case 1 - one address per task",
+			batchSize: 1,
+			want: []transactionTask{
+				{100, []string{testAddress1}, false},
+				{100, []string{testAddress2}, false},
+				{100, []string{testAddress3}, false},
+			},
+		},
+		{
+			name:      "normal case 2 - batch",
+			batchSize: 2,
+			want: []transactionTask{
+				{100, []string{testAddress1, testAddress2}, false},
+				{100, []string{testAddress3}, false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := &serviceParser{batchSize: tt.batchSize}
+			assert.Equal(t, tt.want, parser.buildTasks(100, addresses))
+		})
+	}
+}
+
+func Test_serviceParser_buildMultiAddressTasks(t *testing.T) {
+	parser := &serviceParser{
+		batchSize:        1,
+		addressChunkSize: 2,
+		store:            NewMemoryTransactionStore(10, 10),
+	}
+	parser.store.PutAddress(testAddress1, 90)
+	parser.store.PutAddress(testAddress2, 80)
+	parser.store.PutAddress(testAddress3, 90)
+	parser.store.PutAddress(testAddress4, 90)
+
+	// grouped by cursor, and at most 2 addresses in one task. The retired address is skipped.
+	addresses := []string{testAddress1, testAddress2, testAddress3, testAddress4, testAddress5}
+	want := []transactionTask{
+		{100, []string{testAddress1, testAddress3}, true},
+		{100, []string{testAddress4}, true},
+		{100, []string{testAddress2}, true},
+	}
+	assert.Equal(t, want, parser.buildTasks(100, addresses))
+}
+
+// vanishingStore is a `TransactionStore` whose addresses are all removed right after they're listed
+type vanishingStore struct {
+	TransactionStore
+}
+
+func (this *vanishingStore) Addresses() []string {
+	addresses := this.TransactionStore.Addresses()
+	for _, addr := range addresses {
+		this.TransactionStore.Evict(addr)
+	}
+	return addresses
+}
+
+func Test_serviceParser_distributeRound_noTask(t *testing.T) {
+	parser := &serviceParser{
+		processedBlock:   100,
+		batchSize:        1,
+		addressChunkSize: 2,
+		newTaskNoti:      make(chan int),
+		logger:           logging.NewDefaultLogger(logging.LevelDebug),
+		store:            &vanishingStore{NewMemoryTransactionStore(10, 10)},
+	}
+	parser.store.PutAddress(testAddress1, 90)
+	parser.store.PutAddress(testAddress2, 80)
+
+	// no task is notified, otherwise the executor would wait for them forever
+	done := make(chan struct{})
+	go func() {
+		parser.distributeRound(context.Background(), 101)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case taskNum := <-parser.newTaskNoti:
+		t.Fatalf("unexpected task notification | number: %d", taskNum)
+	case <-time.After(time.Second):
+		t.Fatal("round not finished")
+	}
+	assert.Equal(t, 101, parser.GetCurrentBlock())
+}
+
+func Test_serviceParser_updateTransactionsByAddresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    []ethereum.Transaction
+		respErr error
+		// the transactions of each address, if they're fetched one by one
+		singleResp map[string][]ethereum.Transaction
+		wantCursor map[string]int
+		wantTrx    map[string][]ethereum.Transaction
+	}{
+		{
+			name: "normal case 1 - fan out by from and to",
+			resp: []ethereum.Transaction{
+				{BlockNumber: 180, Action: ethereum.Action{From: testAddress1, To: "0x00000000000000000000000000000000000000aa"}},
+				// in mixed case, between 2 of the addresses
+				{BlockNumber: 170, Action: ethereum.Action{From: "0x000000000000000000000000000000000000FFFF", To: testAddress1}},
+				// before the cursor of testAddress4, fetched already
+				{BlockNumber: 120, Action: ethereum.Action{From: testAddress4}},
+				{BlockNumber: 110, Action: ethereum.Action{To: testAddress1}},
+			},
+			wantCursor: map[string]int{testAddress1: 201, testAddress4: 201, testAddress3: 300},
+			wantTrx: map[string][]ethereum.Transaction{
+				testAddress1: {
+					{BlockNumber: 180, Action: ethereum.Action{From: testAddress1, To: "0x00000000000000000000000000000000000000aa"}},
+					{BlockNumber: 170, Action: ethereum.Action{From: "0x000000000000000000000000000000000000FFFF", To: testAddress1}},
+					{BlockNumber: 110, Action: ethereum.Action{To: testAddress1}},
+				},
+				testAddress4: {
+					{BlockNumber: 170, Action: ethereum.Action{From: "0x000000000000000000000000000000000000FFFF", To: testAddress1}},
+				},
+				testAddress3: {},
+			},
+		},
+		{
+			name: "normal case 2 - contract creation, fan out to the creator and the created contract",
+			resp: []ethereum.Transaction{
+				{BlockNumber: 180, Type: "create", Action: ethereum.Action{From: testAddress1}, Result: ethereum.Result{Address: testAddress4}},
+			},
+			wantCursor: map[string]int{testAddress1: 201, testAddress4: 201, testAddress3: 300},
+			wantTrx: map[string][]ethereum.Transaction{
+				testAddress1: {{BlockNumber: 180, Type: "create", Action: ethereum.Action{From: testAddress1}, Result: ethereum.Result{Address: testAddress4}}},
+				testAddress4: {{BlockNumber: 180, Type: "create", Action: ethereum.Action{From: testAddress1}, Result: ethereum.Result{Address: testAddress4}}},
+				testAddress3: {},
+			},
+		},
+		{
+			name:       "error case 1 - chain call fail",
+			respErr:    errors.New("chain call fail"),
+			wantCursor: map[string]int{testAddress1: 100, testAddress4: 150, testAddress3: 300},
+			wantTrx: map[string][]ethereum.Transaction{
+				testAddress1: {},
+				testAddress4: {},
+				testAddress3: {},
+			},
+		},
+		{
+			name:    "error case 2 - permanent failure, fetched one by one",
+			respErr: &ethereum.RPCError{Code: -32602, Message: "invalid params"},
+			singleResp: map[string][]ethereum.Transaction{
+				testAddress1: {{BlockNumber: 180, Action: ethereum.Action{From: testAddress1}}},
+				testAddress4: {{BlockNumber: 160, Action: ethereum.Action{To: testAddress4}}},
+			},
+			wantCursor: map[string]int{testAddress1: 201, testAddress4: 201, testAddress3: 300},
+			wantTrx: map[string][]ethereum.Transaction{
+				testAddress1: {{BlockNumber: 180, Action: ethereum.Action{From: testAddress1}}},
+				testAddress4: {{BlockNumber: 160, Action: ethereum.Action{To: testAddress4}}},
+				testAddress3: {},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+			chainAccesser.EXPECT().EthGetCurrentTransactionsByAddresses(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, req *ethereum.EthGetCurrentTransactionsByAddressesRequest) ([]ethereum.Transaction, error) {
+					// from the lowest cursor, and the synced address is skipped
+					assert.Equal(t, "0x64", req.FromBlock)
+					assert.Equal(t, "0xc8", req.ToBlock)
+					assert.Equal(t, []string{testAddress1, testAddress4}, req.Addresses)
+					return tt.resp, tt.respErr
+				})
+			chainAccesser.EXPECT().EthGetCurrentTransactionsByAddress(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, req *ethereum.EthGetCurrentTransactionsByAddressRequest) ([]ethereum.Transaction, error) {
+					// from the cursor of each address
+					cursors := map[string]string{testAddress1: "0x64", testAddress4: "0x96"}
+					assert.Equal(t, cursors[req.FromAddress], req.FromBlock)
+					assert.Equal(t, "0xc8", req.ToBlock)
+					return tt.singleResp[req.FromAddress], nil
+				}).Times(len(tt.singleResp))
+
+			parser := &serviceParser{
+				logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+				chainAccesser:               chainAccesser,
+				store:                       NewMemoryTransactionStore(10, 10),
+				getTransactionsQueryTimeout: time.Second,
+			}
+			parser.store.PutAddress(testAddress1, 100)
+			parser.store.PutAddress(testAddress4, 150)
+			parser.store.PutAddress(testAddress3, 300)
+
+			parser.updateTransactionsByAddresses(context.Background(), []string{testAddress1, testAddress4, testAddress3}, 200)
+
+			for addr, want := range tt.wantCursor {
+				cursor, _ := parser.store.GetCursor(addr)
+				assert.Equal(t, want, cursor)
+				transactions, _ := parser.store.GetTransactions(addr)
+				assert.Equal(t, tt.wantTrx[addr], transactions)
+			}
+		})
+	}
+}
+
+func Test_serviceParser_updateTransactionsBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []ethereum.EthGetCurrentTransactionsByAddressResult
+		respErr error
+		// the ranges fetched for testAddress1 one by one, when its request fails permanently
+		wantRanges [][2]int
+		wantCursor map[string]int
+		wantTrxNum map[string]int
+	}{
+		{
+			name: "normal case 1",
+			results: []ethereum.EthGetCurrentTransactionsByAddressResult{
+				{Transactions: []ethereum.Transaction{{BlockNumber: 150}}},
+				{Transactions: []ethereum.Transaction{{BlockNumber: 180}, {BlockNumber: 160}}},
+			},
+			wantCursor: map[string]int{testAddress1: 201, testAddress2: 201, testAddress3: 300},
+			wantTrxNum: map[string]int{testAddress1: 1, testAddress2: 2, testAddress3: 0},
+		},
+		{
+			name: "error case 1 - one of the requests fail",
+			results: []ethereum.EthGetCurrentTransactionsByAddressResult{
+				{Err: errors.New("chain error")},
+				{Transactions: []ethereum.Transaction{{BlockNumber: 180}}},
+			},
+			wantCursor: map[string]int{testAddress1: 100, testAddress2: 201, testAddress3: 300},
+			wantTrxNum: map[string]int{testAddress1: 0, testAddress2: 1, testAddress3: 0},
+		},
+		{
+			name:       "error case 2 - batch call fail",
+			respErr:    errors.New("chain call fail"),
+			wantCursor: map[string]int{testAddress1: 100, testAddress2: 150, testAddress3: 300},
+			wantTrxNum: map[string]int{testAddress1: 0, testAddress2: 0, testAddress3: 0},
+		},
+		{
+			name: "error case 3 - permanent failure of one request, range split",
+			results: []ethereum.EthGetCurrentTransactionsByAddressResult{
+				{Err: &ethereum.RPCError{Code: -32602, Message: "invalid params"}},
+				{Err: &ethereum.RPCError{Code: -32005, Message: "limit exceeded"}},
+			},
+			// [100, 150] is rejected too, and split into [100, 125] and [126, 150]. Then [151, 200].
+			wantRanges: [][2]int{{100, 150}, {100, 125}, {126, 150}, {151, 200}},
+			wantCursor: map[string]int{testAddress1: 201, testAddress2: 150, testAddress3: 300},
+			wantTrxNum: map[string]int{testAddress1: 3, testAddress2: 0, testAddress3: 0},
+		},
+		{
+			name:       "error case 4 - batch call canceled, not treated as permanent",
+			respErr:    context.Canceled,
+			wantCursor: map[string]int{testAddress1: 100, testAddress2: 150, testAddress3: 300},
+			wantTrxNum: map[string]int{testAddress1: 0, testAddress2: 0, testAddress3: 0},
+		},
+		{
+			name: "error case 5 - result size limit of one request, range halved",
+			results: []ethereum.EthGetCurrentTransactionsByAddressResult{
+				{Err: &ethereum.RPCError{Code: -32005, Message: "query returned more than 10000 results"}},
+				{Transactions: []ethereum.Transaction{{BlockNumber: 180}}},
+			},
+			wantRanges: [][2]int{{100, 150}, {100, 125}, {126, 150}, {151, 200}},
+			wantCursor: map[string]int{testAddress1: 201, testAddress2: 201, testAddress3: 300},
+			wantTrxNum: map[string]int{testAddress1: 3, testAddress2: 1, testAddress3: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+			chainAccesser.EXPECT().EthGetCurrentTransactionsByAddressBatch(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, reqs []*ethereum.EthGetCurrentTransactionsByAddressRequest) ([]ethereum.EthGetCurrentTransactionsByAddressResult, error) {
+					// the synced address is skipped
+					assert.Equal(t, 2, len(reqs))
+					assert.Equal(t, testAddress1, reqs[0].FromAddress)
+					assert.Equal(t, "0x64", reqs[0].FromBlock)
+					assert.Equal(t, testAddress2, reqs[1].FromAddress)
+					assert.Equal(t, "0x96", reqs[1].FromBlock)
+					assert.NotEqual(t, reqs[0].RequestId, reqs[1].RequestId)
+					return tt.results, tt.respErr
+				})
+			// the node only accepts the ranges of 50 blocks at most
+			var ranges [][2]int
+			chainAccesser.EXPECT().EthGetCurrentTransactionsByAddress(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, req *ethereum.EthGetCurrentTransactionsByAddressRequest) ([]ethereum.Transaction, error) {
+					assert.Equal(t, testAddress1, req.FromAddress)
+					from, _ := convertHexToDecimal(req.FromBlock)
+					to, _ := convertHexToDecimal(req.ToBlock)
+					ranges = append(ranges, [2]int{from, to})
+					if to-from+1 > 50 {
+						return nil, &ethereum.RPCError{Code: -32005, Message: "query returned more than 10000 results"}
+					}
+					return []ethereum.Transaction{{BlockNumber: to}}, nil
+				}).Times(len(tt.wantRanges))
+
+			parser := &serviceParser{
+				logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+				chainAccesser:               chainAccesser,
+				store:                       NewMemoryTransactionStore(10, 10),
+				getTransactionsQueryTimeout: time.Second,
+			}
+			parser.store.PutAddress(testAddress1, 100)
+			parser.store.PutAddress(testAddress2, 150)
+			parser.store.PutAddress(testAddress3, 300)
+
+			parser.updateTransactionsBatch(context.Background(), []string{testAddress1, testAddress2, testAddress3}, 200)
+
+			assert.Equal(t, tt.wantRanges, ranges)
+			for addr, want := range tt.wantCursor {
+				cursor, _ := parser.store.GetCursor(addr)
+				assert.Equal(t, want, cursor)
+				transactions, _ := parser.store.GetTransactions(addr)
+				assert.Equal(t, tt.wantTrxNum[addr], len(transactions))
+			}
+		})
+	}
+}
+
+func Test_serviceParser_updateTokenTransfers(t *testing.T) {
+	transferLog := func(from, to string, logIndex int) ethereum.Log {
+		return ethereum.Log{
+			Address:         "0x00000000000000000000000000000000000000cc",
+			Topics:          []string{ethereum.TransferTopic, ethereum.AddressTopic(from), ethereum.AddressTopic(to)},
+			Data:            fmt.Sprintf("0x%064x", 1000),
+			BlockNumber:     "0xb4",
+			TransactionHash: "0x01",
+			LogIndex:        fmt.Sprintf("0x%x", logIndex),
+		}
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+	// the transfers sent from, and sent to the addresses. The one between 2 of the addresses is returned by both.
+	chainAccesser.EXPECT().EthGetLogs(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, req *ethereum.EthGetLogsRequest) ([]ethereum.Log, error) {
+			assert.Equal(t, "0x64", req.FromBlock)
+			assert.Equal(t, "0xc8", req.ToBlock)
+			if req.Topics[1] != nil {
+				assert.Equal(t, []string{ethereum.AddressTopic(testAddress1), ethereum.AddressTopic(testAddress4)}, req.Topics[1])
+				return []ethereum.Log{transferLog(testAddress1, testAddress4, 0)}, nil
+			}
+			return []ethereum.Log{transferLog(testAddress1, testAddress4, 0), transferLog(testAddress5, testAddress1, 1)}, nil
+		}).Times(2)
+
+	parser := &serviceParser{
+		logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+		chainAccesser:               chainAccesser,
+		store:                       NewMemoryTransactionStore(10, 10),
+		getTransactionsQueryTimeout: time.Second,
+	}
+	parser.store.PutAddress(testAddress1, 100)
+	parser.store.PutAddress(testAddress4, 150)
+	parser.store.PutAddress(testAddress3, 300)
+
+	parser.updateTokenTransfers(context.Background(), []string{testAddress1, testAddress4, testAddress3}, 200)
+
+	got := parser.GetTokenTransfers(ethereum.MustParseAddress(testAddress1))
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, testAddress4, got[0].To)
+	assert.Equal(t, testAddress5, got[1].From)
+	got = parser.GetTokenTransfers(ethereum.MustParseAddress(testAddress4))
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, "1000", got[0].Value.String())
+
+	cursor, _ := parser.store.GetTokenCursor(testAddress1)
+	assert.Equal(t, 201, cursor)
+	cursor, _ = parser.store.GetTokenCursor(testAddress3)
+	assert.Equal(t, 300, cursor)
+	// the transactions are synced separately
+	cursor, _ = parser.store.GetCursor(testAddress1)
+	assert.Equal(t, 100, cursor)
+}
+
+func Test_serviceParser_doUpdateTransactions_permanentFailure(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+	// the node rejects the block 150 until it's fixed
+	badBlock := 150
+	chainAccesser.EXPECT().EthGetCurrentTransactionsByAddress(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, req *ethereum.EthGetCurrentTransactionsByAddressRequest) ([]ethereum.Transaction, error) {
+			from, _ := convertHexToDecimal(req.FromBlock)
+			to, _ := convertHexToDecimal(req.ToBlock)
+			if from <= badBlock && badBlock <= to {
+				return nil, &ethereum.RPCError{Code: -32602, Message: "invalid block"}
+			}
+			return []ethereum.Transaction{{BlockNumber: to}}, nil
+		}).AnyTimes()
+
+	parser := &serviceParser{
+		logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+		chainAccesser:               chainAccesser,
+		store:                       NewMemoryTransactionStore(10, 100),
+		getTransactionsQueryTimeout: time.Second,
+	}
+	parser.store.PutAddress(testAddress1, 100)
+
+	// the blocks before the bad one are fetched, and the cursor stops at it
+	parser.updateTransactions(context.Background(), testAddress1, 200)
+	status, ok := parser.GetSyncStatus(ethereum.MustParseAddress(testAddress1))
+	assert.Equal(t, true, ok)
+	assert.Equal(t, SyncStatus{Cursor: 150, TokenCursor: 100, Error: "chain error | code: -32602, message: invalid block"}, status)
+	for _, trx := range parser.GetTransactions(ethereum.MustParseAddress(testAddress1)) {
+		assert.Less(t, trx.BlockNumber, 150)
+	}
+
+	// retried in next round, and the error is cleared once it's fetched
+	badBlock = -1
+	parser.updateTransactions(context.Background(), testAddress1, 210)
+	status, _ = parser.GetSyncStatus(ethereum.MustParseAddress(testAddress1))
+	assert.Equal(t, SyncStatus{Cursor: 211, TokenCursor: 100}, status)
+
+	// dropped with the address
+	parser.setSyncError(testAddress1, errors.New("chain error"))
+	parser.Unsubscribe(ethereum.MustParseAddress(testAddress1))
+	_, ok = parser.GetSyncStatus(ethereum.MustParseAddress(testAddress1))
+	assert.Equal(t, false, ok)
+	assert.Equal(t, 0, len(parser.syncErrors))
+}
+
+func Test_serviceParser_updateTokenTransfers_permanentFailure(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+	// the node rejects the ranges larger than 30 blocks, and the block 180
+	chainAccesser.EXPECT().EthGetLogs(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, req *ethereum.EthGetLogsRequest) ([]ethereum.Log, error) {
+			from, _ := convertHexToDecimal(req.FromBlock)
+			to, _ := convertHexToDecimal(req.ToBlock)
+			if to-from+1 > 30 || (from <= 180 && 180 <= to) {
+				return nil, &ethereum.RPCError{Code: -32602, Message: "invalid params"}
+			}
+			return nil, nil
+		}).AnyTimes()
+
+	parser := &serviceParser{
+		logger:                      logging.NewDefaultLogger(logging.LevelDebug),
+		chainAccesser:               chainAccesser,
+		store:                       NewMemoryTransactionStore(10, 10),
+		getTransactionsQueryTimeout: time.Second,
+	}
+	parser.store.PutAddress(testAddress1, 100)
+	parser.store.PutAddress(testAddress4, 150)
+
+	// the token cursors stop at the bad block, instead of skipping it
+	parser.updateTokenTransfers(context.Background(), []string{testAddress1, testAddress4}, 200)
+	for _, addr := range []string{testAddress1, testAddress4} {
+		status, _ := parser.GetSyncStatus(ethereum.MustParseAddress(addr))
+		assert.Equal(t, 180, status.TokenCursor)
+		assert.Equal(t, "chain error | code: -32602, message: invalid params", status.TokenError)
+		assert.Equal(t, "", status.Error)
+	}
+}
+
+// lruOf return the LRU of the in-memory storage of parser, for test purpose
+func lruOf(parser *serviceParser) *addressTransactionLRU {
+	return parser.store.(*memoryTransactionStore).lru
+}
+
+func Test_serviceParser_Unsubscribe(t *testing.T) {
+	tests := []struct {
+		name         string
+		newAddresses []string
+		stored       []string
+		address      string
+		want         bool
+		wantNew      []string
+		wantStored   []string
+	}{
+		{
+			name:         "normal case 1 - pending address",
+			newAddresses: []string{testAddress1, testAddress2, testAddress1},
+			stored:       []string{testAddress3},
+			address:      testAddress1,
+			want:         true,
+			wantNew:      []string{testAddress2},
+			wantStored:   []string{testAddress3},
+		},
+		{
+			name:         "normal case 2 - stored address",
+			newAddresses: []string{testAddress2},
+			stored:       []string{testAddress1, testAddress3},
+			address:      testAddress1,
+			want:         true,
+			wantNew:      []string{testAddress2},
+			wantStored:   []string{testAddress3},
+		},
+		{
+			name:         "normal case 3 - not subscribed",
+			newAddresses: []string{testAddress2},
+			stored:       []string{testAddress3},
+			address:      testAddress1,
+			want:         false,
+			wantNew:      []string{testAddress2},
+			wantStored:   []string{testAddress3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := &serviceParser{
+				newAddresses: tt.newAddresses,
+				logger:       logging.NewDefaultLogger(logging.LevelDebug),
+				store:        newMemoryTransactionStore(10, 10),
+			}
+			for _, addr := range tt.stored {
+				parser.store.PutAddress(addr, 100)
+			}
+
+			assert.Equal(t, tt.want, parser.Unsubscribe(ethereum.MustParseAddress(tt.address)))
+			assert.Equal(t, tt.wantNew, parser.newAddresses)
+			assert.Equal(t, tt.wantStored, parser.store.Addresses())
+		})
+	}
+}
+
+// fakeHeadSubscriber is a `HeadSubscriber` whose heads and connection state are controlled by test
+type fakeHeadSubscriber struct {
+	heads     chan *ethereum.Block
+	connected int32
+}
+
+func (this *fakeHeadSubscriber) SubscribeNewHeads(ctx context.Context) (<-chan *ethereum.Block, error) {
+	return this.heads, nil
+}
+
+func (this *fakeHeadSubscriber) Connected() bool {
+	return atomic.LoadInt32(&this.connected) == 1
+}
+
+func Test_serviceParser_startTaskDistribution_heads(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+
+	subscriber := &fakeHeadSubscriber{heads: make(chan *ethereum.Block), connected: 1}
+	parser := &serviceParser{
+		processedBlock: 10,
+		interval:       10 * time.Millisecond,
+		headSubscriber: subscriber,
+		chainAccesser:  chainAccesser,
+		store:          NewMemoryTransactionStore(10, 10),
+		logger:         logging.NewDefaultLogger(logging.LevelDebug),
+	}
+	blockNum := func() int {
+		return parser.GetCurrentBlock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go parser.startTaskDistribution(ctx)
+
+	// driven by heads, no polling while the subscription is alive
+	subscriber.heads <- &ethereum.Block{Number: "0xc"}
+	assert.Eventually(t, func() bool { return blockNum() == 12 }, time.Second, 5*time.Millisecond)
+	time.Sleep(5 * parser.interval)
+	assert.Equal(t, 12, blockNum())
+
+	// fall back to polling after the subscription dropped
+	chainAccesser.EXPECT().EthGetCurrentBlockNumber(gomock.Any(), gomock.Any()).Return(20, nil).MinTimes(1)
+	atomic.StoreInt32(&subscriber.connected, 0)
+	assert.Eventually(t, func() bool { return blockNum() == 20 }, time.Second, 5*time.Millisecond)
+	cancel()
+}
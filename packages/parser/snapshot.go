@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+)
+
+// the version of snapshot format. Need to increase it when the format is changed incompatibly.
+const snapshotVersion = 1
+
+// ErrParserStarted is returned if the state is restored after the parser is started, which would race with the ongoing rounds.
+var ErrParserStarted = errors.New("parser already started")
+
+// Snapshotter is implemented by the parsers which can save and load their state.
+// `Restore` should be called before the parser is started.
+type Snapshotter interface {
+	Snapshot(io.Writer) error
+	Restore(io.Reader) error
+}
+
+type parserSnapshot struct {
+	Version        int `json:"version"`
+	ProcessedBlock int `json:"processed_block"`
+	// addresses subscribed but not picked up by the task distributor yet
+	NewAddresses []string `json:"new_addresses"`
+	// the least recently used first
+	Addresses []addressSnapshot `json:"addresses"`
+}
+
+type addressSnapshot struct {
+	Address      string                 `json:"address"`
+	Cursor       int                    `json:"cursor"`
+	Transactions []ethereum.Transaction `json:"transactions"`
+	// missing in the snapshots written before token transfers are indexed, the token sync cursor is `Cursor` in this case.
+	TokenCursor    *int                     `json:"token_cursor,omitempty"`
+	TokenTransfers []ethereum.TokenTransfer `json:"token_transfers,omitempty"`
+}
+
+// Snapshot write the state of parser into `w`, in JSON format.
+// The state covers the subscribed addresses, the sync cursors, the transactions and the processed block.
+func (this *serviceParser) Snapshot(w io.Writer) error {
+
+	this.newAddrLock.Lock()
+	newAddresses := make([]string, len(this.newAddresses))
+	copy(newAddresses, this.newAddresses)
+	this.newAddrLock.Unlock()
+
+	snapshot := parserSnapshot{
+		Version:        snapshotVersion,
+		ProcessedBlock: this.getProcessedBlock(),
+		NewAddresses:   newAddresses,
+	}
+
+	// query from the least recently used one, so the order of the storage is kept.
+	addresses := this.store.Addresses()
+	for i := len(addresses) - 1; i >= 0; i-- {
+		cursor, ok := this.store.GetCursor(addresses[i])
+		if !ok { // retired during the snapshot
+			continue
+		}
+		transactions, ok := this.store.GetTransactions(addresses[i])
+		if !ok {
+			continue
+		}
+		tokenCursor, ok := this.store.GetTokenCursor(addresses[i])
+		if !ok {
+			continue
+		}
+		transfers, _ := this.store.GetTokenTransfers(addresses[i])
+		snapshot.Addresses = append(snapshot.Addresses, addressSnapshot{
+			Address:        addresses[i],
+			Cursor:         cursor,
+			Transactions:   transactions,
+			TokenCursor:    &tokenCursor,
+			TokenTransfers: transfers,
+		})
+	}
+
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		this.logger.Errorf("write snapshot fail | err: %s", err.Error())
+		return err
+	}
+	this.logger.Infof("write snapshot success | processed block: %d, address number: %d", snapshot.ProcessedBlock, len(snapshot.Addresses))
+	return nil
+}
+
+// Restore load the state of parser from `r`, which is written by `Snapshot`.
+// The existing data of the addresses in the snapshot is replaced.
+// `ErrParserStarted` is returned if the task distributor is running, set `ServiceParserConfiguration.Snapshot` instead.
+func (this *serviceParser) Restore(r io.Reader) error {
+
+	this.blockLock.RLock()
+	started := this.started
+	this.blockLock.RUnlock()
+	if started {
+		this.logger.Errorf("restore snapshot fail | err: %s", ErrParserStarted.Error())
+		return ErrParserStarted
+	}
+
+	var snapshot parserSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		this.logger.Errorf("read snapshot fail | err: %s", err.Error())
+		return err
+	}
+	if snapshot.Version != snapshotVersion {
+		this.logger.Errorf("unsupported snapshot version | version: %d", snapshot.Version)
+		return fmt.Errorf("unsupported snapshot version: %d", snapshot.Version)
+	}
+
+	for _, data := range snapshot.Addresses {
+		this.store.Evict(data.Address)
+		if err := this.store.PutAddress(data.Address, data.Cursor); err != nil {
+			return err
+		}
+		if err := this.store.AppendTransactions(data.Address, data.Transactions, data.Cursor, data.Cursor); err != nil {
+			return err
+		}
+		if data.TokenCursor != nil {
+			if err := this.store.AppendTokenTransfers(data.Address, data.TokenTransfers, data.Cursor, *data.TokenCursor); err != nil {
+				return err
+			}
+		}
+	}
+
+	this.newAddrLock.Lock()
+	this.newAddresses = append(this.newAddresses, snapshot.NewAddresses...)
+	this.newAddrLock.Unlock()
+
+	// the cursors of addresses are restored, the blocks after them would be fetched in the coming rounds.
+	this.setBlocks(snapshot.ProcessedBlock, snapshot.ProcessedBlock-this.confirmations)
+
+	this.logger.Infof("restore snapshot success | processed block: %d, address number: %d", snapshot.ProcessedBlock, len(snapshot.Addresses))
+	return nil
+}
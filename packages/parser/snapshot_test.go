@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_serviceParser_Snapshot(t *testing.T) {
+
+	logger := logging.NewDefaultLogger(logging.LevelDebug)
+	parser := &serviceParser{
+		processedBlock: 200,
+		newAddresses:   []string{testAddress3},
+		logger:         logger,
+		store:          NewMemoryTransactionStore(10, 10),
+	}
+	parser.store.PutAddress(testAddress1, 100)
+	parser.store.AppendTransactions(testAddress1, []ethereum.Transaction{{BlockNumber: 150, TransactionHash: "0x01"}}, 100, 201)
+	parser.store.AppendTokenTransfers(testAddress1, []ethereum.TokenTransfer{{BlockNumber: 120, TransactionHash: "0x02"}}, 100, 181)
+	parser.store.PutAddress(testAddress2, 200)
+
+	buf := &bytes.Buffer{}
+	assert.Equal(t, nil, parser.Snapshot(buf))
+
+	restored := &serviceParser{
+		processedBlock: 300,
+		confirmations:  10,
+		logger:         logger,
+		store:          NewMemoryTransactionStore(10, 10),
+	}
+	// existing data would be replaced
+	restored.store.PutAddress(testAddress1, 300)
+	assert.Equal(t, nil, restored.Restore(buf))
+
+	assert.Equal(t, 200, restored.processedBlock)
+	assert.Equal(t, 190, restored.finalizedBlock)
+	assert.Equal(t, []string{testAddress3}, restored.newAddresses)
+	assert.Equal(t, parser.store.Addresses(), restored.store.Addresses())
+	assert.Equal(t, parser.GetTransactions(ethereum.MustParseAddress(testAddress1)), restored.GetTransactions(ethereum.MustParseAddress(testAddress1)))
+	cursor, _ := restored.store.GetCursor(testAddress1)
+	assert.Equal(t, 201, cursor)
+	assert.Equal(t, parser.GetTokenTransfers(ethereum.MustParseAddress(testAddress1)), restored.GetTokenTransfers(ethereum.MustParseAddress(testAddress1)))
+	cursor, _ = restored.store.GetTokenCursor(testAddress1)
+	assert.Equal(t, 181, cursor)
+	cursor, _ = restored.store.GetCursor(testAddress2)
+	assert.Equal(t, 200, cursor)
+}
+
+func Test_serviceParser_Restore(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		started bool
+		wantErr bool
+	}{
+		{
+			name:    "error case 1 - unsupported version",
+			data:    `{"version": 100, "processed_block": 200}`,
+			wantErr: true,
+		},
+		{
+			name:    "error case 2 - broken data",
+			data:    `{"version": 1, "processed_bl`,
+			wantErr: true,
+		},
+		{
+			name:    "error case 3 - parser started",
+			data:    `{"version": 1, "processed_block": 200}`,
+			started: true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := &serviceParser{
+				processedBlock: 300,
+				started:        tt.started,
+				logger:         logging.NewDefaultLogger(logging.LevelDebug),
+				store:          NewMemoryTransactionStore(10, 10),
+			}
+			err := parser.Restore(strings.NewReader(tt.data))
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, 300, parser.processedBlock)
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package parser
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+)
+
+var (
+	ErrAddressNotFound = errors.New("address not found")
+	ErrCursorMismatch  = errors.New("sync cursor mismatch")
+)
+
+// TransactionStore is the storage of the subscribed addresses, their sync cursors and transactions.
+// The implementations should be safe for concurrent use.
+type TransactionStore interface {
+	// PutAddress add a new address with the sync cursor. An existing address is kept unchanged.
+	PutAddress(address string, cursor int) error
+	// AppendTransactions add the newer transactions of an address, and move the sync cursor from `fromCursor` to `toCursor`.
+	// `ErrCursorMismatch` is returned if the cursor is not `fromCursor`, which means it's moved by others.
+	AppendTransactions(address string, transactions []ethereum.Transaction, fromCursor, toCursor int) error
+	// AddHistoricalTransactions add the transactions older than all the stored ones of an address.
+	// They are dropped if exceeding the limitation. The sync cursor is not changed.
+	AddHistoricalTransactions(address string, transactions []ethereum.Transaction) error
+	// GetTransactions query the transactions of an address, the newer ones first.
+	GetTransactions(address string) ([]ethereum.Transaction, bool)
+	// GetCursor get the sync cursor of an address, the first block NOT fetched yet.
+	GetCursor(address string) (int, bool)
+	// AppendTokenTransfers add the newer token transfers of an address, and move the token sync cursor from `fromCursor` to `toCursor`.
+	// The token transfers are synced separately, with the cursor initialized by `PutAddress`.
+	AppendTokenTransfers(address string, transfers []ethereum.TokenTransfer, fromCursor, toCursor int) error
+	// GetTokenTransfers query the token transfers of an address, the newer ones first.
+	GetTokenTransfers(address string) ([]ethereum.TokenTransfer, bool)
+	// GetTokenCursor get the token sync cursor of an address, the first block NOT fetched yet.
+	GetTokenCursor(address string) (int, bool)
+	// Rollback remove the transactions and token transfers after `forkBlock`, and move the sync cursors back to the block after `forkBlock`.
+	Rollback(address string, forkBlock int) error
+	// Evict remove an address and its data
+	Evict(address string) bool
+	// Addresses return all the addresses, the recently used ones first.
+	Addresses() []string
+	Size() int
+	Close() error
+}
+
+// memoryTransactionStore implements the `TransactionStore` interface.
+// The data is stored in memory, and the LRU policy is used to retire the addresses.
+type memoryTransactionStore struct {
+	// `getAddress` of LRU adjusts the order of list, so the mutex, instead of RWMutex, is used.
+	lock                 sync.Mutex
+	maxTransactionNumber int
+	lru                  *addressTransactionLRU
+}
+
+// NewMemoryTransactionStore construct an in-memory `TransactionStore`.
+// At most `maxAddressNumber` addresses, and `maxTransactionNumber` transactions of each address, are stored.
+func NewMemoryTransactionStore(maxAddressNumber, maxTransactionNumber int) TransactionStore {
+	return newMemoryTransactionStore(maxAddressNumber, maxTransactionNumber)
+}
+
+func newMemoryTransactionStore(maxAddressNumber, maxTransactionNumber int) *memoryTransactionStore {
+	return &memoryTransactionStore{
+		maxTransactionNumber: maxTransactionNumber,
+		lru:                  newAddressTransactionLRU(maxAddressNumber),
+	}
+}
+
+func (this *memoryTransactionStore) PutAddress(address string, cursor int) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.lru.getAddressIn(address) != nil {
+		return nil
+	}
+
+	this.lru.putAddress(addressTransaction{
+		address:      address,
+		blockNum:     cursor,
+		transactions: make([]ethereum.Transaction, 0, this.maxTransactionNumber),
+		tokenCursor:  cursor,
+	})
+	return nil
+}
+
+// retiring get the address which would be retired by LRU, if a new address is put.
+func (this *memoryTransactionStore) retiring() (string, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.lru.size() < this.lru.capability {
+		return "", false
+	}
+	tail := this.lru.tail.previous
+	if tail == this.lru.head {
+		return "", false
+	}
+	return tail.address, true
+}
+
+func (this *memoryTransactionStore) AppendTransactions(address string, transactions []ethereum.Transaction, fromCursor, toCursor int) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	data := this.lru.getAddressIn(address)
+	if data == nil {
+		return ErrAddressNotFound
+	}
+	if data.blockNum != fromCursor {
+		return ErrCursorMismatch
+	}
+
+	// the new transactions first, and the old ones are retired if exceeding the limitation
+	var newTrx []ethereum.Transaction
+	if len(transactions) >= this.maxTransactionNumber {
+		newTrx = append(newTrx, transactions[:this.maxTransactionNumber]...)
+	} else {
+		newTrx = append(newTrx, transactions...)
+		space := this.maxTransactionNumber - len(newTrx)
+		if len(data.transactions) <= space {
+			newTrx = append(newTrx, data.transactions...)
+		} else {
+			newTrx = append(newTrx, data.transactions[:space]...)
+		}
+	}
+	data.transactions = newTrx
+	data.blockNum = toCursor
+	return nil
+}
+
+func (this *memoryTransactionStore) AddHistoricalTransactions(address string, transactions []ethereum.Transaction) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	data := this.lru.getAddressIn(address)
+	if data == nil {
+		return ErrAddressNotFound
+	}
+
+	space := this.maxTransactionNumber - len(data.transactions)
+	if space <= 0 {
+		return nil
+	}
+	if len(transactions) > space {
+		transactions = transactions[:space]
+	}
+	data.transactions = append(data.transactions, transactions...)
+	return nil
+}
+
+func (this *memoryTransactionStore) GetTransactions(address string) ([]ethereum.Transaction, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	data := this.lru.getAddress(address)
+	if data == nil {
+		return nil, false
+	}
+
+	// return a copy, since the stored list would be updated by the workers
+	transactions := make([]ethereum.Transaction, len(data.transactions))
+	copy(transactions, data.transactions)
+	return transactions, true
+}
+
+func (this *memoryTransactionStore) GetCursor(address string) (int, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	data := this.lru.getAddressIn(address)
+	if data == nil {
+		return 0, false
+	}
+	return data.blockNum, true
+}
+
+func (this *memoryTransactionStore) AppendTokenTransfers(address string, transfers []ethereum.TokenTransfer, fromCursor, toCursor int) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	data := this.lru.getAddressIn(address)
+	if data == nil {
+		return ErrAddressNotFound
+	}
+	if data.tokenCursor != fromCursor {
+		return ErrCursorMismatch
+	}
+
+	// the same as transactions, the new ones first and the old ones are retired if exceeding the limitation
+	var newTransfers []ethereum.TokenTransfer
+	if len(transfers) >= this.maxTransactionNumber {
+		newTransfers = append(newTransfers, transfers[:this.maxTransactionNumber]...)
+	} else {
+		newTransfers = append(newTransfers, transfers...)
+		space := this.maxTransactionNumber - len(newTransfers)
+		if len(data.tokenTransfers) <= space {
+			newTransfers = append(newTransfers, data.tokenTransfers...)
+		} else {
+			newTransfers = append(newTransfers, data.tokenTransfers[:space]...)
+		}
+	}
+	data.tokenTransfers = newTransfers
+	data.tokenCursor = toCursor
+	return nil
+}
+
+func (this *memoryTransactionStore) GetTokenTransfers(address string) ([]ethereum.TokenTransfer, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	data := this.lru.getAddress(address)
+	if data == nil {
+		return nil, false
+	}
+
+	transfers := make([]ethereum.TokenTransfer, len(data.tokenTransfers))
+	copy(transfers, data.tokenTransfers)
+	return transfers, true
+}
+
+func (this *memoryTransactionStore) GetTokenCursor(address string) (int, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	data := this.lru.getAddressIn(address)
+	if data == nil {
+		return 0, false
+	}
+	return data.tokenCursor, true
+}
+
+func (this *memoryTransactionStore) Rollback(address string, forkBlock int) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	data := this.lru.getAddressIn(address)
+	if data == nil {
+		return ErrAddressNotFound
+	}
+
+	kept := make([]ethereum.Transaction, 0, this.maxTransactionNumber)
+	for _, trx := range data.transactions {
+		if trx.BlockNumber <= forkBlock {
+			kept = append(kept, trx)
+		}
+	}
+	data.transactions = kept
+	if data.blockNum > forkBlock+1 {
+		data.blockNum = forkBlock + 1
+	}
+
+	keptTransfers := make([]ethereum.TokenTransfer, 0, len(data.tokenTransfers))
+	for _, transfer := range data.tokenTransfers {
+		if transfer.BlockNumber <= forkBlock {
+			keptTransfers = append(keptTransfers, transfer)
+		}
+	}
+	data.tokenTransfers = keptTransfers
+	if data.tokenCursor > forkBlock+1 {
+		data.tokenCursor = forkBlock + 1
+	}
+	return nil
+}
+
+func (this *memoryTransactionStore) Evict(address string) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.lru.removeAddress(address)
+}
+
+func (this *memoryTransactionStore) Addresses() []string {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.lru.allAddresses()
+}
+
+func (this *memoryTransactionStore) Size() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.lru.size()
+}
+
+func (this *memoryTransactionStore) Close() error {
+	return nil
+}
+
+// dump return a copy of all the data, the recently used ones first.
+func (this *memoryTransactionStore) dump() []addressTransaction {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	data := make([]addressTransaction, 0, this.lru.size())
+	for node := this.lru.head.next; node != nil && node != this.lru.tail; node = node.next {
+		transactions := make([]ethereum.Transaction, len(node.transactions))
+		copy(transactions, node.transactions)
+		transfers := make([]ethereum.TokenTransfer, len(node.tokenTransfers))
+		copy(transfers, node.tokenTransfers)
+		data = append(data, addressTransaction{
+			address:        node.address,
+			blockNum:       node.blockNum,
+			transactions:   transactions,
+			tokenCursor:    node.tokenCursor,
+			tokenTransfers: transfers,
+		})
+	}
+	return data
+}
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_memoryTransactionStore_AppendTransactions(t *testing.T) {
+	type args struct {
+		transactions []ethereum.Transaction
+		fromCursor   int
+		toCursor     int
+	}
+	tests := []struct {
+		name                 string
+		maxTransactionNumber int
+		old                  []ethereum.Transaction
+		args                 args
+		wantErr              error
+		want                 []ethereum.Transaction
+		wantCursor           int
+	}{
+		{
+			name:                 "normal case 1",
+			maxTransactionNumber: 3,
+			old:                  []ethereum.Transaction{{BlockNumber: 100}},
+			args: args{
+				transactions: []ethereum.Transaction{{BlockNumber: 102}, {BlockNumber: 101}},
+				fromCursor:   101,
+				toCursor:     103,
+			},
+			want:       []ethereum.Transaction{{BlockNumber: 102}, {BlockNumber: 101}, {BlockNumber: 100}},
+			wantCursor: 103,
+		},
+		{
+			name:                 "normal case 2 - old ones retired",
+			maxTransactionNumber: 2,
+			old:                  []ethereum.Transaction{{BlockNumber: 100}},
+			args: args{
+				transactions: []ethereum.Transaction{{BlockNumber: 102}, {BlockNumber: 101}},
+				fromCursor:   101,
+				toCursor:     103,
+			},
+			want:       []ethereum.Transaction{{BlockNumber: 102}, {BlockNumber: 101}},
+			wantCursor: 103,
+		},
+		{
+			name:                 "error case 1 - cursor mismatch",
+			maxTransactionNumber: 3,
+			old:                  []ethereum.Transaction{{BlockNumber: 100}},
+			args: args{
+				transactions: []ethereum.Transaction{{BlockNumber: 102}},
+				fromCursor:   90,
+				toCursor:     103,
+			},
+			wantErr:    ErrCursorMismatch,
+			want:       []ethereum.Transaction{{BlockNumber: 100}},
+			wantCursor: 101,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			this := NewMemoryTransactionStore(10, tt.maxTransactionNumber)
+			assert.Equal(t, nil, this.PutAddress("0xffff", 100))
+			assert.Equal(t, nil, this.AppendTransactions("0xffff", tt.old, 100, 101))
+
+			err := this.AppendTransactions("0xffff", tt.args.transactions, tt.args.fromCursor, tt.args.toCursor)
+			assert.Equal(t, tt.wantErr, err)
+
+			got, ok := this.GetTransactions("0xffff")
+			assert.Equal(t, true, ok)
+			assert.Equal(t, tt.want, got)
+			cursor, _ := this.GetCursor("0xffff")
+			assert.Equal(t, tt.wantCursor, cursor)
+		})
+	}
+
+	this := NewMemoryTransactionStore(10, 10)
+	assert.Equal(t, ErrAddressNotFound, this.AppendTransactions("0xffff", nil, 0, 1))
+}
+
+func Test_memoryTransactionStore_Rollback(t *testing.T) {
+	this := NewMemoryTransactionStore(10, 10)
+	this.PutAddress("0xffff", 100)
+	this.AppendTransactions("0xffff", []ethereum.Transaction{{BlockNumber: 103}, {BlockNumber: 102}, {BlockNumber: 100}}, 100, 104)
+
+	assert.Equal(t, nil, this.Rollback("0xffff", 101))
+	got, _ := this.GetTransactions("0xffff")
+	assert.Equal(t, []ethereum.Transaction{{BlockNumber: 100}}, got)
+	cursor, _ := this.GetCursor("0xffff")
+	assert.Equal(t, 102, cursor)
+
+	// the cursor is not moved forward
+	assert.Equal(t, nil, this.Rollback("0xffff", 200))
+	cursor, _ = this.GetCursor("0xffff")
+	assert.Equal(t, 102, cursor)
+
+	assert.Equal(t, ErrAddressNotFound, this.Rollback("0x0000", 101))
+}
+
+func Test_memoryTransactionStore_AppendTokenTransfers(t *testing.T) {
+	this := NewMemoryTransactionStore(10, 2)
+	this.PutAddress("0xffff", 100)
+
+	cursor, _ := this.GetTokenCursor("0xffff")
+	assert.Equal(t, 100, cursor)
+
+	assert.Equal(t, nil, this.AppendTokenTransfers("0xffff", []ethereum.TokenTransfer{{BlockNumber: 101}}, 100, 102))
+	assert.Equal(t, ErrCursorMismatch, this.AppendTokenTransfers("0xffff", []ethereum.TokenTransfer{{BlockNumber: 101}}, 100, 102))
+	assert.Equal(t, ErrAddressNotFound, this.AppendTokenTransfers("0x0000", nil, 100, 102))
+	// the old ones are retired if exceeding the limitation
+	assert.Equal(t, nil, this.AppendTokenTransfers("0xffff", []ethereum.TokenTransfer{{BlockNumber: 104}, {BlockNumber: 103}}, 102, 105))
+	got, _ := this.GetTokenTransfers("0xffff")
+	assert.Equal(t, []ethereum.TokenTransfer{{BlockNumber: 104}, {BlockNumber: 103}}, got)
+
+	// the transactions are synced separately
+	cursor, _ = this.GetCursor("0xffff")
+	assert.Equal(t, 100, cursor)
+
+	assert.Equal(t, nil, this.Rollback("0xffff", 103))
+	got, _ = this.GetTokenTransfers("0xffff")
+	assert.Equal(t, []ethereum.TokenTransfer{{BlockNumber: 103}}, got)
+	cursor, _ = this.GetTokenCursor("0xffff")
+	assert.Equal(t, 104, cursor)
+}
+
+func Test_memoryTransactionStore_Evict(t *testing.T) {
+	this := NewMemoryTransactionStore(2, 10)
+	this.PutAddress("0x0001", 100)
+	this.PutAddress("0x0002", 100)
+
+	assert.Equal(t, true, this.Evict("0x0001"))
+	assert.Equal(t, false, this.Evict("0x0001"))
+	assert.Equal(t, []string{"0x0002"}, this.Addresses())
+	assert.Equal(t, 1, this.Size())
+
+	_, ok := this.GetTransactions("0x0001")
+	assert.Equal(t, false, ok)
+}
+
+func Test_memoryTransactionStore_AddHistoricalTransactions(t *testing.T) {
+	this := NewMemoryTransactionStore(10, 3)
+	this.PutAddress("0xffff", 100)
+	this.AppendTransactions("0xffff", []ethereum.Transaction{{BlockNumber: 100}}, 100, 101)
+
+	assert.Equal(t, nil, this.AddHistoricalTransactions("0xffff", []ethereum.Transaction{{BlockNumber: 90}, {BlockNumber: 80}, {BlockNumber: 70}}))
+	got, _ := this.GetTransactions("0xffff")
+	assert.Equal(t, []ethereum.Transaction{{BlockNumber: 100}, {BlockNumber: 90}, {BlockNumber: 80}}, got)
+	cursor, _ := this.GetCursor("0xffff")
+	assert.Equal(t, 101, cursor)
+
+	assert.Equal(t, ErrAddressNotFound, this.AddHistoricalTransactions("0x0000", nil))
+}
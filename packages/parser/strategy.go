@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+)
+
+// IndexingStrategy is the way to find the transactions of addresses on chain
+type IndexingStrategy string
+
+const (
+	// StrategyTraceFilter use `trace_filter`, which needs the `trace_*` namespace enabled on node. It's the default one.
+	StrategyTraceFilter IndexingStrategy = "trace_filter"
+	// StrategyBlockScan scan the blocks with full transactions, and the receipts of the matched ones.
+	// It works with any node, but only the top level transactions are found, and it's much slower for a long range.
+	StrategyBlockScan IndexingStrategy = "block_scan"
+)
+
+// Validate check if the strategy is supported. The empty one means the default.
+func (this IndexingStrategy) Validate() error {
+	switch this {
+	case "", StrategyTraceFilter, StrategyBlockScan:
+		return nil
+	}
+	return fmt.Errorf("unknown indexing strategy: %q", string(this))
+}
+
+// applyStrategy wrap the chain accesser according to the strategy
+func applyStrategy(strategy IndexingStrategy, chainAccesser ethereum.EthereumChainAccesser, logger logging.Logger) (ethereum.EthereumChainAccesser, error) {
+	if err := strategy.Validate(); err != nil {
+		return nil, err
+	}
+	if strategy == StrategyBlockScan {
+		return ethereum.NewBlockScanAccesser(chainAccesser, logger), nil
+	}
+	return chainAccesser, nil
+}
@@ -0,0 +1,32 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum/mocks"
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_applyStrategy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+	logger := logging.NewDefaultLogger(logging.LevelDebug)
+
+	got, err := applyStrategy("", chainAccesser, logger)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, chainAccesser, got)
+
+	got, err = applyStrategy(StrategyTraceFilter, chainAccesser, logger)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, chainAccesser, got)
+
+	got, err = applyStrategy(StrategyBlockScan, chainAccesser, logger)
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, chainAccesser, got)
+
+	_, err = applyStrategy("unknown", chainAccesser, logger)
+	assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+)
+
+// SyncStatus is the sync state of an address
+type SyncStatus struct {
+	// the first block of transactions NOT fetched yet
+	Cursor int `json:"cursor"`
+	// the first block of token transfers NOT fetched yet
+	TokenCursor int `json:"token_cursor"`
+	// the error blocking the transactions at the cursor, e.g. the block is rejected by the node permanently.
+	// The cursor is kept, and the block is retried in each round. It's empty if the sync goes well.
+	Error string `json:"error,omitempty"`
+	// the error blocking the token transfers at the token cursor, the same as `Error`
+	TokenError string `json:"token_error,omitempty"`
+}
+
+// syncError is the errors blocking the sync of an address
+type syncError struct {
+	transactions   string
+	tokenTransfers string
+}
+
+// GetSyncStatus get the sync cursors of an address, and the errors blocking them.
+// `false` is returned if it's not subscribed, or not picked up by the task distributor yet.
+func (this *serviceParser) GetSyncStatus(addr ethereum.Address) (SyncStatus, bool) {
+	address := addr.Hex()
+
+	cursor, ok := this.store.GetCursor(address)
+	if !ok {
+		return SyncStatus{}, false
+	}
+	tokenCursor, ok := this.store.GetTokenCursor(address)
+	if !ok {
+		return SyncStatus{}, false
+	}
+	status := SyncStatus{Cursor: cursor, TokenCursor: tokenCursor}
+
+	this.syncLock.Lock()
+	defer this.syncLock.Unlock()
+	if errs, ok := this.syncErrors[address]; ok {
+		status.Error = errs.transactions
+		status.TokenError = errs.tokenTransfers
+	}
+	return status, true
+}
+
+// setSyncError record the error blocking the transactions of an address. It's cleared by `nil`.
+func (this *serviceParser) setSyncError(address string, err error) {
+	this.updateSyncError(address, func(errs *syncError) {
+		errs.transactions = errorMessage(err)
+	})
+}
+
+// setTokenSyncError record the error blocking the token transfers of an address. It's cleared by `nil`.
+func (this *serviceParser) setTokenSyncError(address string, err error) {
+	this.updateSyncError(address, func(errs *syncError) {
+		errs.tokenTransfers = errorMessage(err)
+	})
+}
+
+func (this *serviceParser) updateSyncError(address string, update func(*syncError)) {
+	this.syncLock.Lock()
+	defer this.syncLock.Unlock()
+
+	errs, ok := this.syncErrors[address]
+	if !ok {
+		errs = &syncError{}
+	}
+	update(errs)
+	if errs.transactions == "" && errs.tokenTransfers == "" {
+		delete(this.syncErrors, address)
+		return
+	}
+	if this.syncErrors == nil {
+		this.syncErrors = make(map[string]*syncError)
+	}
+	this.syncErrors[address] = errs
+}
+
+// removeSyncStatus drop the errors of an address, when it's unsubscribed
+func (this *serviceParser) removeSyncStatus(address string) {
+	this.syncLock.Lock()
+	defer this.syncLock.Unlock()
+
+	delete(this.syncErrors, address)
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+)
+
+// the default number of block timestamps cached
+const defaultBlockTimestampCacheSize = 10000
+
+// errTimestampBlockMismatch is returned if the block on chain is another one than the block of the transaction, e.g. during chain reorganization
+var errTimestampBlockMismatch = errors.New("timestamp block mismatch")
+
+// cachedBlockTimestamp is the timestamp of a block, with its hash
+type cachedBlockTimestamp struct {
+	blockHash string
+	timestamp uint64
+}
+
+// blockTimestampResolver attach the block timestamps to the transactions.
+// The timestamps are cached by block number, so each block is got only once.
+type blockTimestampResolver struct {
+	logger        logging.Logger
+	chainAccesser ethereum.EthereumChainAccesser
+	cache         *lruCache
+	// the timeout of getting a block. No timeout if it's 0.
+	timeout time.Duration
+}
+
+func newBlockTimestampResolver(logger logging.Logger, chainAccesser ethereum.EthereumChainAccesser, cacheSize int, timeout time.Duration) *blockTimestampResolver {
+	if cacheSize <= 0 {
+		cacheSize = defaultBlockTimestampCacheSize
+	}
+	return &blockTimestampResolver{
+		logger:        logger,
+		chainAccesser: chainAccesser,
+		cache:         newLRUCache(cacheSize),
+		timeout:       timeout,
+	}
+}
+
+// attach set the timestamps of the transactions in place. The blocks not cached are got by `eth_getBlockByNumber`.
+// The error is returned if the transactions should be fetched again in next round, so they're not stored without timestamps.
+// If the blocks can never be got, the transactions are kept without them.
+func (this *blockTimestampResolver) attach(ctx context.Context, transactions []ethereum.Transaction) error {
+
+	for i := range transactions {
+		trx := &transactions[i]
+		if trx.Timestamp != 0 { // attached by the strategy, e.g. block scan
+			continue
+		}
+		timestamp, ok := this.get(trx.BlockNumber, trx.BlockHash)
+		if !ok {
+			if err := this.fetch(ctx, trx.BlockNumber); err != nil {
+				// the block may be not on the lagging nodes yet
+				if errors.Is(err, ethereum.ErrBlockNotFound) || !isPermanentFailure(ctx, err) {
+					return err
+				}
+				this.logger.Errorf("permanent failure, skip block timestamps | block: %d, error: %s", trx.BlockNumber, err.Error())
+				return nil
+			}
+			if timestamp, ok = this.get(trx.BlockNumber, trx.BlockHash); !ok { // the canonical block is another one
+				return fmt.Errorf("%w | transaction: %s, block: %d, block hash: %s", errTimestampBlockMismatch, trx.TransactionHash, trx.BlockNumber, trx.BlockHash)
+			}
+		}
+		trx.Timestamp = timestamp
+	}
+	return nil
+}
+
+// put cache the timestamp of a block, e.g. got by the reorganization detection
+func (this *blockTimestampResolver) put(blockNum int, block *ethereum.Block) error {
+	timestamp, err := ethereum.ParseQuantity(block.Timestamp)
+	if err != nil {
+		return err
+	}
+	this.cache.put(strconv.Itoa(blockNum), &cachedBlockTimestamp{
+		blockHash: block.Hash,
+		timestamp: timestamp.Uint64(),
+	})
+	return nil
+}
+
+// get the timestamp of a block, if it's cached for the same hash
+func (this *blockTimestampResolver) get(blockNum int, blockHash string) (uint64, bool) {
+	value, ok := this.cache.get(strconv.Itoa(blockNum))
+	if !ok {
+		return 0, false
+	}
+	cached := value.(*cachedBlockTimestamp)
+	if blockHash != "" && cached.blockHash != "" && cached.blockHash != blockHash {
+		return 0, false
+	}
+	return cached.timestamp, true
+}
+
+func (this *blockTimestampResolver) fetch(ctx context.Context, blockNum int) error {
+
+	if this.timeout > 0 {
+		var cancelFunc context.CancelFunc
+		ctx, cancelFunc = context.WithDeadline(ctx, time.Now().Add(this.timeout))
+		defer cancelFunc()
+	}
+	req := &ethereum.EthGetBlockByNumberRequest{
+		BlockNumber: convertDecimalToHex(blockNum),
+		RequestId:   generateRequestId(),
+	}
+	block, err := this.chainAccesser.EthGetBlockByNumber(ctx, req)
+	if err != nil {
+		this.logger.Errorf("call ethereum chain to get block fail | block: %d, retryable: %t, error: %s", blockNum, ethereum.IsRetryable(err), err.Error())
+		return err
+	}
+	return this.put(blockNum, block)
+}
+
+// recordBlockTimestamp cache the timestamp of a processed block, so it's not got again for the transactions in it
+func (this *serviceParser) recordBlockTimestamp(blockNum int, block *ethereum.Block) {
+	if this.timestamps == nil {
+		return
+	}
+	if err := this.timestamps.put(blockNum, block); err != nil {
+		this.logger.Errorf("record block timestamp fail | block: %d, timestamp: %s, error: %s", blockNum, block.Timestamp, err.Error())
+	}
+}
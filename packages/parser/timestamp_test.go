@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/ethereum/mocks"
+	"github.com/brofu/simple_ethereum_parser/packages/logging"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_blockTimestampResolver_attach(t *testing.T) {
+	block100 := &ethereum.Block{Number: "0x64", Hash: "0xb1", Timestamp: "0x65920080"}
+	block101 := &ethereum.Block{Number: "0x65", Hash: "0xb2", Timestamp: "0x6592008c"}
+
+	tests := []struct {
+		name         string
+		transactions []ethereum.Transaction
+		cached       map[int]*ethereum.Block
+		// the blocks got from chain, keyed by the hex number
+		blocks map[string]*ethereum.Block
+		err    error
+		// the number of blocks got from chain
+		wantQueried int
+		want        []uint64
+		wantErr     bool
+		// the error matched, if it's set
+		wantErrIs error
+	}{
+		{
+			name: "normal case 1 - one call per block",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockNumber: 100, BlockHash: "0xb1"},
+				{TransactionHash: "0x01", BlockNumber: 100, BlockHash: "0xb1", TraceAddress: []string{"0"}},
+				{TransactionHash: "0x02", BlockNumber: 101},
+			},
+			blocks:      map[string]*ethereum.Block{"0x64": block100, "0x65": block101},
+			wantQueried: 2,
+			want:        []uint64{1704067200, 1704067200, 1704067212},
+		},
+		{
+			name: "normal case 2 - cached by processed blocks",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockNumber: 100, BlockHash: "0xb1"},
+				{TransactionHash: "0x02", BlockNumber: 101, BlockHash: "0xb2"},
+			},
+			cached:      map[int]*ethereum.Block{100: block100},
+			blocks:      map[string]*ethereum.Block{"0x65": block101},
+			wantQueried: 1,
+			want:        []uint64{1704067200, 1704067212},
+		},
+		{
+			name: "normal case 3 - attached by strategy",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockNumber: 100, Timestamp: 1},
+			},
+			want: []uint64{1},
+		},
+		{
+			name: "normal case 4 - permanent failure, kept without timestamps",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockNumber: 100},
+			},
+			err:         &ethereum.RPCError{Code: -32601, Message: "Method not found"},
+			wantQueried: 1,
+			want:        []uint64{0},
+		},
+		{
+			name: "failure case 1 - block not found",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockNumber: 100},
+			},
+			err:         ethereum.ErrBlockNotFound,
+			wantQueried: 1,
+			wantErr:     true,
+		},
+		{
+			name: "failure case 2 - retryable failure",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockNumber: 100},
+			},
+			err:         &ethereum.StatusError{StatusCode: 503},
+			wantQueried: 1,
+			wantErr:     true,
+		},
+		{
+			name: "failure case 3 - transaction of orphaned block",
+			transactions: []ethereum.Transaction{
+				{TransactionHash: "0x01", BlockNumber: 100, BlockHash: "0xb0"},
+			},
+			cached:      map[int]*ethereum.Block{100: block100},
+			blocks:      map[string]*ethereum.Block{"0x64": block100},
+			wantQueried: 1,
+			wantErr:     true,
+			wantErrIs:   errTimestampBlockMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+			chainAccesser.EXPECT().EthGetBlockByNumber(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, req *ethereum.EthGetBlockByNumberRequest) (*ethereum.Block, error) {
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return tt.blocks[req.BlockNumber], nil
+				}).Times(tt.wantQueried)
+
+			resolver := newBlockTimestampResolver(logging.NewDefaultLogger(logging.LevelDebug), chainAccesser, 10, time.Second)
+			for num, block := range tt.cached {
+				assert.NoError(t, resolver.put(num, block))
+			}
+
+			err := resolver.attach(context.Background(), tt.transactions)
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.wantErrIs != nil {
+					assert.True(t, errors.Is(err, tt.wantErrIs), err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			var got []uint64
+			for _, trx := range tt.transactions {
+				got = append(got, trx.Timestamp)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_serviceParser_enrichTransactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	chainAccesser := mocks.NewMockEthereumChainAccesser(ctrl)
+
+	logger := logging.NewDefaultLogger(logging.LevelDebug)
+	parser := &serviceParser{
+		logger:        logger,
+		chainAccesser: chainAccesser,
+		timestamps:    newBlockTimestampResolver(logger, chainAccesser, 10, time.Second),
+	}
+	// recorded by the reorganization detection, no call for it
+	parser.recordBlockTimestamp(100, &ethereum.Block{Hash: "0xb1", Timestamp: "0x65920080"})
+	// invalid one is not recorded
+	parser.recordBlockTimestamp(101, &ethereum.Block{Hash: "0xb2", Timestamp: "invalid"})
+	chainAccesser.EXPECT().EthGetBlockByNumber(gomock.Any(), gomock.Any()).Return(
+		&ethereum.Block{Hash: "0xb2", Timestamp: "0x6592008c"}, nil)
+
+	transactions := []ethereum.Transaction{
+		{TransactionHash: "0x01", BlockNumber: 100, BlockHash: "0xb1"},
+		{TransactionHash: "0x02", BlockNumber: 101, BlockHash: "0xb2"},
+	}
+	assert.NoError(t, parser.enrichTransactions(context.Background(), transactions))
+	assert.Equal(t, uint64(1704067200), transactions[0].Timestamp)
+	assert.Equal(t, uint64(1704067212), transactions[1].Timestamp)
+	assert.Nil(t, transactions[0].Receipt)
+}
@@ -1,23 +1,48 @@
 package parser
 
 import (
+	"context"
+
+	"github.com/brofu/simple_ethereum_parser/packages/abi"
 	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
 	"github.com/brofu/simple_ethereum_parser/packages/logging"
 )
 
+type ToolParserConfiguration struct {
+	// the way to find the transactions on chain. `StrategyTraceFilter` is used if it's empty.
+	Strategy IndexingStrategy
+	// the first block to query the transactions.
+	// It should be set for `StrategyBlockScan`, since it's too slow to scan from the genesis block.
+	FromBlock int
+}
+
 type toolParser struct {
 	logger        logging.Logger
 	chainAccesser ethereum.EthereumChainAccesser
+	fromBlock     int
+	// attach the block timestamps to the transactions
+	timestamps *blockTimestampResolver
+	// the ABIs to decode the calls to contracts
+	abis *abiRegistry
 }
 
-func NewToolParser(logger logging.Logger, chainAccesser ethereum.EthereumChainAccesser) Parser {
+func NewToolParser(logger logging.Logger, chainAccesser ethereum.EthereumChainAccesser, config ToolParserConfiguration) Parser {
+	chainAccesser, err := applyStrategy(config.Strategy, chainAccesser, logger)
+	if err != nil {
+		logger.Errorf("apply indexing strategy fail | error: %s", err.Error())
+		panic(err)
+	}
+
 	return &toolParser{
 		logger:        logger,
 		chainAccesser: chainAccesser,
+		fromBlock:     config.FromBlock,
+		timestamps:    newBlockTimestampResolver(logger, chainAccesser, 0, 0),
+		abis:          newABIRegistry(),
 	}
 }
 
-func (this *toolParser) GetTransactions(address string) []ethereum.Transaction {
+func (this *toolParser) GetTransactions(address ethereum.Address) []ethereum.Transaction {
 
 	// get current block number
 	bn := this.GetCurrentBlock()
@@ -27,10 +52,10 @@ func (this *toolParser) GetTransactions(address string) []ethereum.Transaction {
 	}
 
 	req := &ethereum.EthGetCurrentTransactionsByAddressRequest{
-		FromBlock:   "0x0",
+		FromBlock:   convertDecimalToHex(this.fromBlock),
 		ToBlock:     convertDecimalToHex(bn),
-		FromAddress: address,
-		ToAddress:   address,
+		FromAddress: address.Hex(),
+		ToAddress:   address.Hex(),
 		RequestId:   generateRequestId(),
 	}
 	transactions, err := this.chainAccesser.EthGetCurrentTransactionsByAddress(nil, req)
@@ -38,14 +63,101 @@ func (this *toolParser) GetTransactions(address string) []ethereum.Transaction {
 		this.logger.Errorf("get error: %s", err.Error())
 		return nil
 	}
+	// the transactions are still shown without timestamps
+	if err := this.timestamps.attach(context.Background(), transactions); err != nil {
+		this.logger.Errorf("attach block timestamps fail | address: %s, error: %s", address.Hex(), err.Error())
+	}
+	this.abis.decode(transactions)
 	return transactions
 }
 
+func (this *toolParser) GetTokenTransfers(address ethereum.Address) []ethereum.TokenTransfer {
+
+	bn := this.GetCurrentBlock()
+	if bn == 0 {
+		this.logger.Errorf("get latest block number fail")
+		return nil
+	}
+
+	transfers, err := ethereum.GetTokenTransfers(nil, this.chainAccesser, convertDecimalToHex(this.fromBlock), convertDecimalToHex(bn), []string{address.Hex()}, generateRequestId())
+	if err != nil {
+		this.logger.Errorf("get error: %s", err.Error())
+		return nil
+	}
+	return transfers
+}
+
+// GetAccountState query the balance and nonce of an address at the latest block
+func (this *toolParser) GetAccountState(address ethereum.Address) (AccountState, bool) {
+
+	bn := this.GetCurrentBlock()
+	if bn == 0 {
+		this.logger.Errorf("get latest block number fail")
+		return AccountState{}, false
+	}
+
+	blockTag := convertDecimalToHex(bn)
+	balance, err := this.chainAccesser.EthGetBalance(nil, &ethereum.EthGetBalanceRequest{
+		Address:     address.Hex(),
+		BlockNumber: blockTag,
+		RequestId:   generateRequestId(),
+	})
+	if err != nil {
+		this.logger.Errorf("get error: %s", err.Error())
+		return AccountState{}, false
+	}
+	nonce, err := this.chainAccesser.EthGetTransactionCount(nil, &ethereum.EthGetTransactionCountRequest{
+		Address:     address.Hex(),
+		BlockNumber: blockTag,
+		RequestId:   generateRequestId(),
+	})
+	if err != nil {
+		this.logger.Errorf("get error: %s", err.Error())
+		return AccountState{}, false
+	}
+	return AccountState{Address: address, Balance: balance, Nonce: nonce, BlockNumber: bn}, true
+}
+
+func (this *toolParser) RegisterABI(contract ethereum.Address, contractABI *abi.ABI) {
+	this.abis.register(contract.Hex(), contractABI)
+}
+
+// GetFinalizedTransactions is the same as `GetTransactions`.
+// There is no confirmation setting for cmd tool scenarios, all the transactions on chain are treated as final.
+func (this *toolParser) GetFinalizedTransactions(address ethereum.Address) []ethereum.Transaction {
+	return this.GetTransactions(address)
+}
+
 // Subscribe is not necessary for cmd tool scenarios
-func (this *toolParser) Subscribe(address string) bool {
+func (this *toolParser) Subscribe(address ethereum.Address) bool {
+	return true
+}
+
+// SubscribeFrom is not necessary for cmd tool scenarios, the whole history is queried by `GetTransactions`
+func (this *toolParser) SubscribeFrom(address ethereum.Address, fromBlock int) bool {
 	return true
 }
 
+// GetBackfillProgress is not necessary for cmd tool scenarios
+func (this *toolParser) GetBackfillProgress(address ethereum.Address) (BackfillProgress, bool) {
+	return BackfillProgress{}, false
+}
+
+// GetSyncStatus is not necessary for cmd tool scenarios, the transactions are queried on demand
+func (this *toolParser) GetSyncStatus(address ethereum.Address) (SyncStatus, bool) {
+	return SyncStatus{}, false
+}
+
+// Unsubscribe is not necessary for cmd tool scenarios
+func (this *toolParser) Unsubscribe(address ethereum.Address) bool {
+	return true
+}
+
+// GetPendingTransactions is not supported for cmd tool scenarios, since the mempool should be watched before the transactions enter it
+func (this *toolParser) GetPendingTransactions(address ethereum.Address) []PendingTransaction {
+	return []PendingTransaction{}
+}
+
 func (this *toolParser) GetCurrentBlock() int {
 	req := &ethereum.EthGetCurrentBlockNumberRequest{
 		RequestId: generateRequestId(),
@@ -0,0 +1,348 @@
+// Package websocket is a minimal implementation of the WebSocket protocol (RFC 6455), based on the standard library.
+// It only supports what's needed by JSON RPC: text and binary messages, fragmentation, ping / pong and close.
+// Extensions (e.g. compression) and sub-protocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xa
+
+	// the GUID to compute `Sec-WebSocket-Accept`, defined by RFC 6455
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// the max size of a message, to protect from the broken peers
+	maxMessageSize = 32 << 20
+)
+
+var (
+	ErrClosed          = errors.New("websocket closed")
+	ErrBadHandshake    = errors.New("bad websocket handshake")
+	ErrMessageTooLarge = errors.New("websocket message too large")
+	ErrProtocol        = errors.New("websocket protocol error")
+)
+
+// Conn is a WebSocket connection. `ReadMessage` should be called by one goroutine, and `WriteMessage` is safe for concurrent use.
+type Conn struct {
+	conn net.Conn
+	// the buffered reader of `conn`, which may contain the data after the handshake
+	reader *bufio.Reader
+	// the frames sent by client should be masked, and the ones sent by server should not
+	isClient bool
+
+	writeLock sync.Mutex
+	closeOnce sync.Once
+}
+
+// Dial connect to a WebSocket server in `rawURL`, with `ws` or `wss` scheme.
+func Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, error) {
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	host := u.Host
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	case "wss":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("unsupported websocket scheme: %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the handshake should be finished in time
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	rawKey := make([]byte, 16)
+	if _, err := rand.Read(rawKey); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(rawKey)
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Host:       u.Host,
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != computeAccept(key) {
+		conn.Close()
+		return nil, fmt.Errorf("%w: status: %s", ErrBadHandshake, resp.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return &Conn{conn: conn, reader: reader, isClient: true}, nil
+}
+
+// Accept upgrade an HTTP request to WebSocket, on the server side.
+func Accept(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet ||
+		!strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "bad websocket handshake", http.StatusBadRequest)
+		return nil, ErrBadHandshake
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, ErrBadHandshake
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + computeAccept(key) + "\r\n\r\n"
+	if _, err := rw.WriteString(resp); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, reader: rw.Reader, isClient: false}, nil
+}
+
+// ReadMessage read the next text or binary message. The fragmented messages are joined.
+// Ping is answered automatically. `ErrClosed` is returned when the peer closes the connection.
+func (this *Conn) ReadMessage() (int, []byte, error) {
+
+	var opcode int
+	var message []byte
+	for {
+		fin, op, payload, err := this.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case OpPing:
+			if err := this.writeFrame(OpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			this.writeFrame(OpClose, payload)
+			this.Close()
+			return 0, nil, ErrClosed
+		case OpText, OpBinary:
+			if message != nil { // a new message before the fragmented one finished
+				return 0, nil, ErrProtocol
+			}
+			opcode = op
+			message = payload
+		case OpContinuation:
+			if message == nil {
+				return 0, nil, ErrProtocol
+			}
+			message = append(message, payload...)
+		default:
+			return 0, nil, ErrProtocol
+		}
+
+		if len(message) > maxMessageSize {
+			return 0, nil, ErrMessageTooLarge
+		}
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// WriteMessage send a text message
+func (this *Conn) WriteMessage(data []byte) error {
+	return this.writeFrame(OpText, data)
+}
+
+// SetReadDeadline set the deadline of reading the coming messages
+func (this *Conn) SetReadDeadline(t time.Time) error {
+	return this.conn.SetReadDeadline(t)
+}
+
+// Close close the underlying connection. It's safe to be called more than once.
+func (this *Conn) Close() error {
+	var err error
+	this.closeOnce.Do(func() {
+		err = this.conn.Close()
+	})
+	return err
+}
+
+func (this *Conn) readFrame() (bool, int, []byte, error) {
+
+	var header [2]byte
+	if _, err := io.ReadFull(this.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	op := int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(this.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(this.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, ErrMessageTooLarge
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(this.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(this.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+func (this *Conn) writeFrame(op int, payload []byte) error {
+	this.writeLock.Lock()
+	defer this.writeLock.Unlock()
+
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|byte(op)) // always in one frame
+
+	var maskBit byte
+	if this.isClient {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[len(frame)-2:], uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[len(frame)-8:], uint64(len(payload)))
+	}
+
+	if this.isClient {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range payload {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	_, err := this.conn.Write(frame)
+	return err
+}
+
+func computeAccept(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// headerContains check if the comma separated header contains the token, case-insensitively
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, s := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newEchoServer start a server which echoes the messages back
+func newEchoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(message) == "close" {
+				conn.writeFrame(OpClose, nil)
+				return
+			}
+			if string(message) == "ping" { // ping the client before echo
+				conn.writeFrame(OpPing, []byte("hello"))
+			}
+			if err := conn.WriteMessage(message); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestConn_Message(t *testing.T) {
+
+	server := newEchoServer()
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{
+			name:    "short message",
+			message: `{"jsonrpc":"2.0","method":"eth_blockNumber","id":1}`,
+		},
+		{
+			name:    "16 bits length",
+			message: strings.Repeat("a", 1000),
+		},
+		{
+			name:    "64 bits length",
+			message: strings.Repeat("b", 70000),
+		},
+		{
+			name:    "ping answered",
+			message: "ping",
+		},
+		{
+			name:    "empty message",
+			message: "",
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, conn.WriteMessage([]byte(tt.message)))
+			opcode, message, err := conn.ReadMessage()
+			assert.NoError(t, err)
+			assert.Equal(t, OpText, opcode)
+			assert.Equal(t, tt.message, string(message))
+		})
+	}
+
+	// closed by server
+	assert.NoError(t, conn.WriteMessage([]byte("close")))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, errors.Is(err, ErrClosed))
+}
+
+func TestDial(t *testing.T) {
+
+	server := newEchoServer()
+	defer server.Close()
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer plain.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{
+			name: "normal case",
+			url:  "ws" + strings.TrimPrefix(server.URL, "http"),
+		},
+		{
+			name:    "not a websocket server",
+			url:     "ws" + strings.TrimPrefix(plain.URL, "http"),
+			wantErr: ErrBadHandshake,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn, err := Dial(ctx, tt.url, nil)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), err)
+				return
+			}
+			assert.NoError(t, err)
+			conn.Close()
+		})
+	}
+
+	// handshake rejected by server
+	resp, err := http.Get(server.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+
+	// unsupported scheme
+	_, err = Dial(context.Background(), server.URL, nil)
+	assert.Error(t, err)
+}
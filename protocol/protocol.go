@@ -2,14 +2,32 @@ package protocol
 
 import (
 	"encoding/json"
+	"time"
 
+	"github.com/brofu/simple_ethereum_parser/packages/abi"
 	"github.com/brofu/simple_ethereum_parser/packages/ethereum"
+	"github.com/brofu/simple_ethereum_parser/packages/parser"
 )
 
 var (
 	//
 	ErrCodeUnmarl = -100
 	ErrMsgUnmarl  = "Unmarshal request error"
+
+	ErrCodeBackfillNotFound = -101
+	ErrMsgBackfillNotFound  = "Backfill job not found"
+
+	ErrCodeInvalidAddress = -102
+	ErrMsgInvalidAddress  = "Invalid address"
+
+	ErrCodeAccountNotFound = -103
+	ErrMsgAccountNotFound  = "Account not found"
+
+	ErrCodeInvalidABI = -104
+	ErrMsgInvalidABI  = "Invalid ABI"
+
+	ErrCodeSyncStatusNotFound = -105
+	ErrMsgSyncStatusNotFound  = "Sync status not found"
 )
 
 type Error struct {
@@ -33,13 +51,150 @@ type GetBlockNumberResult struct {
 }
 
 type GetTransactionsParams struct {
-	Address string `json:"address"`
+	// nil if it's missing. The addresses in params are pointers, since the zero address is valid.
+	Address *ethereum.Address `json:"address"`
+	// only return the transactions with enough confirmations
+	Finalized bool `json:"finalized"`
 }
 
 type SubscribeParams struct {
-	Address string `json:"address"`
+	Address *ethereum.Address `json:"address"`
+	// backfill the history from this block, if it's set
+	FromBlock *int `json:"from_block,omitempty"`
+}
+
+type GetBackfillProgressParams struct {
+	Address *ethereum.Address `json:"address"`
+}
+
+type GetSyncStatusParams struct {
+	Address *ethereum.Address `json:"address"`
+}
+
+type UnsubscribeParams struct {
+	Address *ethereum.Address `json:"address"`
+}
+
+type GetTokenTransfersParams struct {
+	Address *ethereum.Address `json:"address"`
+}
+
+type GetAccountParams struct {
+	Address *ethereum.Address `json:"address"`
+}
+
+type GetPendingTransactionsParams struct {
+	Address *ethereum.Address `json:"address"`
+}
+
+type RegisterABIParams struct {
+	// the contract address
+	Address *ethereum.Address `json:"address"`
+	// the ABI JSON of the contract. The registered one is removed if it's null.
+	ABI json.RawMessage `json:"abi"`
 }
 
 type GetTransactionsResult struct {
 	Transactions []ethereum.Transaction `json:"transactions"`
 }
+
+// Transaction is a transaction in the API response, with the amounts in human-readable form
+type Transaction struct {
+	ethereum.Transaction
+	Readable ReadableAmounts `json:"readable"`
+}
+
+// ReadableAmounts is the amounts of a transaction in ether and decimal, with the block time
+type ReadableAmounts struct {
+	// e.g. "1.5 ETH"
+	Value   string `json:"value"`
+	Gas     uint64 `json:"gas"`
+	GasUsed uint64 `json:"gasUsed"`
+	// the fee paid, e.g. "0.000021 ETH". It's empty if the receipt is not attached.
+	Fee string `json:"fee,omitempty"`
+	// the block time in UTC, e.g. "2024-01-01T00:00:00Z". It's empty if the timestamp is not attached.
+	Time string `json:"time,omitempty"`
+	// the signature of the called function, e.g. "transfer(address,uint256)".
+	// It's from the `decoded` section, or the signature registry if the ABI of the contract is not registered.
+	Method string `json:"method,omitempty"`
+}
+
+// NewTransactions attach the human-readable amounts to the transactions. The method names are looked up in `signatures` if it's set.
+func NewTransactions(transactions []ethereum.Transaction, signatures *abi.SignatureRegistry) []Transaction {
+	result := make([]Transaction, 0, len(transactions))
+	for _, trx := range transactions {
+		readable := ReadableAmounts{
+			Value:   trx.Action.Value.Ether() + " ETH",
+			Gas:     trx.Action.Gas.Uint64(),
+			GasUsed: trx.Result.GasUsed.Uint64(),
+		}
+		if trx.Receipt != nil {
+			readable.Fee = trx.Receipt.Fee.Ether() + " ETH"
+		}
+		if trx.Timestamp != 0 {
+			readable.Time = formatTimestamp(trx.Timestamp)
+		}
+		if trx.Decoded != nil {
+			readable.Method = trx.Decoded.Signature
+		} else if signatures != nil {
+			readable.Method, _ = signatures.Function(trx.Action.Input)
+		}
+		result = append(result, Transaction{
+			Transaction: trx,
+			Readable:    readable,
+		})
+	}
+	return result
+}
+
+// TokenTransfer is a token transfer in the API response, with the amounts in human-readable form
+type TokenTransfer struct {
+	ethereum.TokenTransfer
+	Readable ReadableTokenAmounts `json:"readable"`
+}
+
+// ReadableTokenAmounts is the amounts of a token transfer in decimal.
+// The decimals of the token are not known, so the value is in the smallest unit of the token.
+type ReadableTokenAmounts struct {
+	// the amount of ERC-20 token, e.g. "1000"
+	Value string `json:"value,omitempty"`
+	// the token ID of ERC-721 token, e.g. "7"
+	TokenId string `json:"tokenId,omitempty"`
+}
+
+// NewTokenTransfers attach the human-readable amounts to the token transfers
+func NewTokenTransfers(transfers []ethereum.TokenTransfer) []TokenTransfer {
+	result := make([]TokenTransfer, 0, len(transfers))
+	for _, transfer := range transfers {
+		var readable ReadableTokenAmounts
+		if transfer.Standard == ethereum.TokenStandardERC721 {
+			readable.TokenId = transfer.TokenId.String()
+		} else {
+			readable.Value = transfer.Value.String()
+		}
+		result = append(result, TokenTransfer{
+			TokenTransfer: transfer,
+			Readable:      readable,
+		})
+	}
+	return result
+}
+
+// Account is the balance and nonce of an address in the API response, with the balance in ether
+type Account struct {
+	parser.AccountState
+	// e.g. "1.5 ETH"
+	BalanceEther string `json:"balance_ether"`
+}
+
+func NewAccount(state parser.AccountState) Account {
+	return Account{
+		AccountState: state,
+		BalanceEther: state.Balance.Ether() + " ETH",
+	}
+}
+
+// formatTimestamp format the unix time in seconds as RFC 3339 in UTC
+func formatTimestamp(timestamp uint64) string {
+	return time.Unix(int64(timestamp), 0).UTC().Format(time.RFC3339)
+}